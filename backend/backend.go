@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/mux"
 	logging "github.com/sirupsen/logrus"
 	arbit "github.com/sunmxt/arbiter"
 )
@@ -20,6 +21,8 @@ var (
 	ErrConnectionClosed       = errors.New("connection closed")
 	ErrBackendTypeUnknown     = errors.New("Unknown backend type")
 	ErrInvalidBackendConfig   = errors.New("Unknown backend config")
+	ErrPunchUnsupported       = errors.New("hole punching not supported")
+	ErrPunchIdentityMismatch  = errors.New("punched peer with unexpected identity")
 )
 
 type Link interface {
@@ -27,6 +30,13 @@ type Link interface {
 	Close() error
 }
 
+// FrameSender is implemented by links which keep frames after sending returns, e.g. by queueing.
+type FrameSender interface {
+	// SendFrame sends frame. Link takes its own reference of frame if the frame is kept,
+	// so that a frame broadcasted to many links is shared rather than copied.
+	SendFrame(*mux.FrameBuffer) error
+}
+
 type Backend interface {
 	Type() Type
 	Priority() uint32
@@ -47,12 +57,28 @@ const (
 	UnknownBackend = Type(0)
 	// TCPBackend identifies TCP Backend.
 	TCPBackend = Type(1)
+	// UDPBackend identifies UDP Backend.
+	UDPBackend = Type(2)
+	// MemBackend identifies in-memory Backend.
+	MemBackend = Type(3)
+	// UnixBackend identifies Unix domain socket Backend.
+	UnixBackend = Type(4)
+	// WSBackend identifies WebSocket Backend.
+	WSBackend = Type(5)
 )
 
 func (b Type) String() string {
 	switch b {
 	case TCPBackend:
 		return "tcp"
+	case UDPBackend:
+		return "udp"
+	case MemBackend:
+		return "mem"
+	case UnixBackend:
+		return "unix"
+	case WSBackend:
+		return "ws"
 	default:
 		return "unknown"
 	}
@@ -69,6 +95,70 @@ func (p Endpoint) String() string {
 	return p.Type.String() + "://" + p.Endpoint
 }
 
+// Reloadable is implemented by backends which can apply configuration changes in place.
+type Reloadable interface {
+	// Reload applies configuration from creator.
+	// It reports false if backend should be recreated. Links restarted to apply changes are returned.
+	Reload(BackendCreator) (restarted []string, reloaded bool)
+}
+
+// LinkInfo describes live link.
+type LinkInfo struct {
+	Publish string // publish endpoint of peer.
+	Remote  string // remote address.
+	PSKID   string // fingerprint of pre-shared key authenticating link. See PSKID.
+	Stripe  uint8  // index among parallel connections to peer.
+
+	Compression   string            // negotiated compression algorithm. empty if disabled.
+	CompressStats mux.CompressStats // statistics of compressed sending.
+
+	Stats LinkStats // traffic and error statistics.
+}
+
+// LinkLister is implemented by backends which can report live links.
+type LinkLister interface {
+	Links() []LinkInfo
+
+	// LinkStats reports statistics by publish endpoint of peer. Links not connected are included,
+	// so that failed handshakes are counted.
+	LinkStats() map[string]LinkStats
+}
+
+// FrameSizeLimiter is implemented by backends which can't carry frames beyond a size.
+type FrameSizeLimiter interface {
+	// MaxFrameSize returns max size of frame passed to Link.Send.
+	MaxFrameSize() int
+}
+
+// Observer is implemented by backends which report where peers are seen from.
+type Observer interface {
+	// ObservedAddress returns remote address of inbound link from peer, i.e. where the peer is seen from.
+	// Empty string is returned if there is no such link.
+	ObservedAddress(publish string) string
+}
+
+// Reflector is implemented by backends which help peers behind NAT discover their reflexive endpoints.
+type Reflector interface {
+	Observer
+
+	// ReflexiveCandidate derives candidate publish endpoint from address this backend is observed at.
+	ReflexiveCandidate(observed string) (string, bool)
+	// ProbeEndpoint handshakes with endpoint and reports publish endpoint of peer answering there.
+	ProbeEndpoint(endpoint string) (identity string, err error)
+}
+
+// Puncher is implemented by backends which traverse NAT by connecting to peer simultaneously.
+type Puncher interface {
+	Observer
+
+	// PunchPort returns local port punching starts from. Zero port means the observed port is kept.
+	// It reports false if punching is disabled.
+	PunchPort() (port uint16, enabled bool)
+	// Punch connects to peer with identity at endpoint, while the peer connects to us at the same time.
+	// Link is bound to endpoint, so that the peer is reached by Connect(endpoint) afterwards.
+	Punch(endpoint, identity string) (Link, error)
+}
+
 type BackendCreator interface {
 	Type() Type
 	Priority() uint32
@@ -78,11 +168,19 @@ type BackendCreator interface {
 }
 
 var creators = map[string]func(*config.Backend) (BackendCreator, error){
-	"tcp": newTCPCreator,
+	"tcp":  newTCPCreator,
+	"udp":  newUDPCreator,
+	"mem":  newMemCreator,
+	"unix": newUnixCreator,
+	"ws":   newWSCreator,
 }
 
 var TypeByName = map[string]Type{
-	TCPBackend.String(): TCPBackend,
+	TCPBackend.String():  TCPBackend,
+	UDPBackend.String():  UDPBackend,
+	MemBackend.String():  MemBackend,
+	UnixBackend.String(): UnixBackend,
+	WSBackend.String():   WSBackend,
 }
 
 func GetCreator(ty string, cfg *config.Backend) (BackendCreator, error) {
@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	ErrInvalidEndpoint = errors.New("invalid endpoint")
+)
+
+// normalizeHostPort canonicalizes IP literal in host:port, so that one address
+// always gives one endpoint string. IPv6 hosts must be bracketed, e.g. [2001:db8::1]:3880.
+func normalizeHostPort(hostport string) (string, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", fmt.Errorf("%v: %v", ErrInvalidEndpoint, err)
+	}
+	if port == "" {
+		return "", fmt.Errorf("%v: missing port in address %v", ErrInvalidEndpoint, hostport)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// NormalizeEndpoint canonicalizes endpoint of backend type.
+func NormalizeEndpoint(ty Type, endpoint string) (string, error) {
+	switch ty {
+	case TCPBackend, UDPBackend:
+		return normalizeHostPort(endpoint)
+	case WSBackend:
+		host, path := endpoint, ""
+		if idx := strings.Index(endpoint, "/"); idx >= 0 {
+			host, path = endpoint[:idx], endpoint[idx:]
+		}
+		host, err := normalizeHostPort(host)
+		if err != nil {
+			return "", err
+		}
+		return host + path, nil
+	}
+	return endpoint, nil
+}
+
+// ParseEndpoint parses endpoint in form of "type:address" or "type://address".
+// e.g. tcp:10.240.0.1:3880, udp:[2001:db8::1]:3880, ws://[2001:db8::1]:80/utt
+func ParseEndpoint(s string) (ep Endpoint, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) < 2 {
+		return NullEndpoint, fmt.Errorf("%v: endpoint with invalid format \"%v\"", ErrInvalidEndpoint, s)
+	}
+	ty, hasType := TypeByName[parts[0]]
+	if !hasType || ty == UnknownBackend {
+		return NullEndpoint, fmt.Errorf("%v: unsupported endpoint type \"%v\"", ErrInvalidEndpoint, parts[0])
+	}
+	addr := strings.TrimPrefix(parts[1], "//")
+	if addr == "" {
+		return NullEndpoint, fmt.Errorf("%v: got empty endpoint from \"%v\"", ErrInvalidEndpoint, s)
+	}
+	if addr, err = NormalizeEndpoint(ty, addr); err != nil {
+		return NullEndpoint, err
+	}
+	return Endpoint{Type: ty, Endpoint: addr}, nil
+}
@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	cases := []struct {
+		raw      string
+		expected Endpoint
+		invalid  bool
+	}{
+		{raw: "tcp:10.240.0.1:3880", expected: Endpoint{Type: TCPBackend, Endpoint: "10.240.0.1:3880"}},
+		{raw: "udp:[2001:db8::1]:3880", expected: Endpoint{Type: UDPBackend, Endpoint: "[2001:db8::1]:3880"}},
+		{raw: "tcp://[2001:DB8:0::1]:3880", expected: Endpoint{Type: TCPBackend, Endpoint: "[2001:db8::1]:3880"}},
+		{raw: "tcp:[::ffff:10.240.0.1]:3880", expected: Endpoint{Type: TCPBackend, Endpoint: "10.240.0.1:3880"}},
+		{raw: "tcp:gateway.example.com:3880", expected: Endpoint{Type: TCPBackend, Endpoint: "gateway.example.com:3880"}},
+		{raw: "ws:[2001:db8::1]:80/utt", expected: Endpoint{Type: WSBackend, Endpoint: "[2001:db8::1]:80/utt"}},
+		{raw: "unix:/var/run/utt/vnet1.sock", expected: Endpoint{Type: UnixBackend, Endpoint: "/var/run/utt/vnet1.sock"}},
+		{raw: "tcp:2001:db8::1:3880", invalid: true}, // not bracketed.
+		{raw: "tcp:[2001:db8::1]", invalid: true},
+		{raw: "tcp:10.240.0.1:", invalid: true},
+		{raw: "tcp:", invalid: true},
+		{raw: "quic:10.240.0.1:3880", invalid: true},
+		{raw: "10.240.0.1", invalid: true},
+	}
+	for _, c := range cases {
+		ep, err := ParseEndpoint(c.raw)
+		if c.invalid {
+			assert.Error(t, err, c.raw)
+			continue
+		}
+		if assert.NoError(t, err, c.raw) {
+			assert.Equal(t, c.expected, ep, c.raw)
+		}
+	}
+}
+
+func TestPublishIPv6(t *testing.T) {
+	c, err := GetCreator("tcp", &config.Backend{
+		PSK: "12345", Type: "tcp",
+		Parameters: map[string]interface{}{"bind": "[::]:3880", "publish": "[2001:DB8::0:1]:3880"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "[2001:db8::1]:3880", c.Publish())
+	}
+	c, err = GetCreator("udp", &config.Backend{
+		PSK: "12345", Type: "udp",
+		Parameters: map[string]interface{}{"bind": "[::1]:3880"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "[::1]:3880", c.Publish())
+	}
+	_, err = GetCreator("tcp", &config.Backend{
+		PSK: "12345", Type: "tcp",
+		Parameters: map[string]interface{}{"bind": "[::]:3880", "publish": "2001:db8::1:3880"},
+	})
+	assert.Error(t, err)
+}
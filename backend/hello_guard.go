@@ -0,0 +1,195 @@
+package backend
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/proto"
+)
+
+const (
+	defaultHelloWindow  = 120
+	defaultBanThreshold = 5
+	defaultBanPeriod    = 600
+
+	authFailureWindow = time.Minute
+	helloCacheTTL     = 4 * time.Minute // how long hellos are remembered if timestamp check is disabled.
+)
+
+var (
+	ErrHelloReplayed = errors.New("replayed hello")
+	ErrHelloExpired  = errors.New("hello timestamp out of window")
+)
+
+// BanInfo describes source banned for authentication failures.
+type BanInfo struct {
+	IP       string
+	Failures int
+	Until    time.Time
+}
+
+// BanLister is implemented by backends which ban sources failing authentication.
+type BanLister interface {
+	Bans() []BanInfo
+}
+
+type authFailure struct {
+	count       int
+	since       time.Time
+	bannedUntil time.Time
+}
+
+// helloGuard rejects replayed hellos and bans sources failing authentication repeatedly.
+type helloGuard struct {
+	lock sync.Mutex
+
+	window       time.Duration // zero if timestamp check disabled, so that legacy peers are accepted.
+	banThreshold int           // zero if banning disabled.
+	banPeriod    time.Duration
+
+	seen      map[[32]byte]time.Time // HMAC --> expiry.
+	failures  map[string]*authFailure
+	lastPurge time.Time
+}
+
+func newHelloGuard(cfg *config.Backend) *helloGuard {
+	g := &helloGuard{
+		seen:     make(map[[32]byte]time.Time),
+		failures: make(map[string]*authFailure),
+	}
+	g.configure(cfg)
+	return g
+}
+
+func (g *helloGuard) configure(cfg *config.Backend) {
+	window, threshold, period := defaultHelloWindow, 0, 0
+	if cfg != nil {
+		if cfg.HelloWindow != nil {
+			window = *cfg.HelloWindow
+		}
+		threshold, period = cfg.BanThreshold, cfg.BanPeriod
+	}
+	if threshold == 0 {
+		threshold = defaultBanThreshold
+	}
+	if period < 1 {
+		period = defaultBanPeriod
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.window, g.banThreshold = 0, 0
+	if window > 0 {
+		g.window = time.Duration(window) * time.Second
+	}
+	if threshold > 0 {
+		g.banThreshold = threshold
+	}
+	g.banPeriod = time.Duration(period) * time.Second
+}
+
+// cacheTTL is how long hellos are remembered.
+func (g *helloGuard) cacheTTL() time.Duration {
+	if g.window > 0 {
+		return g.window * 2
+	}
+	return helloCacheTTL
+}
+
+func (g *helloGuard) purge(now time.Time) {
+	if now.Sub(g.lastPurge) < authFailureWindow {
+		return
+	}
+	for hmac, expiry := range g.seen {
+		if now.After(expiry) {
+			delete(g.seen, hmac)
+		}
+	}
+	for ip, f := range g.failures {
+		if now.After(f.bannedUntil) && now.Sub(f.since) > authFailureWindow {
+			delete(g.failures, ip)
+		}
+	}
+	g.lastPurge = now
+}
+
+// check rejects stale or replayed hello. It should be called after hello is verified.
+func (g *helloGuard) check(hello *proto.Hello, now time.Time) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.purge(now)
+	if g.window > 0 {
+		if skew := now.Sub(hello.Timestamp()); skew > g.window || skew < -g.window {
+			return ErrHelloExpired
+		}
+	}
+	if _, seen := g.seen[hello.HMAC]; seen {
+		return ErrHelloReplayed
+	}
+	g.seen[hello.HMAC] = now.Add(g.cacheTTL())
+	return nil
+}
+
+func sourceIP(addr net.Addr) net.IP {
+	switch v := addr.(type) {
+	case *net.TCPAddr:
+		return v.IP
+	case *net.UDPAddr:
+		return v.IP
+	}
+	return nil
+}
+
+// banned reports whether source is banned.
+func (g *helloGuard) banned(addr net.Addr, now time.Time) bool {
+	ip := sourceIP(addr)
+	if ip == nil {
+		return false
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	f, _ := g.failures[ip.String()]
+	return f != nil && now.Before(f.bannedUntil)
+}
+
+// fail records authentication failure of source. It reports true if source gets banned.
+func (g *helloGuard) fail(addr net.Addr, now time.Time) bool {
+	ip := sourceIP(addr)
+	if ip == nil {
+		return false
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.banThreshold < 1 {
+		return false
+	}
+	key := ip.String()
+	f, _ := g.failures[key]
+	if f == nil || now.Sub(f.since) > authFailureWindow {
+		f = &authFailure{since: now}
+		g.failures[key] = f
+	}
+	if f.count++; f.count < g.banThreshold || now.Before(f.bannedUntil) {
+		return false
+	}
+	f.bannedUntil = now.Add(g.banPeriod)
+	return true
+}
+
+// bans lists banned sources.
+func (g *helloGuard) bans(now time.Time) (bans []BanInfo) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for ip, f := range g.failures {
+		if now.Before(f.bannedUntil) {
+			bans = append(bans, BanInfo{IP: ip, Failures: f.count, Until: f.bannedUntil})
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].IP < bans[j].IP })
+	return
+}
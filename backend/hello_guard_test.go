@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/proto"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestHelloGuard(t *testing.T) {
+	now := time.Now()
+	g := newHelloGuard(&config.Backend{})
+
+	hello := &proto.Hello{}
+	hello.Refresh()
+	hello.Sign([]byte("12345"))
+	assert.NoError(t, g.check(hello, now))
+	assert.Equal(t, ErrHelloReplayed, g.check(hello, now))
+
+	stale := &proto.Hello{}
+	stale.Refresh()
+	stale.Sign([]byte("12345"))
+	assert.Equal(t, ErrHelloExpired, g.check(stale, now.Add(240*time.Second)))
+	window := 300
+	g.configure(&config.Backend{HelloWindow: &window})
+	assert.NoError(t, g.check(stale, now.Add(240*time.Second)))
+
+	// timestamp check can be disabled, so that peers in older versions are accepted.
+	legacy := &proto.Hello{}
+	rand.Read(legacy.IV[:])
+	legacy.Sign([]byte("12345"))
+	assert.Equal(t, ErrHelloExpired, g.check(legacy, now))
+	window = 0
+	g.configure(&config.Backend{HelloWindow: &window})
+	assert.NoError(t, g.check(legacy, now))
+
+	// banning.
+	addr := &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 3880}
+	for i := 1; i < defaultBanThreshold; i++ {
+		assert.False(t, g.fail(addr, now))
+	}
+	assert.False(t, g.banned(addr, now))
+	assert.True(t, g.fail(addr, now))
+	assert.True(t, g.banned(&net.TCPAddr{IP: addr.IP, Port: 3881}, now))
+	assert.False(t, g.banned(addr, now.Add(defaultBanPeriod*time.Second+time.Second)))
+	bans := g.bans(now)
+	if assert.Equal(t, 1, len(bans)) {
+		assert.Equal(t, "192.168.0.1", bans[0].IP)
+		assert.Equal(t, defaultBanThreshold, bans[0].Failures)
+	}
+	// failures out of window are forgotten.
+	other := &net.TCPAddr{IP: net.ParseIP("192.168.0.2"), Port: 3880}
+	for i := 0; i < defaultBanThreshold*2; i++ {
+		assert.False(t, g.fail(other, now.Add(authFailureWindow*time.Duration(i+1)+time.Second)))
+	}
+	// sources without IP are never banned.
+	assert.False(t, g.fail(&net.UnixAddr{Name: "/tmp/utt.sock"}, now))
+
+	g.configure(&config.Backend{BanThreshold: -1})
+	assert.False(t, g.fail(other, now))
+}
+
+func TestTCPBan(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	raw := &config.Backend{PSK: "12345", BanThreshold: 2}
+	b, err := NewTCP(arbiter, nil, &TCPBackendConfig{Bind: "127.0.0.1:39970", raw: raw}, &raw.PSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello := &proto.Hello{}
+	hello.Refresh()
+	hello.Sign([]byte("54321"))
+	for i := 0; i < 2; i++ {
+		var conn net.Conn
+		assert.True(t, waitForBackend(func() bool {
+			conn, err = net.Dial("tcp", "127.0.0.1:39970")
+			return err == nil
+		}))
+		if conn == nil {
+			return
+		}
+		_, err = conn.Write(hello.Encode(nil))
+		assert.NoError(t, err)
+		// denied.
+		conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		_, err = conn.Read(make([]byte, 1))
+		assert.Error(t, err)
+		conn.Close()
+	}
+	bans := b.Bans()
+	if assert.Equal(t, 1, len(bans)) {
+		assert.Equal(t, "127.0.0.1", bans[0].IP)
+	}
+}
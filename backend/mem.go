@@ -0,0 +1,354 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/mux"
+	logging "github.com/sirupsen/logrus"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+const defaultMemInboxSize = 1024
+
+var (
+	ErrMemEndpointExists  = errors.New("mem endpoint already exists")
+	ErrMemEndpointMissing = errors.New("mem endpoint not found")
+)
+
+// MemBackendConfig describes in-memory backend parameters.
+type MemBackendConfig struct {
+	Network  string `json:"network" yaml:"network"`
+	Publish  string `json:"publish" yaml:"publish"`
+	Priority uint32 `json:"priority" yaml:"priority"`
+
+	InboxSize int `json:"inboxSize" yaml:"inboxSize" default:"1024"`
+}
+
+type memCreator struct {
+	cfg MemBackendConfig
+}
+
+func newMemCreator(cfg *config.Backend) (BackendCreator, error) {
+	c := &memCreator{}
+	if cfg.Parameters == nil {
+		return nil, ErrInvalidBackendConfig
+	}
+	// re-parse
+	bin, err := json.Marshal(cfg.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("parse backend config failure (%v)", err)
+	}
+	if err = json.Unmarshal(bin, &c.cfg); err != nil {
+		return nil, fmt.Errorf("parse backend config failure (%v)", err)
+	}
+	if c.cfg.Publish == "" {
+		return nil, ErrInvalidBackendConfig
+	}
+	return c, nil
+}
+
+func (c *memCreator) Type() Type       { return MemBackend }
+func (c *memCreator) Priority() uint32 { return c.cfg.Priority }
+func (c *memCreator) Publish() string  { return c.cfg.Publish }
+func (c *memCreator) New(arbiter *arbit.Arbiter, log *logging.Entry) (Backend, error) {
+	return NewMem(arbiter, log, &c.cfg)
+}
+
+// MemLinkPolicy describes delivery behaviour between two in-memory endpoints.
+type MemLinkPolicy struct {
+	Latency time.Duration // delivery delay.
+	Loss    float64       // probability of dropping a frame, in [0, 1].
+	Blocked bool          // drop all frames.
+}
+
+type memPath struct {
+	from, to string
+}
+
+// MemNetwork connects in-memory backends within one process.
+type MemNetwork struct {
+	lock sync.RWMutex
+
+	endpoints     map[string]*Mem
+	policies      map[memPath]MemLinkPolicy
+	defaultPolicy MemLinkPolicy
+	rand          *rand.Rand
+	randLock      sync.Mutex
+}
+
+var (
+	memNetworksLock sync.Mutex
+	memNetworks     = map[string]*MemNetwork{}
+)
+
+// GetMemNetwork returns in-memory network with specific name. The network will be created if missing.
+func GetMemNetwork(name string) *MemNetwork {
+	memNetworksLock.Lock()
+	defer memNetworksLock.Unlock()
+
+	n, _ := memNetworks[name]
+	if n == nil {
+		n = &MemNetwork{
+			endpoints: make(map[string]*Mem),
+			policies:  make(map[memPath]MemLinkPolicy),
+			rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		}
+		memNetworks[name] = n
+	}
+	return n
+}
+
+// SetDefaultPolicy sets policy for paths without specific policy.
+func (n *MemNetwork) SetDefaultPolicy(p MemLinkPolicy) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.defaultPolicy = p
+}
+
+// SetPolicy sets policy of direction `from` --> `to`.
+func (n *MemNetwork) SetPolicy(from, to string, p MemLinkPolicy) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.policies[memPath{from: from, to: to}] = p
+}
+
+// Partition blocks all traffic between two endpoint groups.
+func (n *MemNetwork) Partition(a, b []string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, from := range a {
+		for _, to := range b {
+			for _, path := range []memPath{{from: from, to: to}, {from: to, to: from}} {
+				p := n.policyOf(path)
+				p.Blocked = true
+				n.policies[path] = p
+			}
+		}
+	}
+}
+
+// Heal removes all specific policies.
+func (n *MemNetwork) Heal() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.policies = make(map[memPath]MemLinkPolicy)
+}
+
+func (n *MemNetwork) policyOf(path memPath) MemLinkPolicy {
+	p, hasPolicy := n.policies[path]
+	if !hasPolicy {
+		return n.defaultPolicy
+	}
+	return p
+}
+
+func (n *MemNetwork) lossHit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	n.randLock.Lock()
+	defer n.randLock.Unlock()
+	return n.rand.Float64() < rate
+}
+
+func (n *MemNetwork) register(m *Mem) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if _, exists := n.endpoints[m.config.Publish]; exists {
+		return ErrMemEndpointExists
+	}
+	n.endpoints[m.config.Publish] = m
+	return nil
+}
+
+func (n *MemNetwork) unregister(m *Mem) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if actual, _ := n.endpoints[m.config.Publish]; actual == m {
+		delete(n.endpoints, m.config.Publish)
+	}
+}
+
+// send queues frame to endpoint. Reference of frame is taken while it's queued.
+func (n *MemNetwork) send(from, to string, frame *mux.FrameBuffer) error {
+	n.lock.RLock()
+	target, _ := n.endpoints[to]
+	policy := n.policyOf(memPath{from: from, to: to})
+	n.lock.RUnlock()
+
+	if target == nil {
+		return ErrConnectionClosed
+	}
+	if policy.Blocked || n.lossHit(policy.Loss) {
+		return nil // lost silently.
+	}
+	return target.enqueue(memFrame{
+		src:       from,
+		payload:   frame.Ref(),
+		deliverAt: time.Now().Add(policy.Latency),
+	})
+}
+
+type memFrame struct {
+	src       string
+	payload   *mux.FrameBuffer
+	deliverAt time.Time
+}
+
+// Mem implements in-memory backend.
+type Mem struct {
+	config  *MemBackendConfig
+	network *MemNetwork
+
+	log   *logging.Entry
+	inbox chan memFrame
+	watch sync.Map
+	links sync.Map // endpoint --> *MemLink
+
+	Arbiter *arbit.Arbiter
+}
+
+// NewMem creates in-memory backend.
+func NewMem(arbiter *arbit.Arbiter, log *logging.Entry, cfg *MemBackendConfig) (m *Mem, err error) {
+	if log == nil {
+		log = logging.WithField("module", "backend_mem")
+	}
+	size := cfg.InboxSize
+	if size < 1 {
+		size = defaultMemInboxSize
+	}
+	m = &Mem{
+		config:  cfg,
+		network: GetMemNetwork(cfg.Network),
+		log:     log,
+		inbox:   make(chan memFrame, size),
+	}
+	if err = m.network.register(m); err != nil {
+		return nil, err
+	}
+	m.Arbiter = arbit.NewWithParent(arbiter)
+	m.Arbiter.Go(func() {
+		<-m.Arbiter.Exit()
+		m.network.unregister(m)
+	})
+	m.Arbiter.Go(m.deliverProc)
+
+	return m, nil
+}
+
+func (m *Mem) enqueue(frame memFrame) error {
+	select {
+	case m.inbox <- frame:
+	default:
+		// queue full. drop like a real network.
+		frame.payload.Release()
+	}
+	return nil
+}
+
+func (m *Mem) deliverProc() {
+	for {
+		var frame memFrame
+		select {
+		case <-m.Arbiter.Exit():
+			return
+		case frame = <-m.inbox:
+		}
+		if wait := time.Until(frame.deliverAt); wait > 0 {
+			select {
+			case <-m.Arbiter.Exit():
+				return
+			case <-time.After(wait):
+			}
+		}
+		m.watch.Range(func(k, v interface{}) bool {
+			if emit, ok := v.(func(Backend, []byte, string)); ok {
+				emit(m, frame.payload.Bytes(), frame.src)
+			}
+			return true
+		})
+		frame.payload.Release()
+	}
+}
+
+// Type returns backend type ID.
+func (m *Mem) Type() Type { return MemBackend }
+
+// Priority returns priority of backend.
+func (m *Mem) Priority() uint32 { return m.config.Priority }
+
+// Publish returns publish endpoint.
+func (m *Mem) Publish() string { return m.config.Publish }
+
+// IP returns bind IP.
+func (m *Mem) IP() net.IP { return nil }
+
+// Shutdown closes backend.
+func (m *Mem) Shutdown() {
+	m.Arbiter.Shutdown()
+	m.Arbiter.Join()
+}
+
+// Watch registers callback to receive packet.
+func (m *Mem) Watch(proc func(Backend, []byte, string)) error {
+	if proc != nil {
+		m.watch.Store(&proc, proc)
+	}
+	return nil
+}
+
+// Connect trys to establish data path to peer.
+func (m *Mem) Connect(endpoint string) (Link, error) {
+	if !m.Arbiter.ShouldRun() {
+		return nil, ErrOperationCanceled
+	}
+	m.network.lock.RLock()
+	_, exists := m.network.endpoints[endpoint]
+	m.network.lock.RUnlock()
+	if !exists {
+		return nil, ErrMemEndpointMissing
+	}
+	if v, ok := m.links.Load(endpoint); ok {
+		return v.(*MemLink), nil
+	}
+	v, _ := m.links.LoadOrStore(endpoint, &MemLink{backend: m, remote: endpoint})
+	return v.(*MemLink), nil
+}
+
+// Reload applies new configuration in place if nothing changed.
+func (m *Mem) Reload(creator BackendCreator) (restarted []string, reloaded bool) {
+	c, isMem := creator.(*memCreator)
+	return nil, isMem && *m.config == c.cfg
+}
+
+// MemLink is data path between two in-memory backends.
+type MemLink struct {
+	backend *Mem
+	remote  string
+}
+
+// Send sends data frame.
+func (l *MemLink) Send(frame []byte) error {
+	fb := mux.NewFrameBuffer(len(frame))
+	defer fb.Release()
+	copy(fb.Bytes(), frame)
+	return l.SendFrame(fb)
+}
+
+// SendFrame sends data frame without copying.
+func (l *MemLink) SendFrame(frame *mux.FrameBuffer) error {
+	if !l.backend.Arbiter.ShouldRun() {
+		return ErrOperationCanceled
+	}
+	return l.backend.network.send(l.backend.config.Publish, l.remote, frame)
+}
+
+// Close terminates link.
+func (l *MemLink) Close() error { return nil }
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestMemBackend(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newMem := func(publish string) Backend {
+		creator, err := GetCreator("mem", &config.Backend{
+			Type: "mem",
+			Parameters: map[string]interface{}{
+				"network": t.Name(),
+				"publish": publish,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, MemBackend, creator.Type())
+		b, err := creator.New(arbiter, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newMem("a"), newMem("b")
+	received := make(chan string, 16)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		assert.Equal(t, "a", src)
+		received <- string(frame)
+	})
+	network := GetMemNetwork(t.Name())
+
+	_, err := a.Connect("c")
+	assert.Equal(t, ErrMemEndpointMissing, err)
+
+	link, err := a.Connect("b")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("deliver", func(t *testing.T) {
+		assert.NoError(t, link.Send([]byte("1")))
+		select {
+		case frame := <-received:
+			assert.Equal(t, "1", frame)
+		case <-time.After(time.Second):
+			t.Fatal("frame not delivered.")
+		}
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		network.SetPolicy("a", "b", MemLinkPolicy{Latency: time.Millisecond * 200})
+		defer network.Heal()
+
+		start := time.Now()
+		assert.NoError(t, link.Send([]byte("2")))
+		select {
+		case frame := <-received:
+			assert.Equal(t, "2", frame)
+			assert.True(t, time.Since(start) >= time.Millisecond*200)
+		case <-time.After(time.Second):
+			t.Fatal("frame not delivered.")
+		}
+	})
+
+	t.Run("loss", func(t *testing.T) {
+		network.SetPolicy("a", "b", MemLinkPolicy{Loss: 1})
+		defer network.Heal()
+
+		assert.NoError(t, link.Send([]byte("3")))
+		select {
+		case <-received:
+			t.Fatal("frame should be lost.")
+		case <-time.After(time.Millisecond * 100):
+		}
+	})
+
+	t.Run("partition", func(t *testing.T) {
+		network.Partition([]string{"a"}, []string{"b"})
+		assert.NoError(t, link.Send([]byte("4")))
+		select {
+		case <-received:
+			t.Fatal("frame should be blocked.")
+		case <-time.After(time.Millisecond * 100):
+		}
+
+		network.Heal()
+		assert.NoError(t, link.Send([]byte("5")))
+		select {
+		case frame := <-received:
+			assert.Equal(t, "5", frame)
+		case <-time.After(time.Second):
+			t.Fatal("frame not delivered.")
+		}
+	})
+
+	b.Shutdown()
+	assert.Equal(t, ErrConnectionClosed, link.Send([]byte("6")))
+}
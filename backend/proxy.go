@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+var (
+	ErrProxyRefused     = errors.New("proxy refused to connect")
+	ErrUnsupportedProxy = errors.New("unsupported proxy")
+)
+
+// socksConnDialer makes SOCKS5 request over established connection to proxy.
+type socksConnDialer interface {
+	DialWithConn(ctx context.Context, c net.Conn, network, address string) (net.Addr, error)
+}
+
+// proxyDialer dials peers through SOCKS5 or HTTP CONNECT proxy.
+// Connection to proxy is returned as it is after tunnel established, so that links
+// work unchanged over it.
+type proxyDialer struct {
+	proxy  *url.URL
+	socks  socksConnDialer // nil for HTTP CONNECT proxy.
+	direct net.Dialer
+}
+
+func (d *proxyDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.direct.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+		conn.SetDeadline(deadline)
+	}
+	if d.socks != nil {
+		_, err = d.socks.DialWithConn(ctx, conn, network, addr)
+	} else {
+		err = httpConnect(conn, d.proxy, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// httpConnect opens tunnel to target over connection to HTTP CONNECT proxy.
+func httpConnect(conn net.Conn, proxyURL *url.URL, target string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v: %v", ErrProxyRefused, resp.Status)
+	}
+	return nil
+}
+
+// streamDialer holds dialer of backend, which could be replaced on reload.
+type streamDialer struct {
+	proxy.ContextDialer
+
+	wss *tls.Config // dials websocket over TLS if not nil.
+}
+
+// newStreamDialer creates dialer of stream connections to peers with proxy settings.
+func newStreamDialer(cfg *TCPBackendConfig) (proxy.ContextDialer, error) {
+	direct, err := newDirectDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Proxy == "" {
+		return direct, nil
+	}
+	proxyURL, err := url.Parse(cfg.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url (%v)", err)
+	}
+	d := &proxyDialer{proxy: proxyURL, direct: *direct}
+	switch proxyURL.Scheme {
+	case "http":
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if user := proxyURL.User; user != nil {
+			password, _ := user.Password()
+			auth = &proxy.Auth{User: user.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, nil)
+		if err != nil {
+			return nil, err
+		}
+		if d.socks, _ = socks.(socksConnDialer); d.socks == nil {
+			return nil, fmt.Errorf("%v: %v", ErrUnsupportedProxy, cfg.Proxy)
+		}
+	default:
+		return nil, fmt.Errorf("%v: %v", ErrUnsupportedProxy, cfg.Proxy)
+	}
+	if len(cfg.ProxyBypass) < 1 {
+		return d, nil
+	}
+	perHost := proxy.NewPerHost(d, direct)
+	for _, bypass := range cfg.ProxyBypass {
+		perHost.AddFromString(bypass)
+	}
+	return perHost, nil
+}
@@ -0,0 +1,174 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+// serveTestSOCKS5Proxy serves minimal SOCKS5 proxy with username/password authentication.
+func serveTestSOCKS5Proxy(t *testing.T, listener net.Listener, connected chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			buf := make([]byte, 512)
+			// methods.
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil || buf[0] != 5 {
+				return
+			}
+			if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil || bytes.IndexByte(buf[:buf[1]], 2) < 0 {
+				conn.Write([]byte{5, 0xFF})
+				return
+			}
+			conn.Write([]byte{5, 2})
+			// username/password.
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+				return
+			}
+			user := make([]byte, buf[1])
+			if _, err := io.ReadFull(conn, user); err != nil {
+				return
+			}
+			if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+				return
+			}
+			password := make([]byte, buf[0])
+			if _, err := io.ReadFull(conn, password); err != nil {
+				return
+			}
+			if string(user) != "user" || string(password) != "pass" {
+				conn.Write([]byte{1, 1})
+				return
+			}
+			conn.Write([]byte{1, 0})
+			// request.
+			if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[1] != 1 {
+				return
+			}
+			var host string
+			switch buf[3] {
+			case 1:
+				if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+					return
+				}
+				host = net.IP(buf[:4]).String()
+			case 3:
+				if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, buf[1:1+buf[0]]); err != nil {
+					return
+				}
+				host = string(buf[1 : 1+buf[0]])
+			default:
+				return
+			}
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+				return
+			}
+			target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer upstream.Close()
+			conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			connected <- target
+			go io.Copy(upstream, conn)
+			io.Copy(conn, upstream)
+		}()
+	}
+}
+
+func TestTCPProxy(t *testing.T) {
+	_, err := GetCreator("tcp", &config.Backend{
+		PSK: "12345", Type: "tcp",
+		Parameters: map[string]interface{}{
+			"bind":  "127.0.0.1:39960",
+			"proxy": "ftp://127.0.0.1:21",
+		},
+	})
+	assert.Error(t, err)
+
+	socksListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socksListener.Close()
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpListener.Close()
+	connected := make(chan string, 4)
+	go serveTestSOCKS5Proxy(t, socksListener, connected)
+	go serveTestConnectProxy(t, httpListener, connected)
+
+	cases := []struct {
+		proxy   string
+		bypass  []string
+		proxied bool
+	}{
+		{proxy: "socks5://user:pass@" + socksListener.Addr().String(), proxied: true},
+		{proxy: "http://user:pass@" + httpListener.Addr().String(), proxied: true},
+		{proxy: "socks5://user:pass@" + socksListener.Addr().String(), bypass: []string{"127.0.0.0/8"}},
+	}
+	for _, c := range cases {
+		arbiter := arbit.New()
+
+		newTCP := func(bind string) *TCP {
+			raw := &config.Backend{PSK: "12345"}
+			cfg := &TCPBackendConfig{Bind: bind, Proxy: c.proxy, ProxyBypass: c.bypass, raw: raw}
+			b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		}
+		a, b := newTCP("127.0.0.1:39960"), newTCP("127.0.0.1:39961")
+
+		received := make(chan []byte, 1)
+		b.Watch(func(_ Backend, frame []byte, src string) {
+			received <- append([]byte(nil), frame...)
+		})
+
+		var link Link
+		assert.True(t, waitForBackend(func() bool {
+			link, err = a.Connect(b.Publish())
+			return err == nil
+		}), "cannot connect to %v via %v", b.Publish(), c.proxy)
+		if link != nil {
+			payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+			assert.NoError(t, link.Send(payload))
+			select {
+			case frame := <-received:
+				assert.True(t, bytes.Equal(payload, frame))
+			case <-time.After(time.Second * 5):
+				t.Fatal("frame not delivered.")
+			}
+		}
+		select {
+		case host := <-connected:
+			assert.True(t, c.proxied, "connection to %v not bypassed.", host)
+			assert.Equal(t, "127.0.0.1:39961", host)
+		default:
+			assert.False(t, c.proxied, "connection not via proxy %v.", c.proxy)
+		}
+
+		arbiter.Shutdown()
+		arbiter.Join()
+	}
+}
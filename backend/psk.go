@@ -0,0 +1,93 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/proto"
+)
+
+// pskRing contains pre-shared keys of backend.
+// Active key signs outgoing handshakes. Incoming handshakes signed by either active key or
+// one of accepted keys are accepted, so that secret can be rotated across nodes in stages.
+type pskRing struct {
+	active   *string
+	accepted []string
+}
+
+func newPSKRing(active *string, raw *config.Backend) *pskRing {
+	r := &pskRing{active: active}
+	if raw != nil {
+		r.accepted = append(r.accepted, raw.AcceptedPSK...)
+	}
+	return r
+}
+
+func (r *pskRing) equal(x *pskRing) bool {
+	if (r.active == nil) != (x.active == nil) || (r.active != nil && *r.active != *x.active) {
+		return false
+	}
+	return len(r.accepted) == len(x.accepted) && (len(r.accepted) == 0 || reflect.DeepEqual(r.accepted, x.accepted))
+}
+
+// pskKeeper holds key ring replaceable in place.
+type pskKeeper struct {
+	ring atomic.Value
+}
+
+func (k *pskKeeper) load() *pskRing {
+	r, _ := k.ring.Load().(*pskRing)
+	if r == nil {
+		return &pskRing{}
+	}
+	return r
+}
+
+// store replaces key ring and reports whether keys changed.
+func (k *pskKeeper) store(r *pskRing) bool {
+	if k.load().equal(r) {
+		return false
+	}
+	k.ring.Store(r)
+	return true
+}
+
+func (r *pskRing) sign(hello *proto.Hello) {
+	if r.active != nil {
+		hello.Sign([]byte(*r.active))
+	} else {
+		hello.Sign(nil)
+	}
+}
+
+// verify finds key signing hello.
+func (r *pskRing) verify(hello *proto.Hello) (psk *string, accepted bool) {
+	if r.active != nil {
+		accepted = hello.Verify([]byte(*r.active))
+	} else {
+		accepted = hello.Verify(nil)
+	}
+	if accepted {
+		return r.active, true
+	}
+	for idx := range r.accepted {
+		if hello.Verify([]byte(r.accepted[idx])) {
+			return &r.accepted[idx], true
+		}
+	}
+	return nil, false
+}
+
+// PSKID returns fingerprint of pre-shared key, which is the first 4 bytes of its SHA-256 digest in hex.
+// It identifies key used by link without disclosing the key.
+func PSKID(psk *string) string {
+	var key []byte
+	if psk != nil {
+		key = []byte(*psk)
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
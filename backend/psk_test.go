@@ -0,0 +1,99 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/proto"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestPSKRing(t *testing.T) {
+	active, old := "new", "old"
+	ring := newPSKRing(&active, &config.Backend{AcceptedPSK: []string{old}})
+
+	hello := &proto.Hello{}
+	hello.Refresh()
+	hello.Sign([]byte(old))
+	psk, accepted := ring.verify(hello)
+	assert.True(t, accepted)
+	assert.Equal(t, old, *psk)
+
+	ring.sign(hello)
+	psk, accepted = ring.verify(hello)
+	assert.True(t, accepted)
+	assert.Equal(t, active, *psk)
+
+	hello.Sign([]byte("unknown"))
+	_, accepted = ring.verify(hello)
+	assert.False(t, accepted)
+
+	assert.Equal(t, "11507a0e", PSKID(&active)) // sha256("new")
+	assert.Equal(t, PSKID(nil), PSKID(new(string)))
+}
+
+func TestTCPPSKRotation(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind, psk string, accepted ...string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: psk, AcceptedPSK: accepted, Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	oldKey, newKey := "old", "new"
+	a, b := newTCP("127.0.0.1:39930", newKey, oldKey), newTCP("127.0.0.1:39931", oldKey)
+	c := newTCP("127.0.0.1:39932", newKey)
+
+	var err error
+	assert.True(t, waitForBackend(func() bool {
+		_, err = b.Connect(a.Publish())
+		return err == nil
+	}), "cannot connect to %v", a.Publish())
+	assert.True(t, waitForBackend(func() bool {
+		links := a.Links()
+		return len(links) == 1 && links[0].PSKID == PSKID(&oldKey)
+	}), "link authenticated by old key not reported.")
+
+	// b doesn't accept new key yet.
+	_, err = c.Connect(b.Publish())
+	assert.Error(t, err)
+
+	raw := &config.Backend{
+		PSK: oldKey, AcceptedPSK: []string{newKey}, Encrypt: b.getConfig().raw.Encrypt, Type: "tcp",
+		Parameters: map[string]interface{}{"bind": "127.0.0.1:39931"},
+	}
+	creator, err := GetCreator("tcp", raw)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, reloaded := b.Reload(creator)
+	assert.True(t, reloaded)
+	assert.True(t, waitForBackend(func() bool {
+		_, err = c.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	assert.True(t, waitForBackend(func() bool {
+		for _, link := range b.Links() {
+			if link.Publish == c.Publish() {
+				return link.PSKID == PSKID(&newKey)
+			}
+		}
+		return false
+	}), "link authenticated by new key not reported.")
+
+	// established link kept.
+	links := a.Links()
+	if assert.Equal(t, 1, len(links)) {
+		assert.Equal(t, b.Publish(), links[0].Publish)
+	}
+}
@@ -0,0 +1,146 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/crossmesh/fabric/proto"
+)
+
+// minimum bucket size, so that any frame could pass.
+const minRateLimitBurst = 65536
+
+// RateLimitConfig describes token bucket limits of egress data frames.
+// Control messages, such as gossip and health probing, are exempt.
+type RateLimitConfig struct {
+	Rate      uint64 `json:"rate" yaml:"rate"`           // bytes per second of whole backend. unlimited if zero.
+	Burst     uint64 `json:"burst" yaml:"burst"`         // bucket size in byte of whole backend. defaults to rate.
+	PeerRate  uint64 `json:"peerRate" yaml:"peerRate"`   // bytes per second to each peer. unlimited if zero.
+	PeerBurst uint64 `json:"peerBurst" yaml:"peerBurst"` // bucket size in byte to each peer. defaults to peer rate.
+}
+
+// tokenBucket shapes traffic by reserving tokens in advance.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64 // tokens per second.
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst uint64) *tokenBucket {
+	if rate < 1 {
+		return nil
+	}
+	if burst < 1 {
+		burst = rate
+	}
+	if burst < minRateLimitBurst {
+		burst = minRateLimitBurst
+	}
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n tokens and returns time to wait before they are available.
+// Nothing is taken if the wait exceeds maxWait.
+func (b *tokenBucket) reserve(now time.Time, n int, maxWait time.Duration) (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		if b.tokens += elapsed.Seconds() * b.rate; b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	left := b.tokens - float64(n)
+	var wait time.Duration
+	if left < 0 {
+		wait = time.Duration(-left / b.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return 0, false
+	}
+	b.tokens = left
+	return wait, true
+}
+
+// cancel gives back reserved tokens.
+func (b *tokenBucket) cancel(n int) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	if b.tokens += float64(n); b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lock.Unlock()
+}
+
+// egressLimiter limits egress data frames per backend and per peer.
+type egressLimiter struct {
+	cfg     RateLimitConfig
+	backend *tokenBucket
+	peers   sync.Map // peer --> *tokenBucket
+}
+
+func newEgressLimiter(cfg *RateLimitConfig) *egressLimiter {
+	if cfg == nil || (cfg.Rate < 1 && cfg.PeerRate < 1) {
+		return nil
+	}
+	return &egressLimiter{
+		cfg:     *cfg,
+		backend: newTokenBucket(cfg.Rate, cfg.Burst),
+	}
+}
+
+func (l *egressLimiter) getPeerBucket(peer string) *tokenBucket {
+	if l.cfg.PeerRate < 1 {
+		return nil
+	}
+	if v, ok := l.peers.Load(peer); ok {
+		return v.(*tokenBucket)
+	}
+	v, _ := l.peers.LoadOrStore(peer, newTokenBucket(l.cfg.PeerRate, l.cfg.PeerBurst))
+	return v.(*tokenBucket)
+}
+
+// isControlFrame reports whether frame is exempted from shaping.
+// Relayed messages are shaped as data, since they mostly carry frames of others.
+func isControlFrame(frame []byte) bool {
+	typeID, _ := proto.UnpackProtocolMessageHeader(frame)
+	return typeID != proto.MsgTypeRawFrame && typeID != proto.MsgTypeRelay
+}
+
+// wait blocks until frame to peer conforms to limits.
+// It reports false if frame can't be sent within timeout.
+func (l *egressLimiter) wait(peer string, frame []byte, timeout time.Duration) bool {
+	if l == nil || isControlFrame(frame) {
+		return true
+	}
+	now, peerBucket := time.Now(), l.getPeerBucket(peer)
+	peerWait, ok := peerBucket.reserve(now, len(frame), timeout)
+	if !ok {
+		return false
+	}
+	backendWait, ok := l.backend.reserve(now, len(frame), timeout)
+	if !ok {
+		peerBucket.cancel(len(frame))
+		return false
+	}
+	if backendWait < peerWait {
+		backendWait = peerWait
+	}
+	if backendWait > 0 {
+		time.Sleep(backendWait)
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFrame(typeID uint16, size int) []byte {
+	frame := make([]byte, size)
+	proto.PackProtocolMessageHeader(frame, typeID)
+	return frame
+}
+
+func TestEgressLimiter(t *testing.T) {
+	assert.Nil(t, newEgressLimiter(nil))
+	assert.Nil(t, newEgressLimiter(&RateLimitConfig{Burst: 1024}))
+
+	timeout := time.Millisecond * 50
+
+	// per backend.
+	l := newEgressLimiter(&RateLimitConfig{Rate: minRateLimitBurst})
+	assert.True(t, l.wait("a", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst), timeout))
+	assert.False(t, l.wait("b", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst/2), timeout))
+	// control messages are exempt.
+	assert.True(t, l.wait("a", newTestFrame(proto.MsgTypeGossip, 1024), timeout))
+	assert.True(t, l.wait("a", newTestFrame(proto.MsgTypePing, 1024), timeout))
+	// relayed messages are shaped.
+	assert.False(t, l.wait("a", newTestFrame(proto.MsgTypeRelay, minRateLimitBurst/2), timeout))
+	// shaped.
+	start := time.Now()
+	assert.True(t, l.wait("a", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst/4), time.Second))
+	assert.True(t, time.Since(start) > time.Millisecond*100)
+
+	// per peer.
+	l = newEgressLimiter(&RateLimitConfig{PeerRate: minRateLimitBurst})
+	assert.True(t, l.wait("a", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst), timeout))
+	assert.False(t, l.wait("a", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst/2), timeout))
+	assert.True(t, l.wait("b", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst), timeout))
+
+	// rejected frames take no token of peer.
+	l = newEgressLimiter(&RateLimitConfig{Rate: minRateLimitBurst, PeerRate: minRateLimitBurst * 2})
+	assert.True(t, l.wait("a", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst), timeout))
+	assert.False(t, l.wait("b", newTestFrame(proto.MsgTypeRawFrame, minRateLimitBurst), timeout))
+	assert.Equal(t, float64(minRateLimitBurst*2), l.getPeerBucket("b").tokens)
+}
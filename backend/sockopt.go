@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+var ErrSocketOptionUnsupported = errors.New("socket option not supported on this platform")
+
+// socketOptions places underlay sockets for policy routing.
+type socketOptions struct {
+	device string // SO_BINDTODEVICE.
+	mark   uint32 // SO_MARK.
+	tos    int    // IP_TOS or IPV6_TCLASS.
+
+	reusePort bool // SO_REUSEADDR and SO_REUSEPORT.
+}
+
+func (c *TCPBackendConfig) socketOptions() (*socketOptions, error) {
+	if c.BindDevice == "" && c.Mark == 0 && c.DSCP == 0 {
+		return nil, nil
+	}
+	if c.DSCP > 63 {
+		return nil, fmt.Errorf("invalid DSCP value %v", c.DSCP)
+	}
+	opts := &socketOptions{
+		device: c.BindDevice,
+		mark:   c.Mark,
+		tos:    int(c.DSCP) << 2,
+	}
+	if err := opts.supported(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// checkHolePunch validates hole punching option. Punching connections share bind port with listener,
+// which needs SO_REUSEPORT.
+func (c *TCPBackendConfig) checkHolePunch(network string) error {
+	if !c.HolePunch || network != "tcp" {
+		return nil
+	}
+	if err := (&socketOptions{reusePort: true}).supported(); err != nil {
+		return fmt.Errorf("hole punching unavailable (%v)", err)
+	}
+	return nil
+}
+
+// dialSource returns local address of outgoing connections. Port is not allowed, since
+// connections to the same peer, such as stripes, can't share source port without SO_REUSEPORT.
+func (c *TCPBackendConfig) dialSource() (*net.TCPAddr, error) {
+	if c.DialSource == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(c.DialSource); ip != nil {
+		return &net.TCPAddr{IP: ip}, nil
+	}
+	host, port, err := net.SplitHostPort(c.DialSource)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dial source \"%v\" (%v)", c.DialSource, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid dial source \"%v\"", c.DialSource)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dial source \"%v\" (%v)", c.DialSource, err)
+	}
+	if portNum != 0 {
+		return nil, fmt.Errorf("invalid dial source \"%v\" (source port should be left to system)", c.DialSource)
+	}
+	return &net.TCPAddr{IP: ip}, nil
+}
+
+// newDirectDialer creates dialer placing connections as configured.
+func newDirectDialer(cfg *TCPBackendConfig) (*net.Dialer, error) {
+	opts, err := cfg.socketOptions()
+	if err != nil {
+		return nil, err
+	}
+	source, err := cfg.dialSource()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{}
+	if source != nil {
+		dialer.LocalAddr = source
+	}
+	if opts != nil {
+		dialer.Control = opts.control
+	}
+	return dialer, nil
+}
+
+// withReusePort returns options sharing port among sockets.
+func (o *socketOptions) withReusePort() (*socketOptions, error) {
+	opts := &socketOptions{}
+	if o != nil {
+		*opts = *o
+	}
+	opts.reusePort = true
+	if err := opts.supported(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// newPunchDialer creates dialer of punching connections, which start from bind address.
+func (t *TCP) newPunchDialer() (*net.Dialer, error) {
+	opts, err := t.getConfig().socketOptions()
+	if err != nil {
+		return nil, err
+	}
+	if opts, err = opts.withReusePort(); err != nil {
+		return nil, err
+	}
+	return &net.Dialer{LocalAddr: t.getBind(), Control: opts.control}, nil
+}
+
+// listenConfig returns listener configuration placing listening sockets as configured.
+func (t *TCP) listenConfig() (*net.ListenConfig, error) {
+	cfg := t.getConfig()
+	opts, err := cfg.socketOptions()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HolePunch && t.network == "tcp" {
+		if opts, err = opts.withReusePort(); err != nil {
+			return nil, err
+		}
+	}
+	lc := &net.ListenConfig{}
+	if opts != nil {
+		lc.Control = opts.control
+	}
+	return lc, nil
+}
+
+func (t *TCP) listenTCP(addr *net.TCPAddr) (streamListener, error) {
+	lc, err := t.listenConfig()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := lc.Listen(context.Background(), "tcp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	return listener.(*net.TCPListener), nil
+}
@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+package backend
+
+import (
+	"strings"
+	"syscall"
+)
+
+func (o *socketOptions) supported() error { return nil }
+
+func (o *socketOptions) control(network, address string, c syscall.RawConn) (err error) {
+	if cerr := c.Control(func(fd uintptr) {
+		if o.reusePort {
+			if err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+				return
+			}
+			if err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1); err != nil {
+				return
+			}
+		}
+		if o.device != "" {
+			if err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, o.device); err != nil {
+				return
+			}
+		}
+		if o.mark != 0 {
+			if err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, int(o.mark)); err != nil {
+				return
+			}
+		}
+		if o.tos != 0 {
+			if strings.HasSuffix(network, "6") {
+				err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, o.tos)
+			} else {
+				err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, o.tos)
+			}
+		}
+	}); cerr != nil {
+		return cerr
+	}
+	return
+}
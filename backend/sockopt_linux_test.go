@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestSocketOptions(t *testing.T) {
+	for _, params := range []map[string]interface{}{
+		{"bind": "127.0.0.1:39980", "dscp": 64},
+		{"bind": "127.0.0.1:39980", "dialSource": "localhost"},
+		{"bind": "127.0.0.1:39980", "dialSource": "127.0.0.1:http"},
+		{"bind": "127.0.0.1:39980", "dialSource": "127.0.0.1:3880"},
+	} {
+		_, err := GetCreator("tcp", &config.Backend{PSK: "12345", Type: "tcp", Parameters: params})
+		assert.Error(t, err)
+	}
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind string) *TCP {
+		raw := &config.Backend{PSK: "12345"}
+		cfg := &TCPBackendConfig{Bind: bind, DSCP: 46, DialSource: "127.0.0.2", raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newTCP("127.0.0.1:39980"), newTCP("127.0.0.1:39981")
+
+	var (
+		link Link
+		err  error
+	)
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+	local := link.(*TCPLink).conn.LocalAddr().(*net.TCPAddr)
+	assert.Equal(t, "127.0.0.2", local.IP.String())
+
+	tcpConn, _ := tcpConnOf(link.(*TCPLink).conn)
+	raw, err := tcpConn.SyscallConn()
+	if !assert.NoError(t, err) {
+		return
+	}
+	var tos int
+	raw.Control(func(fd uintptr) {
+		tos, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 46<<2, tos)
+}
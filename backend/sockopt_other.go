@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package backend
+
+import "syscall"
+
+func (o *socketOptions) supported() error { return ErrSocketOptionUnsupported }
+
+func (o *socketOptions) control(network, address string, c syscall.RawConn) error {
+	return ErrSocketOptionUnsupported
+}
@@ -0,0 +1,7 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le
+
+package backend
+
+// soReusePort is SO_REUSEPORT, which syscall package lacks.
+const soReusePort = 0xf
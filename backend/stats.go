@@ -0,0 +1,70 @@
+package backend
+
+import "sync/atomic"
+
+// LinkStats contains traffic and error statistics of link.
+type LinkStats struct {
+	FramesSent        uint64 // frames sent.
+	BytesSent         uint64 // bytes of frames sent.
+	FramesReceived    uint64 // frames received.
+	BytesReceived     uint64 // bytes of frames received.
+	SendTimeouts      uint64 // frames dropped for sending timeout.
+	DemuxErrors       uint64 // corrupted frames or streams received.
+	HandshakeFailures uint64 // failed attempts to establish link.
+	Reconnects        uint64 // times link is re-established.
+}
+
+// Add accumulates statistics of another link.
+func (s *LinkStats) Add(r *LinkStats) {
+	s.FramesSent += r.FramesSent
+	s.BytesSent += r.BytesSent
+	s.FramesReceived += r.FramesReceived
+	s.BytesReceived += r.BytesReceived
+	s.SendTimeouts += r.SendTimeouts
+	s.DemuxErrors += r.DemuxErrors
+	s.HandshakeFailures += r.HandshakeFailures
+	s.Reconnects += r.Reconnects
+}
+
+// linkCounters counts statistics of link. It outlives connections of link.
+type linkCounters struct {
+	stats       LinkStats
+	established uint64 // times link is established.
+}
+
+// newLinkCounters allocates counters. counters should be allocated alone to keep
+// 64-bit alignment required by atomic operations on 32-bit platforms.
+func newLinkCounters() *linkCounters { return &linkCounters{} }
+
+func (c *linkCounters) sent(size int) {
+	atomic.AddUint64(&c.stats.FramesSent, 1)
+	atomic.AddUint64(&c.stats.BytesSent, uint64(size))
+}
+
+func (c *linkCounters) received(size int) {
+	atomic.AddUint64(&c.stats.FramesReceived, 1)
+	atomic.AddUint64(&c.stats.BytesReceived, uint64(size))
+}
+
+func (c *linkCounters) sendTimeout()      { atomic.AddUint64(&c.stats.SendTimeouts, 1) }
+func (c *linkCounters) demuxError()       { atomic.AddUint64(&c.stats.DemuxErrors, 1) }
+func (c *linkCounters) handshakeFailure() { atomic.AddUint64(&c.stats.HandshakeFailures, 1) }
+
+func (c *linkCounters) connected() {
+	if atomic.AddUint64(&c.established, 1) > 1 {
+		atomic.AddUint64(&c.stats.Reconnects, 1)
+	}
+}
+
+// Stats returns snapshot of statistics.
+func (c *linkCounters) Stats() (s LinkStats) {
+	s.FramesSent = atomic.LoadUint64(&c.stats.FramesSent)
+	s.BytesSent = atomic.LoadUint64(&c.stats.BytesSent)
+	s.FramesReceived = atomic.LoadUint64(&c.stats.FramesReceived)
+	s.BytesReceived = atomic.LoadUint64(&c.stats.BytesReceived)
+	s.SendTimeouts = atomic.LoadUint64(&c.stats.SendTimeouts)
+	s.DemuxErrors = atomic.LoadUint64(&c.stats.DemuxErrors)
+	s.HandshakeFailures = atomic.LoadUint64(&c.stats.HandshakeFailures)
+	s.Reconnects = atomic.LoadUint64(&c.stats.Reconnects)
+	return
+}
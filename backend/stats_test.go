@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestLinkStats(t *testing.T) {
+	s := LinkStats{FramesSent: 1, BytesSent: 10, Reconnects: 1}
+	s.Add(&LinkStats{FramesSent: 2, BytesSent: 20, DemuxErrors: 3})
+	assert.Equal(t, LinkStats{FramesSent: 3, BytesSent: 30, DemuxErrors: 3, Reconnects: 1}, s)
+
+	c := newLinkCounters()
+	c.connected()
+	c.sent(5)
+	c.received(7)
+	assert.Equal(t, LinkStats{FramesSent: 1, BytesSent: 5, FramesReceived: 1, BytesReceived: 7}, c.Stats())
+	c.connected()
+	assert.Equal(t, uint64(1), c.Stats().Reconnects)
+}
+
+func TestLinkStatsCounting(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCPWithPSK := func(bind, psk string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: psk, Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	newTCP := func(bind string) *TCP { return newTCPWithPSK(bind, "12345") }
+
+	payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+	test := func(t *testing.T, a, b Backend) {
+		received := make(chan struct{}, 16)
+		b.Watch(func(_ Backend, frame []byte, src string) { received <- struct{}{} })
+
+		var (
+			link Link
+			err  error
+		)
+		assert.True(t, waitForBackend(func() bool {
+			link, err = a.Connect(b.Publish())
+			return err == nil
+		}), "cannot connect to %v", b.Publish())
+		if link == nil {
+			return
+		}
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, link.Send(payload))
+			select {
+			case <-received:
+			case <-time.After(time.Second * 5):
+				t.Fatalf("frame %v not delivered.", i)
+			}
+		}
+
+		links := a.(LinkLister).Links()
+		if assert.Equal(t, 1, len(links)) {
+			assert.Equal(t, uint64(4), links[0].Stats.FramesSent)
+			assert.Equal(t, uint64(4*len(payload)), links[0].Stats.BytesSent)
+			assert.Equal(t, uint64(0), links[0].Stats.Reconnects)
+		}
+		links = b.(LinkLister).Links()
+		if assert.Equal(t, 1, len(links)) {
+			assert.Equal(t, uint64(4), links[0].Stats.FramesReceived)
+			assert.Equal(t, uint64(4*len(payload)), links[0].Stats.BytesReceived)
+		}
+	}
+
+	t.Run("tcp", func(t *testing.T) {
+		a, b := newTCP("127.0.0.1:39869"), newTCP("127.0.0.1:39878")
+		test(t, a, b)
+
+		// counters survive reconnection.
+		link, err := a.Connect(b.Publish())
+		if !assert.NoError(t, err) {
+			return
+		}
+		link.Close()
+		assert.True(t, waitForBackend(func() bool {
+			_, err = a.Connect(b.Publish())
+			return err == nil
+		}), "cannot reconnect to %v", b.Publish())
+		links := a.Links()
+		if assert.Equal(t, 1, len(links)) {
+			assert.Equal(t, uint64(4), links[0].Stats.FramesSent)
+			assert.Equal(t, uint64(1), links[0].Stats.Reconnects)
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		test(t, newTestUDP(t, arbiter, "127.0.0.1:39879", true), newTestUDP(t, arbiter, "127.0.0.1:39869", true))
+	})
+
+	t.Run("unconnected", func(t *testing.T) {
+		a, b := newTCP("127.0.0.1:39858"), newTCPWithPSK("127.0.0.1:39859", "54321")
+		assert.True(t, waitForBackend(func() bool {
+			a.Connect(b.Publish())
+			return a.LinkStats()[b.Publish()].HandshakeFailures > 0
+		}), "handshake failure to %v not counted", b.Publish())
+		assert.Equal(t, 0, len(a.Links()))
+	})
+}
@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+
+	"github.com/crossmesh/fabric/mux"
+	"github.com/crossmesh/fabric/proto"
+)
+
+const (
+	CipherAESGCM           = "aes-gcm"
+	CipherChaCha20Poly1305 = "chacha20-poly1305"
+)
+
+var ErrUnknownCipher = errors.New("unknown cipher suite")
+
+// connectVersion returns connecting protocol version of configured cipher suite.
+func (c *TCPBackendConfig) connectVersion() (uint8, error) {
+	switch c.Cipher {
+	case "", CipherAESGCM:
+		return proto.ConnectAES256GCM, nil
+	case CipherChaCha20Poly1305:
+		return proto.ConnectChaCha20Poly1305, nil
+	}
+	return 0, fmt.Errorf("%v: %v", ErrUnknownCipher, c.Cipher)
+}
+
+func isEncryptedConnectVersion(version uint8) bool {
+	return version == proto.ConnectAES256GCM || version == proto.ConnectChaCha20Poly1305
+}
+
+// resetChaCha20Poly1305 replaces ChaCha20-Poly1305 muxer and demuxer over current writer.
+func (l *TCPLink) resetChaCha20Poly1305(key []byte, nonce []byte) (err error) {
+	l.crypt = nil
+	if l.muxer, err = mux.NewChaCha20StreamMuxer(l.w, key, nonce); err != nil {
+		return err
+	}
+	if l.demuxer, err = mux.NewChaCha20StreamDemuxer(key, nonce); err != nil {
+		return err
+	}
+	return nil
+}
+
+// resetRecordCipher replaces muxer and demuxer with record format of cipher suite of connecting protocol
+// version. Records are sealed by at most maxConcurrency workers at once.
+func (l *TCPLink) resetRecordCipher(version uint8, key []byte, nonce []byte, client bool) (err error) {
+	workers := int(l.backend.getRoutinesCount())
+	switch version {
+	case proto.ConnectAES256GCM:
+		if l.crypt, err = aes.NewCipher(key); err != nil {
+			return err
+		}
+		if l.muxer, err = mux.NewGCMRecordMuxer(l.w, l.crypt, nonce, client, workers); err != nil {
+			return err
+		}
+		l.demuxer, err = mux.NewGCMRecordDemuxer(l.crypt, nonce, client)
+		return err
+
+	case proto.ConnectChaCha20Poly1305:
+		l.crypt = nil
+		if l.muxer, err = mux.NewChaCha20RecordMuxer(l.w, key, nonce, client, workers); err != nil {
+			return err
+		}
+		l.demuxer, err = mux.NewChaCha20RecordDemuxer(key, nonce, client)
+		return err
+	}
+	return fmt.Errorf("invalid connecting protocol version %v", version)
+}
+
+// resetCipher replaces muxer and demuxer with cipher suite of connecting protocol version.
+func (l *TCPLink) resetCipher(version uint8, key []byte, nonce []byte) error {
+	switch version {
+	case proto.ConnectAES256GCM:
+		return l.resetAESGCM(key, nonce)
+	case proto.ConnectChaCha20Poly1305:
+		return l.resetChaCha20Poly1305(key, nonce)
+	}
+	return fmt.Errorf("invalid connecting protocol version %v", version)
+}
@@ -0,0 +1,138 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/mux"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestTCPCipher(t *testing.T) {
+	_, err := GetCreator("tcp", &config.Backend{
+		PSK: "12345", Type: "tcp",
+		Parameters: map[string]interface{}{
+			"bind":   "127.0.0.1:39920",
+			"cipher": "rc4",
+		},
+	})
+	assert.Error(t, err)
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind, cipher string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, Cipher: cipher, RekeyBytes: 64, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newTCP("127.0.0.1:39920", CipherChaCha20Poly1305), newTCP("127.0.0.1:39921", CipherAESGCM)
+
+	received := make(chan []byte, 64)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- append([]byte(nil), frame...)
+	})
+
+	var link Link
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+	_, isChaCha20 := link.(*TCPLink).muxer.(*mux.ChaCha20RecordMuxer)
+	assert.True(t, isChaCha20)
+
+	// across rekeying.
+	for i := 0; i < 16; i++ {
+		payload := bytes.Repeat([]byte{byte(i)}, 30)
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatalf("frame %v not delivered.", i)
+		}
+	}
+	_, isChaCha20 = b.getLink(a.Publish()).demuxer.(*mux.ChaCha20RecordDemuxer)
+	assert.True(t, isChaCha20)
+}
+
+func TestTCPParallelRecords(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, Cipher: CipherAESGCM, RekeyBytes: 256, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newTCP("127.0.0.1:39852"), newTCP("127.0.0.1:39853")
+
+	const senders, frames = 4, 32
+	received := make(chan string, senders*frames)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- string(frame)
+	})
+
+	var (
+		link Link
+		err  error
+	)
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+	_, isRecord := link.(*TCPLink).muxer.(*mux.GCMRecordMuxer)
+	assert.True(t, isRecord)
+
+	// concurrent senders across rekeying.
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < frames; j++ {
+				assert.NoError(t, link.Send([]byte(fmt.Sprintf("frame-%v-%v", i, j))))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	delivered := make(map[string]struct{})
+	for len(delivered) < senders*frames {
+		select {
+		case frame := <-received:
+			delivered[frame] = struct{}{}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%v of %v frames delivered.", len(delivered), senders*frames)
+		}
+	}
+	_, isRecord = b.getLink(a.Publish()).demuxer.(*mux.GCMRecordDemuxer)
+	assert.True(t, isRecord)
+}
@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/crossmesh/fabric/mux"
+)
+
+const (
+	CompressionNone   = "none"
+	CompressionSnappy = "snappy"
+)
+
+var ErrUnknownCompression = errors.New("unknown compression algorithm")
+
+// compress reports whether payload compression should be requested to peers.
+func (c *TCPBackendConfig) compress() (bool, error) {
+	switch c.Compression {
+	case "", CompressionNone:
+		return false, nil
+	case CompressionSnappy:
+		return true, nil
+	}
+	return false, fmt.Errorf("%v: %v", ErrUnknownCompression, c.Compression)
+}
+
+// enableCompression makes link compress frames before they are sealed.
+func (l *TCPLink) enableCompression() {
+	l.compressor = mux.NewCompressor()
+}
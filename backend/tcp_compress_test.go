@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestTCPCompression(t *testing.T) {
+	_, err := GetCreator("tcp", &config.Backend{
+		PSK: "12345", Type: "tcp",
+		Parameters: map[string]interface{}{
+			"bind":        "127.0.0.1:39950",
+			"compression": "gzip",
+		},
+	})
+	assert.Error(t, err)
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind, compression string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, Compression: compression, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newTCP("127.0.0.1:39950", CompressionSnappy), newTCP("127.0.0.1:39951", "")
+
+	received := make(chan []byte, 64)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- append([]byte(nil), frame...)
+	})
+
+	var link Link
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+
+	for i := 0; i < 16; i++ {
+		payload := bytes.Repeat([]byte{byte(i)}, 30*(i+1))
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatalf("frame %v not delivered.", i)
+		}
+	}
+
+	links := a.Links()
+	if assert.Equal(t, 1, len(links)) {
+		assert.Equal(t, CompressionSnappy, links[0].Compression)
+		assert.Equal(t, uint64(16), links[0].CompressStats.Frames)
+		assert.True(t, links[0].CompressStats.Ratio() < 1)
+	}
+	links = b.Links()
+	if assert.Equal(t, 1, len(links)) {
+		assert.Equal(t, CompressionSnappy, links[0].Compression)
+	}
+}
@@ -2,28 +2,95 @@ package backend
 
 import (
 	"context"
+	"crypto/ecdh"
+	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
 	"io"
 	"net"
 	"time"
 
+	"github.com/crossmesh/fabric/cmd/version"
 	"github.com/crossmesh/fabric/proto"
 	logging "github.com/sirupsen/logrus"
 )
 
+// helloSessionKey derives session key from hello message and pre-shared key.
+func helloSessionKey(hello *proto.Hello, psk *string) [32]byte {
+	buf := make([]byte, 0, len(hello.Lead)+len(hello.HMAC)+64)
+	buf = append(buf, hello.Lead...)
+	if psk != nil {
+		buf = append(buf, []byte(*psk)...)
+	}
+	buf = append(buf, hello.HMAC[:]...)
+	return sha256.Sum256(buf)
+}
+
+// newHandshakeKey generates ephemeral key for key exchange.
+func newHandshakeKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// exchangeSessionKey derives forward-secret session key.
+// Public keys are carried by Welcome and Connect sealed with PSK session key, so that
+// the exchange is authenticated by pre-shared key.
+func exchangeSessionKey(base [32]byte, private *ecdh.PrivateKey, peer []byte, welcomeKey, connectKey []byte) (key [32]byte, err error) {
+	public, err := ecdh.X25519().NewPublicKey(peer)
+	if err != nil {
+		return key, err
+	}
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return key, err
+	}
+	h := sha256.New()
+	h.Write([]byte("kx#"))
+	h.Write(base[:])
+	h.Write(shared)
+	h.Write(welcomeKey)
+	h.Write(connectKey)
+	copy(key[:], h.Sum(nil))
+	return
+}
+
+// authFailed records authentication failure of source.
+func (t *TCP) authFailed(log *logging.Entry, addr net.Addr) {
+	if t.guard.fail(addr, time.Now()) {
+		log.Warnf("%v banned for repeated authentication failures.", addr)
+	}
+}
+
 func (t *TCP) handshakeConnect(log *logging.Entry, connID uint32, adaptedConn net.Conn) (accepted bool, err error) {
+	link, connectReq, err := t.acceptHandshake(log, adaptedConn)
+	if err != nil || link == nil {
+		return false, err
+	}
+	return t.acceptTCPLink(log, link, connectReq)
+}
+
+// acceptHandshake authenticates connecting peer. Nil link is returned if denied.
+func (t *TCP) acceptHandshake(log *logging.Entry, adaptedConn net.Conn) (link *TCPLink, connectReq *proto.Connect, err error) {
 	buf := make([]byte, defaultBufferSize)
 
-	conn, isTCPConn := adaptedConn.(*net.TCPConn)
-	if !isTCPConn {
-		log.Error("got non-tcp connection. rejected.")
-		return false, nil
+	conn, isStreamConn := adaptedConn.(streamConn)
+	if !isStreamConn {
+		log.Error("got non-stream connection. rejected.")
+		return nil, nil, nil
+	}
+	if t.guard.banned(conn.RemoteAddr(), time.Now()) {
+		log.Debug("deined for banned source.")
+		return nil, nil, nil
 	}
 	// handshake should be finished in 20 seconds.
 	if err := conn.SetDeadline(time.Now().Add(time.Second * 20)); err != nil {
 		log.Error("conn.SetDeadline() failure: ", err)
-		return false, nil
+		return nil, nil, nil
+	}
+	if t.tlsEnabled() {
+		if conn, err = t.tlsServer(conn); err != nil {
+			log.Info("deined for TLS handshake failure: ", err)
+			return nil, nil, nil
+		}
 	}
 
 	// wait for hello.
@@ -38,72 +105,81 @@ func (t *TCP) handshakeConnect(log *logging.Entry, connID uint32, adaptedConn ne
 		if err != nil {
 			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
 				log.Info("deny due to inactivity.")
-				return false, nil
+				return nil, nil, nil
 			}
 			if err == io.EOF {
 				log.Info("connection closed by client.")
-				return false, nil
+				return nil, nil, nil
 			}
-			return false, err
+			return nil, nil, err
 		}
 		read += newRead
 	}
 	if !t.Arbiter.ShouldRun() {
-		return false, nil
+		return nil, nil, nil
 	}
 	if err := hello.Decode(buf[:read]); err != nil {
 		if err == proto.ErrInvalidPacket {
-			return false, nil
+			return nil, nil, nil
 		}
-		return false, err
+		return nil, nil, err
 	}
 	buf = buf[:0]
-	if t.psk != nil {
-		accepted = hello.Verify([]byte(*t.psk))
-	} else {
-		accepted = hello.Verify(nil)
-	}
+	psk, accepted := t.keys.load().verify(&hello)
 	if !accepted {
 		log.Info("deined for authentication failure.")
-		return accepted, nil
+		t.authFailed(log, conn.RemoteAddr())
+		return nil, nil, nil
+	}
+	if err = t.guard.check(&hello, time.Now()); err != nil {
+		log.Warn("deined for authentication failure: ", err)
+		t.authFailed(log, conn.RemoteAddr())
+		return nil, nil, nil
 	}
 	log.Debug("authentication success.")
 
 	// init cipher.
-	link := newTCPLink(t)
+	link = newTCPLink(t)
 	link.conn = conn
-	buf = buf[:0]
-	buf = append(buf, hello.Lead...)
-	if t.psk != nil {
-		buf = append(buf, []byte(*t.psk)...)
-	}
-	buf = append(buf, hello.HMAC[:]...)
-	key := sha256.Sum256(buf)
+	link.pskID = PSKID(psk)
+	key := helloSessionKey(&hello, psk)
 	if err = link.InitializeAESGCM(key[:], hello.IV[:]); err != nil {
 		log.Error("cipher initializion failure: ", err)
-		return false, err
+		return nil, nil, err
 	}
 
 	// welcome
 	welcome := proto.Welcome{
 		Welcome:  true,
-		Identity: t.config.Publish,
+		Identity: t.getConfig().Publish,
 	}
 	if welcome.Identity == "" {
 		err = fmt.Errorf("empty publish endpoint")
 		log.Error(err)
-		return false, err
+		return nil, nil, err
 	}
+	kxKey, err := newHandshakeKey()
+	if err != nil {
+		log.Error("generate ephemeral key failure: ", err)
+		return nil, nil, err
+	}
+	welcome.Features.Enable(version.LinkKeyExchange)
+	welcome.Features.Enable(version.LinkRekey)
+	welcome.Features.Enable(version.LinkChaCha20Poly1305)
+	welcome.Features.Enable(version.LinkStriping)
+	welcome.Features.Enable(version.LinkCompression)
+	welcome.Features.Enable(version.LinkLengthFraming)
+	welcome.Features.Enable(version.LinkParallelRecords)
+	welcome.PublicKey = kxKey.PublicKey().Bytes()
 	welcome.EncodeMessage("ok")
 	buf = welcome.Encode(buf[:0])
 	if _, err = link.muxer.Mux(buf); err != nil {
 		log.Error("send welcome failure: ", err)
-		return false, err
+		return nil, nil, err
 	}
 
 	// wait for connect
 	log.Debug("negotiate peering information.")
-	var connectReq *proto.Connect
 	if rerr := link.read(func(frame []byte) bool {
 		connectReq = &proto.Connect{}
 		if err = connectReq.Decode(frame); err != nil {
@@ -113,21 +189,55 @@ func (t *TCP) handshakeConnect(log *logging.Entry, connID uint32, adaptedConn ne
 	}); rerr != nil {
 		if nerr, ok := rerr.(net.Error); ok && nerr.Timeout() {
 			log.Info("deny due to inactivity.")
-			return false, nil
+			return nil, nil, nil
 		}
 		if rerr == io.EOF {
 			log.Info("connection closed by foreign peer.")
-			return false, nil
+			return nil, nil, nil
 		}
 	}
 	if !t.Arbiter.ShouldRun() || err != nil || connectReq == nil {
-		return false, err
+		return nil, nil, err
+	}
+	// muxer is switched before link is published.
+	if !t.acceptConnectVersion(log, link, connectReq) {
+		return nil, nil, nil
+	}
+	if isEncryptedConnectVersion(connectReq.Version) {
+		if connectReq.Features.Enabled(version.LinkKeyExchange) {
+			if key, err = exchangeSessionKey(key, kxKey, connectReq.PublicKey, welcome.PublicKey, connectReq.PublicKey); err != nil {
+				log.Error("key exchange failure: ", err)
+				return nil, nil, nil
+			}
+			log.Debug("forward-secret session key negotiated.")
+		}
+		if connectReq.Features.Enabled(version.LinkParallelRecords) {
+			err = link.resetRecordCipher(connectReq.Version, key[:], hello.IV[:], false)
+		} else {
+			err = link.resetCipher(connectReq.Version, key[:], hello.IV[:])
+		}
+		if err != nil {
+			log.Error("cipher initializion failure: ", err)
+			return nil, nil, err
+		}
+		if connectReq.Features.Enabled(version.LinkRekey) {
+			if err = link.enableRekey(key); err != nil {
+				log.Error("cannot enable rekeying: ", err)
+				return nil, nil, err
+			}
+		}
+	}
+	if connectReq.Features.Enabled(version.LinkCompression) {
+		log.Debug("enable compression.")
+		link.enableCompression()
 	}
 
-	return t.acceptTCPLink(log, link, connectReq)
+	return link, connectReq, nil
 }
 
-func (t *TCP) connectHandshake(ctx context.Context, log *logging.Entry, link *TCPLink) (accepted bool, err error) {
+// exchangeHello sends hello to peer and waits for welcome.
+// Cipher of link is initialized with pre-shared session key on success.
+func (t *TCP) exchangeHello(ctx context.Context, log *logging.Entry, link *TCPLink) (hello *proto.Hello, welcome *proto.Welcome, key [32]byte, err error) {
 	buf := make([]byte, defaultBufferSize)
 
 	// deadline.
@@ -135,21 +245,30 @@ func (t *TCP) connectHandshake(ctx context.Context, log *logging.Entry, link *TC
 	if hasDeadline {
 		if err = link.conn.SetDeadline(deadline); err != nil {
 			log.Error("conn.SetDeadline() failure: ", err)
-			return false, err
+			return
 		}
 	}
+	if t.tlsEnabled() {
+		var conn *tlsStreamConn
+		name := link.publish
+		if link.identity != "" {
+			name = link.identity
+		}
+		if conn, err = t.tlsClient(link.conn, name); err != nil {
+			log.Error("TLS handshake failure: ", err)
+			return
+		}
+		link.conn = conn
+	}
 
 	log.Debug("handshaking...")
 	// hello
-	hello := proto.Hello{
+	hello = &proto.Hello{
 		Lead: t.getStartCode(log),
 	}
 	hello.Refresh()
-	if t.psk != nil {
-		hello.Sign([]byte(*t.psk))
-	} else {
-		hello.Sign(nil)
-	}
+	keys := t.keys.load()
+	keys.sign(hello)
 	buf = hello.Encode(buf[:0])
 	if _, err = link.conn.Write(buf); err != nil {
 		if err == io.EOF {
@@ -157,26 +276,20 @@ func (t *TCP) connectHandshake(ctx context.Context, log *logging.Entry, link *TC
 		} else {
 			log.Error("send error: ", err)
 		}
-		return false, err
+		return
 	}
 
 	// can init cipher now.
 	log.Debug("initialize cipher.")
-	buf = buf[:0]
-	buf = append(buf, hello.Lead...)
-	if t.psk != nil {
-		buf = append(buf, []byte(*t.psk)...)
-	}
-	buf = append(buf, hello.HMAC[:]...)
-	key := sha256.Sum256(buf)
+	link.pskID = PSKID(keys.active)
+	key = helloSessionKey(hello, keys.active)
 	if err = link.InitializeAESGCM(key[:], hello.IV[:]); err != nil {
 		log.Error("cipher initializion failure: ", err)
-		return false, err
+		return
 	}
 
 	// wait for welcome.
 	log.Debug("wait for authentication.")
-	var welcome *proto.Welcome
 	if rerr := link.read(func(frame []byte) bool {
 		welcome = &proto.Welcome{}
 		if err = welcome.Decode(frame); err != nil {
@@ -186,43 +299,101 @@ func (t *TCP) connectHandshake(ctx context.Context, log *logging.Entry, link *TC
 	}); rerr != nil {
 		if nerr, ok := rerr.(net.Error); ok && nerr.Timeout() {
 			log.Error("canceled for deadline exceeded.")
-			return false, ErrOperationCanceled
+			return hello, nil, key, ErrOperationCanceled
 		}
 		if rerr == io.EOF {
 			log.Error("connection closed by foreign peer.")
-			return false, ErrConnectionClosed
+			return hello, nil, key, ErrConnectionClosed
 		}
 		log.Error("link read failure: ", rerr)
-		return false, rerr
+		return hello, nil, key, rerr
 	}
 	if err != nil {
-		return false, err
+		return
 	}
 	if done := ctx.Done(); done != nil {
 		select {
 		case <-done:
-			return false, ErrOperationCanceled
+			return hello, nil, key, ErrOperationCanceled
 		default:
 		}
 	}
-	if !welcome.Welcome { // denied.
+	return
+}
+
+func (t *TCP) connectHandshake(ctx context.Context, log *logging.Entry, link *TCPLink) (accepted bool, err error) {
+	hello, welcome, key, err := t.exchangeHello(ctx, log, link)
+	if err != nil {
+		return false, err
+	}
+	if welcome == nil || !welcome.Welcome { // denied.
 		return false, nil
 	}
+	if link.identity != "" && welcome.Identity != link.identity {
+		log.Errorf("expect peer \"%v\", but got \"%v\".", link.identity, welcome.Identity)
+		return false, ErrPunchIdentityMismatch
+	}
 
+	buf := make([]byte, defaultBufferSize)
 	log.Debug("good authentication. connecting...")
 	// send connect request.
 	connectReq := proto.Connect{
-		Identity: t.config.Publish,
+		Identity: t.getConfig().Publish,
 	}
+	link.striping = welcome.Features.Enabled(version.LinkStriping)
+	if link.stripe > 0 && !link.striping {
+		err = fmt.Errorf("peer doesn't support striping")
+		log.Error(err)
+		return false, err
+	}
+	connectReq.Features.Enable(version.LinkStriping)
+	connectReq.Stripe = link.stripe
 	if connectReq.Identity == "" {
 		err = fmt.Errorf("empty publish endpoint")
 		log.Error(err)
 		return false, err
 	}
-	if t.config.raw.GetEncrypt() {
-		connectReq.Version = proto.ConnectAES256GCM
+	if t.getConfig().raw.GetEncrypt() {
+		if connectReq.Version, err = t.getConfig().connectVersion(); err != nil {
+			log.Error(err)
+			return false, err
+		}
+		if connectReq.Version == proto.ConnectChaCha20Poly1305 && !welcome.Features.Enabled(version.LinkChaCha20Poly1305) {
+			log.Warn("peer doesn't support chacha20-poly1305. fall back to aes-gcm.")
+			connectReq.Version = proto.ConnectAES256GCM
+		}
 	} else {
 		connectReq.Version = proto.ConnectNoCrypt
+		// legacy peer knows start-code framing only.
+		if welcome.Features.Enabled(version.LinkLengthFraming) {
+			connectReq.Features.Enable(version.LinkLengthFraming)
+		}
+	}
+	encrypted := isEncryptedConnectVersion(connectReq.Version)
+	// legacy peer sends no ephemeral key.
+	var kxKey *ecdh.PrivateKey
+	if encrypted && welcome.Features.Enabled(version.LinkKeyExchange) {
+		if kxKey, err = newHandshakeKey(); err != nil {
+			log.Error("generate ephemeral key failure: ", err)
+			return false, err
+		}
+		connectReq.Features.Enable(version.LinkKeyExchange)
+		connectReq.PublicKey = kxKey.PublicKey().Bytes()
+	}
+	rekey := encrypted && welcome.Features.Enabled(version.LinkRekey)
+	if rekey {
+		connectReq.Features.Enable(version.LinkRekey)
+	}
+	records := encrypted && welcome.Features.Enabled(version.LinkParallelRecords)
+	if records {
+		connectReq.Features.Enable(version.LinkParallelRecords)
+	}
+	if compress, _ := t.getConfig().compress(); compress {
+		if welcome.Features.Enabled(version.LinkCompression) {
+			connectReq.Features.Enable(version.LinkCompression)
+		} else {
+			log.Warn("peer doesn't support compression. send frames as they are.")
+		}
 	}
 	buf = connectReq.Encode(buf[:0])
 	if _, err = link.muxer.Mux(buf); err != nil {
@@ -232,17 +403,42 @@ func (t *TCP) connectHandshake(ctx context.Context, log *logging.Entry, link *TC
 	// switch protocol
 	switch connectReq.Version {
 	case proto.ConnectNoCrypt:
-		link.InitializeNoCryption()
+		link.InitializeNoCryption(connectReq.Features.Enabled(version.LinkLengthFraming))
 
-	case proto.ConnectAES256GCM:
+	case proto.ConnectAES256GCM, proto.ConnectChaCha20Poly1305:
 		log.Debug("enable encryption.")
-		// let it is.
+		if kxKey != nil {
+			if key, err = exchangeSessionKey(key, kxKey, welcome.PublicKey, welcome.PublicKey, connectReq.PublicKey); err != nil {
+				log.Error("key exchange failure: ", err)
+				return false, err
+			}
+			log.Debug("forward-secret session key negotiated.")
+		}
+		if records {
+			err = link.resetRecordCipher(connectReq.Version, key[:], hello.IV[:], true)
+		} else {
+			err = link.resetCipher(connectReq.Version, key[:], hello.IV[:])
+		}
+		if err != nil {
+			log.Error("cipher initializion failure: ", err)
+			return false, err
+		}
+		if rekey {
+			if err = link.enableRekey(key); err != nil {
+				log.Error("cannot enable rekeying: ", err)
+				return false, err
+			}
+		}
 	default:
 		// should not hit this.
 		err = fmt.Errorf("invalid connecting protocol version %v", connectReq.Version)
 		log.Error(err)
 		return false, err
 	}
+	if connectReq.Features.Enabled(version.LinkCompression) {
+		log.Debug("enable compression.")
+		link.enableCompression()
+	}
 
 	return true, nil
 }
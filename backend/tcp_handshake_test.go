@@ -0,0 +1,93 @@
+package backend
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/mux"
+	"github.com/crossmesh/fabric/proto"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestExchangeSessionKey(t *testing.T) {
+	psk := "12345"
+	hello := &proto.Hello{}
+	hello.Refresh()
+	hello.Sign([]byte(psk))
+	base := helloSessionKey(hello, &psk)
+
+	server, err := newHandshakeKey()
+	assert.NoError(t, err)
+	client, err := newHandshakeKey()
+	assert.NoError(t, err)
+	serverPub, clientPub := server.PublicKey().Bytes(), client.PublicKey().Bytes()
+
+	serverKey, err := exchangeSessionKey(base, server, clientPub, serverPub, clientPub)
+	assert.NoError(t, err)
+	clientKey, err := exchangeSessionKey(base, client, serverPub, serverPub, clientPub)
+	assert.NoError(t, err)
+	assert.Equal(t, serverKey, clientKey)
+	assert.NotEqual(t, base, serverKey)
+
+	_, err = exchangeSessionKey(base, client, []byte{1, 2, 3}, serverPub, clientPub)
+	assert.Error(t, err)
+}
+
+func TestTCPLengthFraming(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind string) *TCP {
+		encrypt := false
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newTCP("127.0.0.1:39850"), newTCP("127.0.0.1:39851")
+
+	received := make(chan []byte, 1)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- append([]byte(nil), frame...)
+	})
+
+	var (
+		link Link
+		err  error
+	)
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+	_, isLength := link.(*TCPLink).muxer.(*mux.LengthMuxer)
+	assert.True(t, isLength)
+
+	payload := []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x02}
+	assert.NoError(t, link.Send(payload))
+	select {
+	case frame := <-received:
+		assert.True(t, bytes.Equal(payload, frame))
+	case <-time.After(time.Second * 5):
+		t.Fatal("frame not delivered.")
+	}
+	_, isLength = b.getLink(a.Publish()).demuxer.(*mux.LengthDemuxer)
+	assert.True(t, isLength)
+
+	// legacy peer.
+	legacy := newTCPLink(b)
+	assert.True(t, b.acceptConnectVersion(b.log, legacy, &proto.Connect{Version: proto.ConnectNoCrypt}))
+	_, isStartCode := legacy.demuxer.(*mux.StreamDemuxer)
+	assert.True(t, isStartCode)
+}
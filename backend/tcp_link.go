@@ -24,22 +24,39 @@ const (
 // TCPLink maintains data path between two peer.
 type TCPLink struct {
 	lock    sync.RWMutex
-	conn    *net.TCPConn
+	conn    streamConn
 	crypt   cipher.Block
-	remote  *net.TCPAddr
+	remote  net.Addr
 	publish string
+	pskID   string // fingerprint of pre-shared key authenticating link.
 
-	// write context.
-	writeLock sync.Mutex
+	identity string // publish endpoint expected in welcome. not checked if empty.
+
+	stripe     uint8 // index among parallel connections to peer.
+	striping   bool  // peer supports parallel connections.
+	retryAfter int64 // unix nano before which failed stripe won't be re-established.
+
+	// write context. parallel muxer is shared by senders holding read lock.
+	writeLock sync.RWMutex
 	muxer     mux.Muxer
 	w         io.Writer
 
+	// compression context. nil if compression is not negotiated.
+	compressor *mux.Compressor
+
 	// read context.
 	demuxer   mux.Demuxer
 	readLock  sync.Mutex
 	buf       []byte
 	cursor    int
 	maxCursor int
+	onFrame   func(frame []byte) bool // built once, so that feeding demuxer doesn't allocate.
+	emit      func(frame []byte) bool // emit of ongoing read.
+	emitMore  bool
+
+	rekey *tcpLinkRekey
+
+	stats *linkCounters // kept across connections.
 
 	backend *TCP
 }
@@ -47,18 +64,36 @@ type TCPLink struct {
 func newTCPLink(backend *TCP) (r *TCPLink) {
 	r = &TCPLink{
 		backend:   backend,
+		stats:     newLinkCounters(),
 		buf:       make([]byte, defaultBufferSize),
 		cursor:    0,
 		maxCursor: 0,
 	}
+	r.onFrame = r.deliver
 	return r
 }
 
+// deliver passes demuxed frame to emit of ongoing read.
+func (l *TCPLink) deliver(frame []byte) bool {
+	if compressor := l.compressor; compressor != nil {
+		var err error
+		if frame, err = compressor.Decompress(frame); err != nil {
+			l.stats.demuxError()
+			l.backend.log.Warn("drop frame: ", err)
+			return true
+		}
+	}
+	l.emitMore = l.emit(frame)
+	return l.emitMore
+}
+
 func (l *TCPLink) reset() {
 	l.cursor, l.maxCursor = 0, 0
-	l.demuxer, l.muxer, l.crypt = nil, nil, nil
+	l.demuxer, l.muxer, l.crypt, l.rekey = nil, nil, nil, nil
+	l.compressor = nil
 	l.remote, l.conn = nil, nil
-	l.publish = ""
+	l.publish, l.pskID = "", ""
+	l.stripe, l.striping = 0, false
 	l.w = nil
 }
 
@@ -67,6 +102,13 @@ func (l *TCPLink) Active() bool {
 	return l.conn != nil
 }
 
+// writable reports whether frames can be sent.
+func (l *TCPLink) writable() bool {
+	l.writeLock.RLock()
+	defer l.writeLock.RUnlock()
+	return l.muxer != nil
+}
+
 func (l *TCPLink) assign(right *TCPLink) bool {
 	if right == nil {
 		return false
@@ -81,26 +123,36 @@ func (l *TCPLink) assign(right *TCPLink) bool {
 	if !right.Active() {
 		return false
 	}
+	l.move(right)
+	return true
+}
+
+// move takes over connection of right link. Locks should be held by caller.
+func (l *TCPLink) move(right *TCPLink) {
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+
 	l.cursor, l.maxCursor = right.cursor, right.maxCursor
-	l.crypt, l.demuxer, l.muxer = right.crypt, right.demuxer, right.muxer
+	l.crypt, l.demuxer, l.muxer, l.rekey = right.crypt, right.demuxer, right.muxer, right.rekey
+	l.compressor = right.compressor
 	l.buf = right.buf
-	l.remote, l.conn, l.publish = right.remote, right.conn, right.publish
-	l.backend = right.backend
+	l.remote, l.conn, l.publish, l.pskID = right.remote, right.conn, right.publish, right.pskID
+	l.stripe, l.striping = right.stripe, right.striping
 	l.w = right.w
 
 	right.reset()
-
-	return true
 }
 
 func (l *TCPLink) read(emit func(frame []byte) bool) (err error) {
-	conn, feed, cont := l.conn, 0, true
+	conn, feed := l.conn, 0
 	if conn == nil {
 		return ErrConnectionClosed
 	}
 	l.readLock.Lock()
 	defer l.readLock.Unlock()
-	for cont && err == nil {
+	l.emit, l.emitMore = emit, true
+	defer func() { l.emit = nil }()
+	for l.emitMore && err == nil {
 
 		if l.maxCursor <= 0 {
 			// 1. read stream
@@ -110,10 +162,10 @@ func (l *TCPLink) read(emit func(frame []byte) bool) (err error) {
 			l.cursor, l.maxCursor = 0, feed
 		} else {
 			// 2. feed demuxer
-			feed, err = l.demuxer.Demux(l.buf[l.cursor:l.maxCursor], func(frame []byte) bool {
-				cont = emit(frame)
-				return cont
-			})
+			feed, err = l.demuxer.Demux(l.buf[l.cursor:l.maxCursor], l.onFrame)
+			if err != nil {
+				l.stats.demuxError()
+			}
 			l.cursor += feed
 		}
 		if l.cursor == l.maxCursor {
@@ -130,34 +182,81 @@ func (l *TCPLink) Send(frame []byte) (err error) {
 		return ErrOperationCanceled
 	}
 
-	muxer := l.muxer
+	l.writeLock.RLock()
+	muxer, publish := l.muxer, l.publish
+	l.writeLock.RUnlock()
 	if muxer == nil {
 		return ErrOperationCanceled
 	}
+	if !t.getLimiter().wait(publish, frame, t.getSendTimeout()) {
+		l.stats.sendTimeout()
+		return ErrOperationCanceled
+	}
 
-	if !muxer.Parallel() {
+	var rekeyErr error
+	parallel := muxer.Parallel()
+	if parallel {
+		l.writeLock.RLock()
+	} else {
 		l.writeLock.Lock()
-		defer l.writeLock.Unlock()
+	}
+	if l.muxer != muxer {
+		// link is closed or moved meanwhile.
+		err = ErrOperationCanceled
+	} else {
+		if compressor := l.compressor; compressor != nil {
+			err = compressor.Compress(frame, func(compressed []byte) (err error) {
+				_, err = muxer.Mux(compressed)
+				return
+			})
+		} else {
+			_, err = muxer.Mux(frame)
+		}
+		if err == nil {
+			l.stats.sent(len(frame))
+			rekeyErr = l.onSent(muxer, len(frame))
+		}
+	}
+	if parallel {
+		l.writeLock.RUnlock()
+	} else {
+		l.writeLock.Unlock()
 	}
 
-	if _, err = muxer.Mux(frame); err != nil {
+	if err != nil {
+		if err == ErrOperationCanceled {
+			return
+		}
 		if nerr, ok := err.(net.Error); err == io.EOF || (ok && nerr.Timeout()) {
+			if ok {
+				l.stats.sendTimeout()
+			}
 			err = ErrOperationCanceled
 		} else {
 			// close corrupted link.
 			t.log.Error("mux error: ", err)
 			l.Close()
 		}
+		return
+	}
+	if err = rekeyErr; err != nil {
+		t.log.Error("rekey failure: ", err)
+		l.Close()
 	}
 	return
 }
 
 // InitializeAESGCM initializes AES-256-GCM muxer and demuxer.
 func (l *TCPLink) InitializeAESGCM(key []byte, nonce []byte) (err error) {
+	l.initializeWriter()
+	return l.resetAESGCM(key, nonce)
+}
+
+// resetAESGCM replaces AES-256-GCM muxer and demuxer over current writer.
+func (l *TCPLink) resetAESGCM(key []byte, nonce []byte) (err error) {
 	if l.crypt, err = aes.NewCipher(key[:]); err != nil {
 		return err
 	}
-	l.initializeWriter()
 	if l.muxer, err = mux.NewGCMStreamMuxer(l.w, l.crypt, nonce); err != nil {
 		return err
 	}
@@ -168,8 +267,8 @@ func (l *TCPLink) InitializeAESGCM(key []byte, nonce []byte) (err error) {
 }
 
 func (l *TCPLink) initializeWriter() {
-	if l.backend.config.EnableDrainer {
-		bufferSize, latency, statisticWindow, threshold := l.backend.config.MaxDrainBuffer, l.backend.config.MaxDrainLatancy, l.backend.config.DrainStatisticWindow, l.backend.config.BulkThreshold
+	if cfg := l.backend.getConfig(); cfg.EnableDrainer {
+		bufferSize, latency, statisticWindow, threshold := cfg.MaxDrainBuffer, cfg.MaxDrainLatancy, cfg.DrainStatisticWindow, cfg.BulkThreshold
 		if bufferSize < 1 {
 			bufferSize = DefaultDrainBufferSize
 		}
@@ -192,9 +291,17 @@ func (l *TCPLink) initializeWriter() {
 }
 
 // InitializeNoCryption initializes normal muxer and demuxer without encryption.
-func (l *TCPLink) InitializeNoCryption() {
-	l.initializeWriter()
-	l.muxer, l.demuxer = mux.NewStreamMuxer(l.w), mux.NewStreamDemuxer()
+// Frames are length-prefixed if lengthFraming is true, or delimited by start code otherwise.
+// Writer of connection is kept if initialized.
+func (l *TCPLink) InitializeNoCryption(lengthFraming bool) {
+	if l.w == nil {
+		l.initializeWriter()
+	}
+	if lengthFraming {
+		l.muxer, l.demuxer = mux.NewLengthMuxer(l.w), mux.NewLengthDemuxer()
+	} else {
+		l.muxer, l.demuxer = mux.NewStreamMuxer(l.w), mux.NewStreamDemuxer()
+	}
 }
 
 func (l *TCPLink) close() (err error) {
@@ -207,6 +314,19 @@ func (l *TCPLink) close() (err error) {
 	return
 }
 
+// closeConn terminates link if it is still carried by conn.
+func (l *TCPLink) closeConn(conn streamConn) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.conn == conn {
+		l.writeLock.Lock()
+		defer l.writeLock.Unlock()
+		l.close()
+	} else {
+		conn.Close()
+	}
+}
+
 // Close terminates link.
 func (l *TCPLink) Close() (err error) {
 	l.lock.Lock()
@@ -221,13 +341,13 @@ func (l *TCPLink) Close() (err error) {
 	return l.close()
 }
 
-func (t *TCP) connect(addr *net.TCPAddr, publish string) (l *TCPLink, err error) {
+func (t *TCP) connect(addr net.Addr, publish string, stripe uint8) (l *TCPLink, err error) {
 	if !t.Arbiter.ShouldRun() {
 		return nil, ErrOperationCanceled
 	}
 
 	// get link.
-	key := publish
+	key := stripeKey(publish, stripe)
 	link := t.getLink(key)
 	if link.conn != nil {
 		// fast path: link is valid.
@@ -245,38 +365,45 @@ func (t *TCP) connect(addr *net.TCPAddr, publish string) (l *TCPLink, err error)
 	ctx, cancel := context.WithTimeout(t.Arbiter.Context(), t.getConnectTimeout())
 	defer cancel()
 
-	t.log.Infof("connecting to %v(%v)", publish, addr.String())
+	var pending *TCPLink
+
+	t.log.Infof("connecting to %v(%v) stripe %v", publish, addr.String(), stripe)
 	// dial
-	dialer := net.Dialer{}
-	if conn, ierr := dialer.DialContext(ctx, "tcp", addr.String()); ierr != nil {
+	if conn, ierr := t.dial(ctx, addr); ierr != nil {
 		if t.Arbiter.ShouldRun() {
 			t.log.Error(ierr)
 		}
 		return nil, ierr
 
-	} else if tcpConn, isTCP := conn.(*net.TCPConn); !isTCP {
-		t.log.Error("got non-tcp connection")
+	} else if streamConn, isStream := conn.(streamConn); !isStream {
+		t.log.Error("got non-stream connection")
 		return nil, ErrNonTCPConnection
 
 	} else {
-		link.conn = tcpConn
-		link.publish = addr.String()
+		// handshake over pending link, so that senders won't see link until it's established.
+		pending = newTCPLink(t)
+		pending.conn = streamConn
+		pending.publish = addr.String()
+		pending.stripe = stripe
 	}
 
 	connID := atomic.AddUint32(&t.connID, 1)
 	log := t.log.WithField("conn_id", connID)
 	// handshake
 	var accepted bool
-	if accepted, err = t.connectHandshake(ctx, log, link); err != nil {
+	if accepted, err = t.connectHandshake(ctx, log, pending); err != nil {
 		log.Error("handshake failure: ", err)
-		link.close()
+		link.stats.handshakeFailure()
+		pending.close()
 		return nil, err
 	}
 	if !accepted {
 		log.Error("denied by remote peer.")
-		link.close()
+		link.stats.handshakeFailure()
+		pending.close()
 		return nil, err
 	}
+	link.move(pending)
 
 	t.goTCPLinkDaemon(log, key, link)
 
@@ -286,51 +413,88 @@ func (t *TCP) connect(addr *net.TCPAddr, publish string) (l *TCPLink, err error)
 // Connect trys to establish data path to peer.
 func (t *TCP) Connect(endpoint string) (l Link, err error) {
 	var (
-		addr *net.TCPAddr
+		addr net.Addr
 		link *TCPLink
 	)
 	if addr, err = t.resolve(endpoint); err != nil {
 		return nil, err
 	}
-	if link, err = t.connect(addr, endpoint); err != nil {
+	if link, err = t.connect(addr, endpoint, 0); err != nil {
 		return nil, err
 	}
+	if n := t.getStripes(); n > 1 && link.striping {
+		return t.getStripedLink(addr, endpoint, n), nil
+	}
 	return link, err
 }
 
-func (t *TCP) forwardProc(log *logging.Entry, key string, link *TCPLink) {
+func (t *TCP) forwardProc(log *logging.Entry, key string, link *TCPLink, conn streamConn) {
 	var err error
 
+	deliver := func(frame []byte) bool {
+		// deliver frame to all watchers.
+		t.watch.Range(func(k, v interface{}) bool {
+			if emit, ok := v.(func(Backend, []byte, string)); ok {
+				emit(t, frame, link.publish)
+			}
+			return true
+		})
+		link.stats.received(len(frame))
+		return true
+	}
 	for t.Arbiter.ShouldRun() {
-		conn := link.conn
-		if conn == nil {
+		if link.conn != conn { // closed or re-established.
 			break
 		}
 		if err = link.conn.SetReadDeadline(time.Now().Add(time.Second * 3)); err != nil {
 			log.Error("conn.SetReadDeadline() error: ", err)
 			break
 		}
-		if err = link.read(func(frame []byte) bool {
-			// deliver frame to all watchers.
-			t.watch.Range(func(k, v interface{}) bool {
-				if emit, ok := v.(func(Backend, []byte, string)); ok {
-					emit(t, frame, link.publish)
-				}
-				return true
-			})
-			return true
-		}); err != nil {
+		if err = link.read(deliver); err != nil {
 			// handle errors.
 			if err == io.EOF { // connection closed.
 				break
 			}
 			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
 				err = nil
+				continue
 			}
+			// broken connection, or corrupted, replayed or reordered frames. drop link.
+			log.Error("link read failure: ", err)
+			break
 		}
 	}
 }
 
+// tuneConn applies TCP options to connection.
+func (t *TCP) tuneConn(log *logging.Entry, conn streamConn) (err error) {
+	cfg := t.getConfig()
+	if bufSize := cfg.SendBufferSize; bufSize > 0 {
+		if err = conn.SetWriteBuffer(bufSize); err != nil {
+			log.Error("conn.SetWriteBuffer error: ", err)
+			return
+		}
+	}
+	if tcpConn, isTCP := tcpConnOf(conn); isTCP {
+		if err = tcpConn.SetNoDelay(true); err != nil {
+			log.Error("conn.SetNoDelay error: ", err)
+			return
+		}
+		if err = tcpConn.SetKeepAlive(true); err != nil {
+			log.Error("conn.SetKeepalive error: ", err)
+			return
+		}
+
+		if keepalivePeriod := cfg.KeepalivePeriod; keepalivePeriod > 0 {
+			if err = tcpConn.SetKeepAlivePeriod(time.Second * time.Duration(keepalivePeriod)); err != nil {
+				log.Error("conn.SetKeepalivePeriod error: ", err)
+				return
+			}
+		}
+	}
+	return nil
+}
+
 func (t *TCP) goTCPLinkDaemon(log *logging.Entry, key string, link *TCPLink) {
 	var (
 		routines uint32
@@ -338,6 +502,7 @@ func (t *TCP) goTCPLinkDaemon(log *logging.Entry, key string, link *TCPLink) {
 	)
 
 	log.Infof("link to foreign peer \"%v\" established.", key)
+	link.stats.connected()
 	// clear any deadline.
 	if err = link.conn.SetDeadline(time.Time{}); err != nil {
 		log.Error("conn.SetDeadline error: ", err)
@@ -352,38 +517,21 @@ func (t *TCP) goTCPLinkDaemon(log *logging.Entry, key string, link *TCPLink) {
 		return
 	}
 	// TCP options.
-	if bufSize := t.config.SendBufferSize; bufSize > 0 {
-		if err = link.conn.SetWriteBuffer(bufSize); err != nil {
-			log.Error("conn.SetWriteBuffer error: ", err)
-			return
-		}
-	}
-	if err = link.conn.SetNoDelay(true); err != nil {
-		log.Error("conn.SetNoDelay error: ", err)
+	if err = t.tuneConn(log, link.conn); err != nil {
 		return
 	}
-	if err = link.conn.SetKeepAlive(true); err != nil {
-		log.Error("conn.SetKeepalive error: ", err)
-		return
-	}
-
-	if keepalivePeriod := t.config.KeepalivePeriod; keepalivePeriod > 0 {
-		if err = link.conn.SetKeepAlivePeriod(time.Second * time.Duration(keepalivePeriod)); err != nil {
-			log.Error("conn.SetKeepalivePeriod error: ", err)
-			return
-		}
-	}
 
 	// spwan.
+	conn := link.conn
 	for n := t.getRoutinesCount(); n > 0; n-- {
 		t.Arbiter.Go(func() {
 			atomic.AddUint32(&routines, 1)
 			defer func() {
 				if last := atomic.AddUint32(&routines, 0xFFFFFFFF); last == 0 {
-					link.Close()
+					link.closeConn(conn)
 				}
 			}()
-			t.forwardProc(log, key, link)
+			t.forwardProc(log, key, link, conn)
 		})
 	}
 }
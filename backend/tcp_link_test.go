@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/crossmesh/fabric/config"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+// BenchmarkTCPLinkForwarding measures full-size frames passing through a loopback TCP link.
+func BenchmarkTCPLinkForwarding(b *testing.B) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind string, encrypt bool) *TCP {
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, raw: raw}
+		t, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return t
+	}
+
+	frame := make([]byte, 1400)
+	rand.Read(frame)
+
+	bench := func(name string, from, to *TCP) {
+		received := make(chan struct{}, 1)
+		to.Watch(func(_ Backend, frame []byte, src string) { received <- struct{}{} })
+
+		var (
+			link Link
+			err  error
+		)
+		if !waitForBackend(func() bool {
+			link, err = from.Connect(to.Publish())
+			return err == nil
+		}) {
+			b.Fatalf("cannot connect to %v: %v", to.Publish(), err)
+		}
+		if err = link.Send(frame); err != nil { // warm up.
+			b.Fatal(err)
+		}
+		<-received
+
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(frame)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err = link.Send(frame); err != nil {
+					b.Fatal(err)
+				}
+				<-received
+			}
+		})
+	}
+
+	bench("plain", newTCP("127.0.0.1:39854", false), newTCP("127.0.0.1:39855", false))
+	bench("aes-gcm", newTCP("127.0.0.1:39856", true), newTCP("127.0.0.1:39857", true))
+}
@@ -0,0 +1,194 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/crossmesh/fabric/proto"
+)
+
+const tcpPunchRetryInterval = time.Millisecond * 200
+
+// tcpPunch is simultaneous open in progress.
+type tcpPunch struct {
+	identity string
+	conns    chan streamConn
+}
+
+// PunchPort returns bind port. Punching connections start from it, so that NAT preserving ports
+// maps them to the port peers observe the listener at. Punching is not ready until listening.
+func (t *TCP) PunchPort() (uint16, bool) {
+	if t.network != "tcp" || !t.getConfig().HolePunch {
+		return 0, false
+	}
+	port := t.Port()
+	return port, port != 0
+}
+
+// takePunched hands connection from peer being punched to the punching routine.
+func (t *TCP) takePunched(conn net.Conn) bool {
+	v, punching := t.punches.Load(conn.RemoteAddr().String())
+	if !punching {
+		return false
+	}
+	sconn, isStream := conn.(streamConn)
+	if !isStream {
+		return false
+	}
+	select {
+	case v.(*tcpPunch).conns <- sconn:
+	default:
+		conn.Close()
+	}
+	return true
+}
+
+// Punch establishes link to peer by TCP simultaneous open.
+// Both sides dial from bind port until a connection is made, either by dialing or being accepted.
+// Since both sides may think they dial in simultaneous open, the one with lower publish endpoint
+// takes client role in handshake.
+func (t *TCP) Punch(endpoint, identity string) (l Link, err error) {
+	if _, enabled := t.PunchPort(); !enabled {
+		return nil, ErrPunchUnsupported
+	}
+	if !t.Arbiter.ShouldRun() {
+		return nil, ErrOperationCanceled
+	}
+	addr, err := t.resolveAddr(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	dialer, err := t.newPunchDialer()
+	if err != nil {
+		return nil, err
+	}
+
+	// link isn't locked while punching, since it may take up to connect timeout.
+	// the punched connection is assigned at last, unless link has been established by others.
+	link := t.getLink(endpoint)
+	link.lock.RLock()
+	active := link.Active()
+	link.lock.RUnlock()
+	if active {
+		return link, nil
+	}
+
+	punch := &tcpPunch{identity: identity, conns: make(chan streamConn, 1)}
+	t.punches.Store(addr.String(), punch)
+	defer func() {
+		t.punches.Delete(addr.String())
+		select {
+		case conn := <-punch.conns:
+			conn.Close()
+		default:
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Arbiter.Context(), t.getConnectTimeout())
+	defer cancel()
+
+	t.log.Infof("punching to %v(%v)", identity, endpoint)
+	isClient, delay := t.getConfig().Publish < identity, time.Duration(0)
+	if !isClient {
+		// let client punch first, so that its connection is taken over even if there were no NAT.
+		delay = tcpPunchRetryInterval
+	}
+	conn, err := t.punchConn(ctx, dialer, addr, punch, delay)
+	if err != nil {
+		return nil, err
+	}
+
+	log := t.log.WithField("conn_id", atomic.AddUint32(&t.connID, 1))
+	var pending *TCPLink
+	if isClient {
+		pending = newTCPLink(t)
+		pending.conn, pending.publish, pending.identity = conn, endpoint, identity
+		var accepted bool
+		if accepted, err = t.connectHandshake(ctx, log, pending); err != nil || !accepted {
+			log.Error("punched handshake failure: ", err)
+			link.stats.handshakeFailure()
+			pending.close()
+			if err == nil {
+				err = ErrConnectionDeined
+			}
+			return nil, err
+		}
+
+	} else {
+		var connectReq *proto.Connect
+		if pending, connectReq, err = t.acceptHandshake(log, conn); err != nil || pending == nil {
+			log.Error("punched handshake failure: ", err)
+			link.stats.handshakeFailure()
+			conn.Close()
+			if err == nil {
+				err = ErrConnectionDeined
+			}
+			return nil, err
+		}
+		if err = t.verifyPunched(pending, connectReq, identity); err != nil {
+			log.Error(err)
+			link.stats.handshakeFailure()
+			pending.close()
+			return nil, err
+		}
+		pending.publish, pending.remote = endpoint, pending.conn.RemoteAddr()
+	}
+	if !link.assign(pending) {
+		log.Infof("link to %v established during punching. closing punched connection...", identity)
+		pending.close()
+		return link, nil
+	}
+
+	t.goTCPLinkDaemon(log, endpoint, link)
+
+	return link, nil
+}
+
+// verifyPunched checks whether the expected peer connects.
+func (t *TCP) verifyPunched(link *TCPLink, connectReq *proto.Connect, identity string) error {
+	if connectReq.Identity != identity {
+		return ErrPunchIdentityMismatch
+	}
+	if tlsConn, isTLS := link.conn.(*tlsStreamConn); isTLS {
+		return tlsConn.verifyPeerIdentity(t.Type(), identity)
+	}
+	return nil
+}
+
+// punchConn dials peer repeatedly until connection is made, by dialing or being accepted.
+// Early attempts may be dropped or reset by NAT of peer, before the peer dials us.
+func (t *TCP) punchConn(ctx context.Context, dialer *net.Dialer, addr net.Addr, punch *tcpPunch, delay time.Duration) (streamConn, error) {
+	for {
+		select {
+		case conn := <-punch.conns:
+			return conn, nil
+		case <-ctx.Done():
+			return nil, ErrOperationCanceled
+		case <-time.After(delay):
+		}
+		delay = 0
+
+		attemptCtx, cancel := context.WithTimeout(ctx, tcpPunchRetryInterval*5)
+		conn, err := dialer.DialContext(attemptCtx, "tcp", addr.String())
+		cancel()
+		if err == nil {
+			sconn, isStream := conn.(streamConn)
+			if !isStream {
+				conn.Close()
+				return nil, ErrNonTCPConnection
+			}
+			return sconn, nil
+		}
+		t.log.Debugf("punching to %v: %v", addr, err)
+
+		select {
+		case conn := <-punch.conns:
+			return conn, nil
+		case <-ctx.Done():
+			return nil, ErrOperationCanceled
+		case <-time.After(tcpPunchRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestTCPPunch(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind, publish string, holePunch bool) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, Publish: publish, HolePunch: holePunch, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// publish addresses nobody listens at, as if peers were behind NAT.
+	a, b := newTCP("127.0.0.1:39862", "127.0.0.2:39862", true), newTCP("127.0.0.1:39863", "127.0.0.2:39863", true)
+	var _ Puncher = a
+
+	var (
+		port    uint16
+		enabled bool
+	)
+	assert.True(t, waitForBackend(func() bool {
+		_, ready := b.PunchPort()
+		port, enabled = a.PunchPort()
+		return enabled && ready
+	}), "backends not ready.")
+	assert.Equal(t, uint16(39862), port)
+
+	type frame struct {
+		payload []byte
+		src     string
+	}
+	received := make(chan frame, 1)
+	b.Watch(func(_ Backend, payload []byte, src string) {
+		received <- frame{append([]byte(nil), payload...), src}
+	})
+
+	type result struct {
+		link Link
+		err  error
+	}
+	punched := make(chan result, 1)
+	go func() {
+		link, err := b.Punch("127.0.0.1:39862", a.Publish())
+		punched <- result{link, err}
+	}()
+	la, err := a.Punch("127.0.0.1:39863", b.Publish())
+	if !assert.NoError(t, err) {
+		return
+	}
+	rb := <-punched
+	if !assert.NoError(t, rb.err) {
+		return
+	}
+
+	payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+	assert.NoError(t, la.Send(payload))
+	select {
+	case f := <-received:
+		assert.True(t, bytes.Equal(payload, f.payload))
+		assert.Equal(t, "127.0.0.1:39862", f.src)
+	case <-time.After(time.Second * 5):
+		t.Fatal("frame not delivered.")
+	}
+	assert.Equal(t, 1, len(a.Links()))
+	assert.Equal(t, 1, len(b.Links()))
+
+	// punched peer should have expected identity.
+	c := newTCP("127.0.0.1:39864", "127.0.0.2:39864", true)
+	assert.True(t, waitForBackend(func() bool {
+		_, ready := c.PunchPort()
+		return ready
+	}), "backend not ready.")
+	go c.Punch("127.0.0.1:39862", a.Publish())
+	_, err = a.Punch("127.0.0.1:39864", "wrong")
+	assert.Equal(t, ErrPunchIdentityMismatch, err)
+
+	// disabled.
+	d := newTCP("127.0.0.1:39865", "127.0.0.1:39865", false)
+	_, enabled = d.PunchPort()
+	assert.False(t, enabled)
+	_, err = d.Punch("127.0.0.1:39862", a.Publish())
+	assert.Equal(t, ErrPunchUnsupported, err)
+}
@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// ObservedAddress returns remote address of inbound link from peer.
+// Outbound links report nothing since their remote address is the one we dialed.
+func (t *TCP) ObservedAddress(publish string) string {
+	if t.network != "tcp" {
+		return ""
+	}
+	v, ok := t.link.Load(stripeKey(publish, 0))
+	if !ok || v == nil {
+		return ""
+	}
+	link, _ := v.(*TCPLink)
+	if link == nil {
+		return ""
+	}
+	link.lock.RLock()
+	defer link.lock.RUnlock()
+	if !link.Active() || link.remote == nil {
+		return ""
+	}
+	return link.remote.String()
+}
+
+// ReflexiveCandidate derives candidate publish endpoint from observed address.
+// Source port of outbound connection is ephemeral, so publish port is kept.
+func (t *TCP) ReflexiveCandidate(observed string) (string, bool) {
+	if t.network != "tcp" {
+		return "", false
+	}
+	host, _, err := net.SplitHostPort(observed)
+	if err != nil || net.ParseIP(host) == nil {
+		return "", false
+	}
+	_, port, err := net.SplitHostPort(t.getConfig().Publish)
+	if err != nil {
+		return "", false
+	}
+	candidate, err := normalizeHostPort(net.JoinHostPort(host, port))
+	if err != nil {
+		return "", false
+	}
+	return candidate, true
+}
+
+// ProbeEndpoint dials endpoint and reports identity carried by welcome.
+// Connection is closed right after welcome, so that no link is established.
+func (t *TCP) ProbeEndpoint(endpoint string) (identity string, err error) {
+	if !t.Arbiter.ShouldRun() {
+		return "", ErrOperationCanceled
+	}
+	addr, err := t.resolveAddr(endpoint)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(t.Arbiter.Context(), t.getConnectTimeout())
+	defer cancel()
+
+	conn, err := t.dial(ctx, addr)
+	if err != nil {
+		return "", err
+	}
+	sconn, isStream := conn.(streamConn)
+	if !isStream {
+		conn.Close()
+		return "", ErrNonTCPConnection
+	}
+	link := newTCPLink(t)
+	link.conn, link.publish = sconn, endpoint
+	defer link.close()
+
+	log := t.log.WithField("conn_id", atomic.AddUint32(&t.connID, 1)).WithField("probe", endpoint)
+	_, welcome, _, err := t.exchangeHello(ctx, log, link)
+	if err != nil {
+		return "", err
+	}
+	if welcome == nil || !welcome.Welcome {
+		return "", ErrConnectionDeined
+	}
+	return welcome.Identity, nil
+}
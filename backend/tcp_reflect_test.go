@@ -0,0 +1,60 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestTCPReflector(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind, publish string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, Publish: publish, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// a publishes address nobody listens at, as if it were behind NAT.
+	a, b := newTCP("127.0.0.1:39860", "127.0.0.2:39860"), newTCP("127.0.0.1:39861", "127.0.0.1:39861")
+	var _ Reflector = a
+
+	assert.True(t, waitForBackend(func() bool {
+		_, err := a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+
+	var observed string
+	assert.True(t, waitForBackend(func() bool {
+		observed = b.ObservedAddress(a.Publish())
+		return observed != ""
+	}), "inbound link not observed.")
+	// outbound link reports nothing.
+	assert.Equal(t, "", a.ObservedAddress(b.Publish()))
+
+	candidate, ok := a.ReflexiveCandidate(observed)
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1:39860", candidate)
+	_, ok = a.ReflexiveCandidate("not-an-address")
+	assert.False(t, ok)
+
+	identity, err := b.ProbeEndpoint(candidate)
+	assert.NoError(t, err)
+	assert.Equal(t, a.Publish(), identity)
+	_, err = b.ProbeEndpoint(a.Publish())
+	assert.Error(t, err)
+
+	// probing establishes no link.
+	assert.Equal(t, 1, len(a.Links()))
+	assert.Equal(t, 1, len(b.Links()))
+}
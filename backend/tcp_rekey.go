@@ -0,0 +1,126 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/crossmesh/fabric/mux"
+)
+
+const (
+	defaultRekeyBytes  = 1 << 30
+	defaultRekeyPeriod = 3600
+)
+
+// nextRekeyKey derives key of next period. Previous keys can not be recovered from later ones.
+func nextRekeyKey(key [32]byte) [32]byte {
+	buf := make([]byte, 0, len(key)+6)
+	buf = append(buf, []byte("rekey#")...)
+	buf = append(buf, key[:]...)
+	return sha256.Sum256(buf)
+}
+
+// blockRekeyer is implemented by muxers sealing with AES-GCM.
+type blockRekeyer interface {
+	Rekey(cipher.Block) error
+}
+
+// keyRekeyer is implemented by muxers sealing with ChaCha20-Poly1305.
+type keyRekeyer interface {
+	Rekey([]byte) error
+}
+
+// blockRekeyFollower is implemented by demuxers opening with AES-GCM.
+type blockRekeyFollower interface {
+	EnableRekey(func() (cipher.Block, error)) error
+}
+
+// keyRekeyFollower is implemented by demuxers opening with ChaCha20-Poly1305.
+type keyRekeyFollower interface {
+	EnableRekey(func() ([]byte, error)) error
+}
+
+// tcpLinkRekey contains rekeying context of link.
+// Both directions start with session key and switch keys independently.
+type tcpLinkRekey struct {
+	lock             sync.Mutex // serializes accounting of parallel senders.
+	sendKey, recvKey [32]byte
+
+	sent      uint64
+	lastRekey time.Time
+}
+
+func (t *TCP) getRekeyBytes() uint64 {
+	if n := t.getConfig().RekeyBytes; n > 0 {
+		return n
+	}
+	return defaultRekeyBytes
+}
+
+func (t *TCP) getRekeyPeriod() time.Duration {
+	return time.Duration(getDefaultUint32(t.getConfig().RekeyPeriod, defaultRekeyPeriod)) * time.Second
+}
+
+// enableRekey makes link switch keys in band.
+func (l *TCPLink) enableRekey(key [32]byte) (err error) {
+	r := &tcpLinkRekey{
+		sendKey:   key,
+		recvKey:   key,
+		lastRekey: time.Now(),
+	}
+	switch demuxer := l.demuxer.(type) {
+	case blockRekeyFollower:
+		err = demuxer.EnableRekey(func() (cipher.Block, error) {
+			r.recvKey = nextRekeyKey(r.recvKey)
+			return aes.NewCipher(r.recvKey[:])
+		})
+	case keyRekeyFollower:
+		err = demuxer.EnableRekey(func() ([]byte, error) {
+			r.recvKey = nextRekeyKey(r.recvKey)
+			return r.recvKey[:], nil
+		})
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	l.rekey = r
+	return nil
+}
+
+// onSent switches send key once byte count or time period exceeded.
+func (l *TCPLink) onSent(muxer mux.Muxer, size int) (err error) {
+	r := l.rekey
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// limits are loaded on every frame so that reloaded ones apply to established links.
+	if r.sent += uint64(size); r.sent < l.backend.getRekeyBytes() && time.Since(r.lastRekey) < l.backend.getRekeyPeriod() {
+		return nil
+	}
+	switch m := muxer.(type) {
+	case blockRekeyer:
+		r.sendKey = nextRekeyKey(r.sendKey)
+		var block cipher.Block
+		if block, err = aes.NewCipher(r.sendKey[:]); err != nil {
+			return err
+		}
+		err = m.Rekey(block)
+	case keyRekeyer:
+		r.sendKey = nextRekeyKey(r.sendKey)
+		err = m.Rekey(r.sendKey[:])
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.sent, r.lastRekey = 0, time.Now()
+	return nil
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestTCPRekey(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, RekeyBytes: 64, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newTCP("127.0.0.1:39910"), newTCP("127.0.0.1:39911")
+
+	received := make(chan []byte, 64)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- append([]byte(nil), frame...)
+	})
+
+	var (
+		link Link
+		err  error
+	)
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+	initial := link.(*TCPLink).rekey.sendKey
+
+	for i := 0; i < 32; i++ {
+		payload := bytes.Repeat([]byte{byte(i)}, 30)
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatalf("frame %v not delivered.", i)
+		}
+	}
+	assert.NotEqual(t, initial, link.(*TCPLink).rekey.sendKey)
+}
@@ -0,0 +1,131 @@
+package backend
+
+import (
+	"reflect"
+	"sort"
+	"sync/atomic"
+)
+
+// listenerChanged reports whether backend should be recreated to apply configuration.
+func (c *TCPBackendConfig) listenerChanged(x *TCPBackendConfig) bool {
+	return c.Bind != x.Bind || c.Publish != x.Publish || c.getWSPath() != x.getWSPath() ||
+		!reflect.DeepEqual(c.TrustedProxies, x.TrustedProxies) || !reflect.DeepEqual(c.WSS, x.WSS)
+}
+
+// socketOptionsChanged reports whether listening and established sockets should be placed differently.
+func (c *TCPBackendConfig) socketOptionsChanged(x *TCPBackendConfig) bool {
+	return c.BindDevice != x.BindDevice || c.Mark != x.Mark || c.DSCP != x.DSCP
+}
+
+// linkRestartRequired reports whether established links should be restarted to apply configuration.
+// They are parameters negotiated or fixed during handshake.
+func (c *TCPBackendConfig) linkRestartRequired(x *TCPBackendConfig) bool {
+	if c.raw.GetEncrypt() != x.raw.GetEncrypt() ||
+		c.raw.GetMaxConcurrency() != x.raw.GetMaxConcurrency() {
+		return true
+	}
+	if (c.TLS == nil) != (x.TLS == nil) {
+		return true
+	}
+	return c.Cipher != x.Cipher || c.Compression != x.Compression ||
+		c.EnableDrainer != x.EnableDrainer || c.MaxDrainBuffer != x.MaxDrainBuffer ||
+		c.MaxDrainLatancy != x.MaxDrainLatancy || c.DrainStatisticWindow != x.DrainStatisticWindow ||
+		c.BulkThreshold != x.BulkThreshold ||
+		c.socketOptionsChanged(x)
+}
+
+// Reload applies new configuration in place. It reports false if backend should be recreated,
+// which happens only if bind or publish endpoint changed.
+// Tunable parameters apply to listener and established links directly. Links are restarted
+// if parameters fixed during handshake changed, and their publish endpoints are returned.
+func (t *TCP) Reload(creator BackendCreator) (restarted []string, reloaded bool) {
+	c, isStream := creator.(*tcpCreator)
+	if !isStream || c.network != t.network {
+		return nil, false
+	}
+	old, cfg := t.getConfig(), c.cfg
+	if cfg.Publish == "" {
+		cfg.Publish = cfg.Bind
+	}
+	if old.listenerChanged(&cfg) {
+		return nil, false
+	}
+	dialer, err := newStreamDialer(&cfg)
+	if err != nil {
+		t.log.Errorf("invalid dialing options. (err = \"%v\")", err)
+		return nil, false
+	}
+	wss, err := cfg.WSS.clientTLSConfig()
+	if err != nil {
+		t.log.Errorf("invalid dialing options. (err = \"%v\")", err)
+		return nil, false
+	}
+
+	// credentials.
+	if cfg.TLS != nil {
+		if err := t.reloadTLS(cfg.TLS); err != nil {
+			t.log.Errorf("reload certificates failure. keep previous ones. (err = \"%v\")", err)
+			cfg.TLS = old.TLS
+		} else {
+			t.log.Info("certificates reloaded.")
+		}
+	}
+	if t.keys.store(newPSKRing(&cfg.raw.PSK, cfg.raw)) {
+		t.log.Info("pre-shared keys reloaded.")
+	}
+	t.guard.configure(cfg.raw)
+
+	if !reflect.DeepEqual(old.RateLimit, cfg.RateLimit) {
+		t.limiter.Store(newEgressLimiter(cfg.RateLimit))
+	}
+	t.dialer.Store(streamDialer{ContextDialer: dialer, wss: wss})
+	t.config.Store(&cfg)
+
+	if old.socketOptionsChanged(&cfg) || old.HolePunch != cfg.HolePunch {
+		atomic.StoreInt32(&t.restartListener, 1)
+	}
+	if old.linkRestartRequired(&cfg) {
+		return t.restartLinks(), true
+	}
+	if old.SendBufferSize != cfg.SendBufferSize || old.KeepalivePeriod != cfg.KeepalivePeriod {
+		t.tuneLinks()
+	}
+	return nil, true
+}
+
+// tuneLinks applies TCP options to established links.
+func (t *TCP) tuneLinks() {
+	t.link.Range(func(k, v interface{}) bool {
+		link, _ := v.(*TCPLink)
+		if link == nil {
+			return true
+		}
+		link.lock.RLock()
+		if conn := link.conn; conn != nil {
+			t.tuneConn(t.log.WithField("link", link.publish), conn)
+		}
+		link.lock.RUnlock()
+		return true
+	})
+}
+
+// restartLinks closes established links, which will be re-established with new configuration on demand.
+func (t *TCP) restartLinks() (restarted []string) {
+	t.link.Range(func(k, v interface{}) bool {
+		link, _ := v.(*TCPLink)
+		if link == nil {
+			return true
+		}
+		link.lock.Lock()
+		if link.Active() {
+			restarted = append(restarted, k.(string))
+			link.writeLock.Lock()
+			link.close()
+			link.writeLock.Unlock()
+		}
+		link.lock.Unlock()
+		return true
+	})
+	sort.Strings(restarted)
+	return
+}
@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func TestTCPReload(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind string) (*TCP, *config.Backend) {
+		raw := &config.Backend{
+			PSK: "12345", Type: "tcp",
+			Parameters: map[string]interface{}{"bind": bind},
+		}
+		creator, err := GetCreator("tcp", raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := creator.New(arbiter, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.(*TCP), raw
+	}
+	a, raw := newTCP("127.0.0.1:39995")
+	b, _ := newTCP("127.0.0.1:39996")
+
+	received := make(chan []byte, 1)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- append([]byte(nil), frame...)
+	})
+	send := func() {
+		var link Link
+		var err error
+		assert.True(t, waitForBackend(func() bool {
+			link, err = a.Connect(b.Publish())
+			return err == nil
+		}), "cannot connect to %v", b.Publish())
+		if link == nil {
+			return
+		}
+		payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatal("frame not delivered.")
+		}
+	}
+	reload := func() ([]string, bool) {
+		cfg := *raw // configurations are never modified once loaded.
+		creator, err := GetCreator("tcp", &cfg)
+		if !assert.NoError(t, err) {
+			return nil, false
+		}
+		return a.Reload(creator)
+	}
+	send()
+	established := a.getLink(b.Publish())
+
+	// tunables apply in place.
+	raw.Parameters["sendTimeout"] = 200
+	raw.Parameters["keepalivePeriod"] = 30
+	raw.Parameters["sendBuffer"] = 65536
+	raw.Parameters["rekeyBytes"] = 1 << 20
+	raw.Parameters["priority"] = 3
+	raw.Parameters["rateLimit"] = map[string]interface{}{"rate": 1 << 24}
+	restarted, reloaded := reload()
+	assert.True(t, reloaded)
+	assert.Empty(t, restarted)
+	assert.Equal(t, time.Millisecond*200, a.getSendTimeout())
+	assert.Equal(t, uint64(1<<20), a.getRekeyBytes())
+	assert.Equal(t, uint32(3), a.Priority())
+	assert.NotNil(t, a.getLimiter())
+	assert.True(t, established.Active())
+	send()
+	assert.True(t, established == a.getLink(b.Publish()))
+
+	// negotiated parameters restart links.
+	raw.Parameters["compression"] = CompressionSnappy
+	restarted, reloaded = reload()
+	assert.True(t, reloaded)
+	assert.Equal(t, []string{b.Publish()}, restarted)
+	send()
+	links := a.Links()
+	if assert.Equal(t, 1, len(links)) {
+		assert.Equal(t, CompressionSnappy, links[0].Compression)
+	}
+
+	// new publish endpoint requires new backend.
+	raw.Parameters["publish"] = "127.0.0.2:39995"
+	_, reloaded = reload()
+	assert.False(t, reloaded)
+}
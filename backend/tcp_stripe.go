@@ -0,0 +1,190 @@
+package backend
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/crossmesh/fabric/proto"
+)
+
+const (
+	defaultStripes    = 1
+	maxStripes        = 64
+	stripeRetryPeriod = time.Second * 3
+)
+
+func (t *TCP) getStripes() int {
+	n := int(getDefaultUint32(t.getConfig().Stripes, defaultStripes))
+	if n > maxStripes {
+		n = maxStripes
+	}
+	return n
+}
+
+// stripeKey returns link key of stripe. The first stripe is keyed by publish endpoint,
+// so that it's interoperable with peers without striping.
+func stripeKey(publish string, stripe uint8) string {
+	if stripe == 0 {
+		return publish
+	}
+	return publish + "#" + strconv.FormatUint(uint64(stripe), 10)
+}
+
+// stripePublish returns publish endpoint of stripe link key.
+func stripePublish(key string) string {
+	idx := strings.LastIndexByte(key, '#')
+	if idx < 0 {
+		return key
+	}
+	if _, err := strconv.ParseUint(key[idx+1:], 10, 8); err != nil {
+		return key
+	}
+	return key[:idx]
+}
+
+// flowHash hashes flow identifier of frame, so that frames of one flow go through the same stripe.
+// Frames other than raw network frames are hashed to zero.
+func flowHash(frame []byte) uint32 {
+	typeID, payload := proto.UnpackProtocolMessageHeader(frame)
+	if typeID != proto.MsgTypeRawFrame {
+		return 0
+	}
+	h := fnv.New32a()
+	if len(payload) > 0 {
+		// L3 packet with exact length.
+		switch payload[0] >> 4 {
+		case 4:
+			if len(payload) >= 20 && int(binary.BigEndian.Uint16(payload[2:4])) == len(payload) {
+				hashIPv4Flow(h, payload)
+				return h.Sum32()
+			}
+		case 6:
+			if len(payload) >= 40 && int(binary.BigEndian.Uint16(payload[4:6]))+40 == len(payload) {
+				hashIPv6Flow(h, payload)
+				return h.Sum32()
+			}
+		}
+	}
+	// L2 frame.
+	if len(payload) < 14 {
+		return 0
+	}
+	etherType, packet := binary.BigEndian.Uint16(payload[12:14]), payload[14:]
+	if etherType == 0x8100 && len(packet) >= 4 { // 802.1Q.
+		h.Write(payload[14:16])
+		etherType, packet = binary.BigEndian.Uint16(packet[2:4]), packet[4:]
+	}
+	switch {
+	case etherType == 0x0800 && len(packet) >= 20:
+		hashIPv4Flow(h, packet)
+	case etherType == 0x86DD && len(packet) >= 40:
+		hashIPv6Flow(h, packet)
+	default:
+		h.Write(payload[0:12])
+	}
+	return h.Sum32()
+}
+
+func hashIPv4Flow(h hash.Hash32, packet []byte) {
+	h.Write(packet[9:10])  // protocol.
+	h.Write(packet[12:20]) // addresses.
+	headerLen := int(packet[0]&0x0F) * 4
+	if headerLen < 20 || headerLen > len(packet) ||
+		binary.BigEndian.Uint16(packet[6:8])&0x3FFF != 0 { // fragmented. ports not avaliable.
+		return
+	}
+	hashPorts(h, packet[9], packet[headerLen:])
+}
+
+func hashIPv6Flow(h hash.Hash32, packet []byte) {
+	h.Write(packet[6:7])  // next header.
+	h.Write(packet[8:40]) // addresses.
+	hashPorts(h, packet[6], packet[40:])
+}
+
+func hashPorts(h hash.Hash32, protocol byte, segment []byte) {
+	switch protocol {
+	case 6, 17, 132: // TCP, UDP, SCTP.
+		if len(segment) >= 4 {
+			h.Write(segment[0:4])
+		}
+	}
+}
+
+// tcpStripedLink spreads frames over parallel connections to a peer.
+type tcpStripedLink struct {
+	backend *TCP
+	addr    net.Addr
+	publish string
+	stripes []*TCPLink
+}
+
+func (t *TCP) getStripedLink(addr net.Addr, publish string, n int) *tcpStripedLink {
+	if v, ok := t.stripes.Load(publish); ok {
+		if link, _ := v.(*tcpStripedLink); link != nil && len(link.stripes) == n {
+			return link
+		}
+	}
+	link := &tcpStripedLink{
+		backend: t,
+		addr:    addr,
+		publish: publish,
+		stripes: make([]*TCPLink, n),
+	}
+	for i := range link.stripes {
+		link.stripes[i] = t.getLink(stripeKey(publish, uint8(i)))
+	}
+	t.stripes.Store(publish, link)
+	return link
+}
+
+// goReconnect re-establishes failed stripe in background.
+func (s *tcpStripedLink) goReconnect(stripe int) {
+	t, link := s.backend, s.stripes[stripe]
+	now := time.Now().UnixNano()
+	retryAfter := atomic.LoadInt64(&link.retryAfter)
+	if now < retryAfter || !atomic.CompareAndSwapInt64(&link.retryAfter, retryAfter, now+int64(stripeRetryPeriod)) {
+		return
+	}
+	t.Arbiter.Go(func() {
+		if _, err := t.connect(s.addr, s.publish, uint8(stripe)); err != nil {
+			t.log.Warnf("stripe %v to %v not established: %v", stripe, s.publish, err)
+		}
+	})
+}
+
+// Send sends data frame via stripe chosen by flow hash.
+// Frames fall back to next active stripe when chosen one fails.
+func (s *tcpStripedLink) Send(frame []byte) error {
+	n := len(s.stripes)
+	chosen := int(flowHash(frame) % uint32(n))
+	for i := 0; i < n; i++ {
+		stripe := (chosen + i) % n
+		if link := s.stripes[stripe]; link.writable() {
+			return link.Send(frame)
+		}
+		s.goReconnect(stripe)
+	}
+	// all stripes are down.
+	link, err := s.backend.connect(s.addr, s.publish, 0)
+	if err != nil {
+		return err
+	}
+	return link.Send(frame)
+}
+
+// Close terminates all stripes.
+func (s *tcpStripedLink) Close() (err error) {
+	for _, link := range s.stripes {
+		if cerr := link.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return
+}
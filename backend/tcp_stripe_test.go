@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/proto"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func newTestIPv4Frame(srcPort, dstPort uint16, payloadLen int) []byte {
+	packet := make([]byte, 28+payloadLen)
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	packet[9] = 17 // UDP.
+	copy(packet[12:16], []byte{10, 0, 0, 1})
+	copy(packet[16:20], []byte{10, 0, 0, 2})
+	binary.BigEndian.PutUint16(packet[20:22], srcPort)
+	binary.BigEndian.PutUint16(packet[22:24], dstPort)
+	frame := make([]byte, proto.ProtocolMessageHeaderSize)
+	proto.PackProtocolMessageHeader(frame, proto.MsgTypeRawFrame)
+	return append(frame, packet...)
+}
+
+func TestFlowHash(t *testing.T) {
+	// packets in one flow.
+	assert.Equal(t, flowHash(newTestIPv4Frame(1000, 53, 10)), flowHash(newTestIPv4Frame(1000, 53, 100)))
+
+	hashes := make(map[uint32]struct{})
+	for port := uint16(1000); port < 1064; port++ {
+		hashes[flowHash(newTestIPv4Frame(port, 53, 10))] = struct{}{}
+	}
+	assert.True(t, len(hashes) > 1)
+
+	// L2 frame.
+	frame := make([]byte, proto.ProtocolMessageHeaderSize, 64)
+	proto.PackProtocolMessageHeader(frame, proto.MsgTypeRawFrame)
+	frame = append(frame, []byte{0x02, 0, 0, 0, 0, 1, 0x02, 0, 0, 0, 0, 2, 0x08, 0x00}...)
+	packet := newTestIPv4Frame(1000, 53, 10)[proto.ProtocolMessageHeaderSize:]
+	assert.Equal(t, flowHash(append(frame, packet...)), flowHash(newTestIPv4Frame(1000, 53, 10)))
+
+	// control messages.
+	assert.Equal(t, uint32(0), flowHash([]byte{0, 0, byte(proto.MsgTypeGossip), 1, 2, 3}))
+}
+
+func TestStripeKey(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:3880", stripePublish(stripeKey("127.0.0.1:3880", 0)))
+	assert.Equal(t, "127.0.0.1:3880", stripePublish(stripeKey("127.0.0.1:3880", 3)))
+	assert.Equal(t, "127.0.0.1:3880/a#b", stripePublish("127.0.0.1:3880/a#b"))
+}
+
+func TestTCPStripes(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newTCP := func(bind string) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, Stripes: 4, raw: raw}
+		b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a, b := newTCP("127.0.0.1:39940"), newTCP("127.0.0.1:39941")
+
+	received := make(chan []byte, 256)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- append([]byte(nil), frame...)
+	})
+
+	var (
+		link Link
+		err  error
+	)
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+	striped, isStriped := link.(*tcpStripedLink)
+	assert.True(t, isStriped)
+	if !isStriped {
+		return
+	}
+
+	send := func() {
+		for port := uint16(1000); port < 1064; port++ {
+			assert.NoError(t, link.Send(newTestIPv4Frame(port, 53, 10)))
+			select {
+			case <-received:
+			case <-time.After(time.Second * 5):
+				t.Fatalf("frame of port %v not delivered.", port)
+			}
+		}
+	}
+	send()
+	assert.True(t, waitForBackend(func() bool { return len(a.Links()) == 4 && len(b.Links()) == 4 }))
+
+	// failed stripe is re-established independently.
+	striped.stripes[2].Close()
+	assert.True(t, striped.stripes[0].Active())
+	deadline := time.Now().Add(stripeRetryPeriod * 3)
+	for !striped.stripes[2].Active() && time.Now().Before(deadline) {
+		send()
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.True(t, striped.stripes[2].Active())
+}
@@ -0,0 +1,262 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+
+	"github.com/crossmesh/fabric/config"
+)
+
+var (
+	ErrTLSNoPeerCertificate = errors.New("no peer certificate")
+	ErrTLSInvalidCA         = errors.New("no valid certificate found in CA file")
+	ErrTLSInvalidCRL        = errors.New("no revocation list signed by CA found in CRL file")
+	ErrTLSRevoked           = errors.New("peer certificate revoked")
+)
+
+// TCPTLSConfig describes mutual TLS parameters of TCP backend.
+type TCPTLSConfig struct {
+	CA   string `json:"ca" yaml:"ca"`     // PEM file containing trusted CA certificates.
+	Cert string `json:"cert" yaml:"cert"` // PEM file containing certificate chain.
+	Key  string `json:"key" yaml:"key"`   // PEM file containing private key.
+	CRL  string `json:"crl" yaml:"crl"`   // PEM file containing revocation lists signed by CA. optional.
+}
+
+type tcpTLSMaterial struct {
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+	revoked map[string]struct{} // revoked certificates keyed by revocationKey().
+}
+
+func loadTCPTLSMaterial(cfg *TCPTLSConfig) (*tcpTLSMaterial, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("load key pair failure (%v)", err)
+	}
+	caPEM, err := ioutil.ReadFile(cfg.CA)
+	if err != nil {
+		return nil, fmt.Errorf("load CA failure (%v)", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, ErrTLSInvalidCA
+	}
+	material := &tcpTLSMaterial{cert: &cert, roots: roots}
+	if cfg.CRL != "" {
+		if material.revoked, err = loadCRL(cfg.CRL, caPEM); err != nil {
+			return nil, err
+		}
+	}
+	return material, nil
+}
+
+func revocationKey(rawIssuer []byte, serial *big.Int) string {
+	return string(rawIssuer) + "/" + serial.String()
+}
+
+// loadCRL loads certificates revoked by revocation lists in PEM file. Lists should be signed by CA.
+// Lists are applied regardless of their next update time, so they should be renewed by reloading.
+func loadCRL(path string, caPEM []byte) (map[string]struct{}, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load CRL failure (%v)", err)
+	}
+	var cas []*x509.Certificate
+	for block, rest := pem.Decode(caPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if ca, err := x509.ParseCertificate(block.Bytes); err == nil {
+			cas = append(cas, ca)
+		}
+	}
+	signedByCA := func(list *x509.RevocationList) bool {
+		for _, ca := range cas {
+			if list.CheckSignatureFrom(ca) == nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	revoked, lists := make(map[string]struct{}), 0
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		list, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRL (%v)", err)
+		}
+		if !signedByCA(list) {
+			return nil, ErrTLSInvalidCRL
+		}
+		for _, entry := range list.RevokedCertificates {
+			revoked[revocationKey(list.RawIssuer, entry.SerialNumber)] = struct{}{}
+		}
+		lists++
+	}
+	if lists < 1 {
+		return nil, ErrTLSInvalidCRL
+	}
+	return revoked, nil
+}
+
+// TLSFingerprint digests credential files of mutual TLS configured for backend, so that updated
+// certificates could be detected while configuration is unchanged. Empty if TLS is not configured.
+func TLSFingerprint(cfg *config.Backend) string {
+	creator, err := GetCreator(cfg.Type, cfg)
+	if err != nil {
+		return ""
+	}
+	c, isStream := creator.(*tcpCreator)
+	if !isStream || c.cfg.TLS == nil {
+		return ""
+	}
+	h, paths := sha256.New(), []string{c.cfg.TLS.CA, c.cfg.TLS.Cert, c.cfg.TLS.Key}
+	if c.cfg.TLS.CRL != "" {
+		paths = append(paths, c.cfg.TLS.CRL)
+	}
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			content = []byte(err.Error())
+		}
+		digest := sha256.Sum256(content)
+		h.Write(digest[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// tlsStreamConn is TLS connection over stream connection.
+type tlsStreamConn struct {
+	*tls.Conn
+	raw streamConn
+}
+
+func (c *tlsStreamConn) SetWriteBuffer(bytes int) error { return c.raw.SetWriteBuffer(bytes) }
+
+// tcpConnOf returns underlying TCP connection.
+func tcpConnOf(conn streamConn) (*net.TCPConn, bool) {
+	if tlsConn, isTLS := conn.(*tlsStreamConn); isTLS {
+		conn = tlsConn.raw
+	}
+	tcpConn, isTCP := conn.(*net.TCPConn)
+	return tcpConn, isTCP
+}
+
+func (t *TCP) tlsEnabled() bool { return t.getConfig().TLS != nil }
+
+func (t *TCP) reloadTLS(cfg *TCPTLSConfig) error {
+	material, err := loadTCPTLSMaterial(cfg)
+	if err != nil {
+		return err
+	}
+	t.tlsMaterial.Store(material)
+	return nil
+}
+
+func (t *TCP) getTLSMaterial() *tcpTLSMaterial {
+	material, _ := t.tlsMaterial.Load().(*tcpTLSMaterial)
+	return material
+}
+
+// verifyTLSChain verifies peer certificate chain with current CA. Peer certificate should be
+// issued for usage of its role, that is, client authentication for accepted peers and server
+// authentication for dialed ones. Certificates presented are rejected if any of them is revoked.
+// Not using builtin verification so that CA could be reloaded in place.
+func (t *TCP) verifyTLSChain(usage x509.ExtKeyUsage, rawCerts [][]byte) error {
+	if len(rawCerts) < 1 {
+		return ErrTLSNoPeerCertificate
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	material := t.getTLSMaterial()
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         material.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}); err != nil {
+		return err
+	}
+	for _, cert := range certs {
+		if _, revoked := material.revoked[revocationKey(cert.RawIssuer, cert.SerialNumber)]; revoked {
+			return ErrTLSRevoked
+		}
+	}
+	return nil
+}
+
+// newTLSConfig creates TLS configuration verifying peer certificate for given usage.
+func (t *TCP) newTLSConfig(peerUsage x509.ExtKeyUsage) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return t.getTLSMaterial().cert, nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return t.getTLSMaterial().cert, nil
+		},
+		ClientAuth:         tls.RequireAnyClientCert,
+		InsecureSkipVerify: true, // verified by VerifyPeerCertificate.
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return t.verifyTLSChain(peerUsage, rawCerts)
+		},
+	}
+}
+
+func (t *TCP) tlsServer(conn streamConn) (*tlsStreamConn, error) {
+	tlsConn := &tlsStreamConn{Conn: tls.Server(conn, t.newTLSConfig(x509.ExtKeyUsageClientAuth)), raw: conn}
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (t *TCP) tlsClient(conn streamConn, publish string) (*tlsStreamConn, error) {
+	tlsConn := &tlsStreamConn{Conn: tls.Client(conn, t.newTLSConfig(x509.ExtKeyUsageServerAuth)), raw: conn}
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	if err := tlsConn.verifyPeerIdentity(t.Type(), publish); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// verifyPeerIdentity checks whether peer certificate is issued to publish endpoint.
+// Certificate matches if the endpoint host is one of its DNS names or IP addresses,
+// or its common name is the endpoint or node name (e.g. "tcp:1.2.3.4:3880").
+func (c *tlsStreamConn) verifyPeerIdentity(ty Type, publish string) error {
+	certs := c.ConnectionState().PeerCertificates
+	if len(certs) < 1 {
+		return ErrTLSNoPeerCertificate
+	}
+	cert := certs[0]
+	if cn := cert.Subject.CommonName; cn != "" && (cn == publish || cn == ty.String()+":"+publish) {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(publish)
+	if err != nil {
+		host = publish
+	}
+	return cert.VerifyHostname(host)
+}
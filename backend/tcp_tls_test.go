@@ -0,0 +1,296 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func writeTestPEM(t *testing.T, path, ty string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: ty, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	writeTestPEM(t, filepath.Join(dir, name+".pem"), "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, dir: dir}
+}
+
+func (ca *testCA) issue(t *testing.T, file, cn string, ips ...net.IP) *TCPTLSConfig {
+	return ca.issueFor(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, file, cn, ips...)
+}
+
+func (ca *testCA) issueFor(t *testing.T, usages []x509.ExtKeyUsage, file, cn string, ips ...net.IP) *TCPTLSConfig {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  ips,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &TCPTLSConfig{
+		CA:   filepath.Join(ca.dir, ca.cert.Subject.CommonName+".pem"),
+		Cert: filepath.Join(ca.dir, file+".crt"),
+		Key:  filepath.Join(ca.dir, file+".key"),
+	}
+	writeTestPEM(t, cfg.Cert, "CERTIFICATE", der)
+	writeTestPEM(t, cfg.Key, "EC PRIVATE KEY", keyDER)
+	return cfg
+}
+
+// revoke writes revocation list of certificates issued.
+func (ca *testCA) revoke(t *testing.T, file string, issued ...*TCPTLSConfig) string {
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, cfg := range issued {
+		content, err := ioutil.ReadFile(cfg.Cert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode(content)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.RevokedCertificates = append(tmpl.RevokedCertificates, pkix.RevokedCertificate{
+			SerialNumber: cert.SerialNumber, RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(ca.dir, file+".crl")
+	writeTestPEM(t, path, "X509 CRL", der)
+	return path
+}
+
+func newTestTLSTCP(t *testing.T, arbiter *arbit.Arbiter, bind string, tlsCfg *TCPTLSConfig) *TCP {
+	encrypt := false
+	raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+	cfg := &TCPBackendConfig{Bind: bind, TLS: tlsCfg, raw: raw}
+	b, err := NewTCP(arbiter, nil, cfg, &raw.PSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestTCPTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utt-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, foreignCA := newTestCA(t, dir, "ca"), newTestCA(t, dir, "foreign-ca")
+	localhost := net.ParseIP("127.0.0.1")
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	a := newTestTLSTCP(t, arbiter, "127.0.0.1:39900", ca.issue(t, "a", "a", localhost))
+	b := newTestTLSTCP(t, arbiter, "127.0.0.1:39901", ca.issue(t, "b", "tcp:127.0.0.1:39901"))
+	// issued to another endpoint.
+	c := newTestTLSTCP(t, arbiter, "127.0.0.1:39902", ca.issue(t, "c", "tcp:127.0.0.1:1"))
+	// untrusted.
+	d := newTestTLSTCP(t, arbiter, "127.0.0.1:39903", foreignCA.issue(t, "d", "d", localhost))
+
+	received := make(chan []byte, 1)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		assert.Equal(t, a.Publish(), src)
+		received <- append([]byte(nil), frame...)
+	})
+
+	var link Link
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link == nil {
+		return
+	}
+	send := func() {
+		payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatal("frame not delivered.")
+		}
+	}
+	send()
+
+	t.Run("identity", func(t *testing.T) {
+		_, err := a.Connect(c.Publish())
+		assert.Error(t, err)
+		// server denies after connect request.
+		c.Connect(a.Publish())
+		time.Sleep(time.Millisecond * 200)
+		assert.False(t, a.getLink(c.Publish()).Active())
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		_, err := a.Connect(d.Publish())
+		assert.Error(t, err)
+		_, err = d.Connect(a.Publish())
+		assert.Error(t, err)
+	})
+
+	t.Run("key_usage", func(t *testing.T) {
+		// not issued for client authentication.
+		e := newTestTLSTCP(t, arbiter, "127.0.0.1:39904", ca.issueFor(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, "e", "e", localhost))
+		assert.True(t, waitForBackend(func() bool {
+			_, err := a.Connect(e.Publish())
+			return err == nil
+		}), "cannot connect to %v", e.Publish())
+		_, err := e.Connect(a.Publish())
+		assert.Error(t, err)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		revokedCfg := ca.issue(t, "f", "f", localhost)
+		f := newTestTLSTCP(t, arbiter, "127.0.0.1:39905", revokedCfg)
+		gCfg := ca.issue(t, "g", "g", localhost)
+		gCfg.CRL = ca.revoke(t, "ca", revokedCfg)
+		g := newTestTLSTCP(t, arbiter, "127.0.0.1:39906", gCfg)
+
+		assert.True(t, waitForBackend(func() bool {
+			_, err := a.Connect(g.Publish())
+			return err == nil
+		}), "cannot connect to %v", g.Publish())
+		_, err := f.Connect(g.Publish())
+		assert.Error(t, err)
+		_, err = g.Connect(f.Publish())
+		assert.Error(t, err)
+
+		_, err = loadCRL(gCfg.CRL, nil) // not signed by CA.
+		assert.Equal(t, ErrTLSInvalidCRL, err)
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		raw := &config.Backend{
+			PSK: "12345", Encrypt: a.getConfig().raw.Encrypt, Type: "tcp",
+			Parameters: map[string]interface{}{
+				"bind": "127.0.0.1:39900",
+				"tls":  ca.issue(t, "a2", "a", localhost),
+			},
+		}
+		creator, err := GetCreator("tcp", raw)
+		if !assert.NoError(t, err) {
+			return
+		}
+		restarted, reloaded := a.Reload(creator)
+		assert.True(t, reloaded)
+		assert.Empty(t, restarted)
+		assert.Equal(t, filepath.Join(dir, "a2.crt"), a.getConfig().TLS.Cert)
+		send() // link kept.
+
+		raw.Parameters["sendTimeout"] = 100
+		creator, err = GetCreator("tcp", raw)
+		if !assert.NoError(t, err) {
+			return
+		}
+		restarted, reloaded = a.Reload(creator)
+		assert.True(t, reloaded)
+		assert.Empty(t, restarted)
+		assert.Equal(t, time.Millisecond*100, a.getSendTimeout())
+		send() // link kept.
+
+		raw.Parameters["bind"] = "127.0.0.1:39909"
+		creator, err = GetCreator("tcp", raw)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, reloaded = a.Reload(creator)
+		assert.False(t, reloaded)
+	})
+}
+
+func TestTLSFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utt-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir, "ca")
+	raw := &config.Backend{
+		PSK: "12345", Type: "tcp",
+		Parameters: map[string]interface{}{
+			"bind": "127.0.0.1:39900",
+			"tls":  ca.issue(t, "a", "a"),
+		},
+	}
+	fingerprint := TLSFingerprint(raw)
+	assert.NotEmpty(t, fingerprint)
+	assert.Equal(t, fingerprint, TLSFingerprint(raw))
+
+	ca.issue(t, "a", "a") // renewed.
+	assert.NotEqual(t, fingerprint, TLSFingerprint(raw))
+
+	delete(raw.Parameters, "tls")
+	assert.Empty(t, TLSFingerprint(raw))
+}
@@ -0,0 +1,594 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	logging "github.com/sirupsen/logrus"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+const (
+	defaultUDPKeepalivePeriod = 10
+	defaultUDPBufferSize      = 65536
+
+	// maximum payload of a IPv4 UDP datagram.
+	maxUDPDatagramSize = 65507
+)
+
+// UDPBackendConfig describes UDP backend parameters.
+type UDPBackendConfig struct {
+	Bind     string `json:"bind" yaml:"bind"`
+	Publish  string `json:"publish" yaml:"publish"`
+	Priority uint32 `json:"priority" yaml:"priority"`
+
+	SendBufferSize  int    `json:"sendBuffer" yaml:"sendBuffer" default:"0"`
+	RecvBufferSize  int    `json:"recvBuffer" yaml:"recvBuffer" default:"0"`
+	KeepalivePeriod int    `json:"keepalivePeriod" yaml:"keepalivePeriod" default:"10"`
+	ConnectTimeout  uint32 `json:"connectTimeout" yaml:"connectTimeout" default:"15"`
+	SendTimeout     uint32 `json:"sendTimeout" yaml:"sendTimeout" default:"50"`
+
+	// egress rate limits. unlimited if absent.
+	RateLimit *RateLimitConfig `json:"rateLimit" yaml:"rateLimit"`
+
+	raw *config.Backend
+}
+
+type udpCreator struct {
+	cfg UDPBackendConfig
+}
+
+func newUDPCreator(cfg *config.Backend) (BackendCreator, error) {
+	c := &udpCreator{}
+	if cfg.Parameters == nil {
+		return nil, ErrInvalidBackendConfig
+	}
+	// re-parse
+	bin, err := json.Marshal(cfg.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("parse backend config failure (%v)", err)
+	}
+	if err = json.Unmarshal(bin, &c.cfg); err != nil {
+		return nil, fmt.Errorf("parse backend config failure (%v)", err)
+	}
+	if c.cfg.Publish == "" {
+		c.cfg.Publish = c.cfg.Bind
+	}
+	if c.cfg.Publish, err = NormalizeEndpoint(UDPBackend, c.cfg.Publish); err != nil {
+		return nil, err
+	}
+	c.cfg.raw = cfg
+	return c, nil
+}
+
+func (c *udpCreator) Type() Type       { return UDPBackend }
+func (c *udpCreator) Priority() uint32 { return c.cfg.Priority }
+func (c *udpCreator) Publish() string  { return c.cfg.Publish }
+func (c *udpCreator) New(arbiter *arbit.Arbiter, log *logging.Entry) (Backend, error) {
+	return NewUDP(arbiter, log, &c.cfg, &c.cfg.raw.PSK)
+}
+
+var (
+	ErrUDPFrameTooLarge    = errors.New("frame too large for udp datagram")
+	ErrUDPNotListening     = errors.New("udp socket not ready")
+	ErrUDPHandshakeTimeout = errors.New("udp handshake timeout")
+	ErrInvalidUDPPacket    = errors.New("invalid udp packet")
+	ErrUDPReplayedPacket   = errors.New("replayed udp packet")
+)
+
+// UDP implements UDP backend.
+type UDP struct {
+	bind atomic.Value // bindAddr. written by serving routine while read by others.
+	conn *net.UDPConn
+
+	config  atomic.Value // *UDPBackendConfig. replaced on reload.
+	keys    pskKeeper
+	limiter atomic.Value // *egressLimiter
+	guard   *helloGuard  // replay protection only. sources of datagrams can be spoofed, so never banned.
+
+	log *logging.Entry
+
+	lock         sync.RWMutex
+	links        map[string]*UDPLink // remote address --> link
+	resolveCache sync.Map
+
+	watch sync.Map
+
+	Arbiter *arbit.Arbiter
+}
+
+// NewUDP creates UDP backend.
+func NewUDP(arbiter *arbit.Arbiter, log *logging.Entry, cfg *UDPBackendConfig, psk *string) (t *UDP, err error) {
+	if log == nil {
+		log = logging.WithField("module", "backend_udp")
+	}
+	t = &UDP{
+		log:   log,
+		guard: newHelloGuard(cfg.raw),
+		links: make(map[string]*UDPLink),
+	}
+	t.keys.store(newPSKRing(psk, cfg.raw))
+	if cfg.Publish == "" {
+		cfg.Publish = cfg.Bind
+	}
+	t.config.Store(cfg)
+	t.limiter.Store(newEgressLimiter(cfg.RateLimit))
+	t.Arbiter = arbit.NewWithParent(arbiter)
+	t.Arbiter.Go(func() {
+		var err error
+
+		for t.Arbiter.ShouldRun() {
+			if err != nil {
+				log.Info("retry in 3 second.")
+				time.Sleep(time.Second * 3)
+			}
+			err = nil
+
+			var bind *net.UDPAddr
+			if bind, err = net.ResolveUDPAddr("udp", cfg.Bind); err != nil {
+				log.Error("resolve bind address failure: ", err)
+				continue
+			}
+			t.bind.Store(bindAddr{bind})
+
+			t.serve()
+		}
+	})
+	t.goKeepalive()
+
+	return t, nil
+}
+
+func (t *UDP) getConfig() *UDPBackendConfig { return t.config.Load().(*UDPBackendConfig) }
+
+func (t *UDP) getLimiter() *egressLimiter { return t.limiter.Load().(*egressLimiter) }
+
+// getBind returns resolved bind address. It's nil before resolved.
+func (t *UDP) getBind() *net.UDPAddr {
+	v, _ := t.bind.Load().(bindAddr)
+	addr, _ := v.Addr.(*net.UDPAddr)
+	return addr
+}
+
+func (t *UDP) getSendTimeout() time.Duration {
+	return time.Duration(getDefaultUint32(t.getConfig().SendTimeout, defaultSendTimeout)) * time.Millisecond
+}
+
+// Priority returns priority of backend.
+func (t *UDP) Priority() uint32 {
+	return t.getConfig().Priority
+}
+
+// Type returns backend type ID.
+func (t *UDP) Type() Type {
+	return UDPBackend
+}
+
+// Publish returns publish endpoint.
+func (t *UDP) Publish() (id string) {
+	return t.getConfig().Publish
+}
+
+// Port retuens local bind port of udp backend.
+func (t *UDP) Port() uint16 {
+	if bind := t.getBind(); bind != nil {
+		return uint16(bind.Port)
+	}
+	return 0
+}
+
+// MaxFrameSize returns max size of frame fitting in a datagram.
+func (t *UDP) MaxFrameSize() int { return maxUDPDatagramSize - udpPacketOverhead }
+
+// IP returns bind IP.
+func (t *UDP) IP() net.IP {
+	if bind := t.getBind(); bind != nil {
+		return bind.IP
+	}
+	return nil
+}
+
+// Shutdown closes backend.
+func (t *UDP) Shutdown() {
+	t.Arbiter.Shutdown()
+	t.Arbiter.Join()
+}
+
+// Watch registers callback to receive packet.
+func (t *UDP) Watch(proc func(Backend, []byte, string)) error {
+	if proc != nil {
+		t.watch.Store(&proc, proc)
+	}
+	return nil
+}
+
+func (t *UDP) getConnectTimeout() time.Duration {
+	return time.Duration(getDefaultUint32(t.getConfig().ConnectTimeout, defaultConnectTimeout)) * time.Millisecond
+}
+
+func (t *UDP) getKeepalivePeriod() time.Duration {
+	period := t.getConfig().KeepalivePeriod
+	if period < 1 {
+		period = defaultUDPKeepalivePeriod
+	}
+	return time.Duration(period) * time.Second
+}
+
+func (t *UDP) getRoutinesCount() (n uint) {
+	return t.getConfig().raw.GetMaxConcurrency()
+}
+
+func (t *UDP) serve() (err error) {
+	bind := t.getBind()
+	for t.Arbiter.ShouldRun() {
+		if err != nil {
+			time.Sleep(time.Second * 5)
+		}
+		err = nil
+
+		var conn *net.UDPConn
+		if conn, err = net.ListenUDP("udp", bind); err != nil {
+			t.log.Errorf("cannot listen to \"%v\": %v", bind.String(), err)
+			continue
+		}
+		t.tuneConn(conn)
+		t.log.Infof("listening to %v", bind.String())
+
+		t.lock.Lock()
+		t.conn = conn
+		t.lock.Unlock()
+
+		err = t.receiveDatagrams(conn)
+
+		t.lock.Lock()
+		t.conn = nil
+		links := make([]*UDPLink, 0, len(t.links))
+		for _, link := range t.links {
+			links = append(links, link)
+		}
+		t.lock.Unlock()
+		for _, link := range links {
+			link.Close()
+		}
+		conn.Close()
+	}
+	return
+}
+
+// tuneConn applies socket buffer sizes to connection.
+func (t *UDP) tuneConn(conn *net.UDPConn) {
+	cfg := t.getConfig()
+	if bufSize := cfg.SendBufferSize; bufSize > 0 {
+		if err := conn.SetWriteBuffer(bufSize); err != nil {
+			t.log.Error("conn.SetWriteBuffer error: ", err)
+		}
+	}
+	if bufSize := cfg.RecvBufferSize; bufSize > 0 {
+		if err := conn.SetReadBuffer(bufSize); err != nil {
+			t.log.Error("conn.SetReadBuffer error: ", err)
+		}
+	}
+}
+
+func (t *UDP) receiveDatagrams(conn *net.UDPConn) error {
+	var wg sync.WaitGroup
+
+	t.log.Debugf("start receiving datagrams.")
+
+	for n := t.getRoutinesCount(); n > 0; n-- {
+		wg.Add(1)
+		t.Arbiter.Go(func() {
+			defer wg.Done()
+
+			buf := make([]byte, defaultUDPBufferSize)
+			for t.Arbiter.ShouldRun() {
+				if err := conn.SetReadDeadline(time.Now().Add(time.Second * 3)); err != nil {
+					t.log.Error("conn.SetReadDeadline() error: ", err)
+					break
+				}
+				read, addr, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+						continue
+					}
+					t.log.Error("conn.ReadFromUDP() error: ", err)
+					break
+				}
+				t.dispatch(addr, buf[:read])
+			}
+		})
+	}
+	wg.Wait()
+
+	t.log.Debugf("stop receiving datagrams.")
+
+	return nil
+}
+
+func (t *UDP) goKeepalive() {
+	t.Arbiter.TickGo(func(cancel func(), deadline time.Time) {
+		period := t.getKeepalivePeriod()
+		now := time.Now()
+
+		t.lock.RLock()
+		links := make([]*UDPLink, 0, len(t.links))
+		for _, link := range t.links {
+			links = append(links, link)
+		}
+		t.lock.RUnlock()
+
+		for _, link := range links {
+			link.keepalive(now, period)
+		}
+	}, time.Second, 1)
+}
+
+func (t *UDP) writeTo(packet []byte, addr *net.UDPAddr) (err error) {
+	t.lock.RLock()
+	conn := t.conn
+	t.lock.RUnlock()
+	if conn == nil {
+		return ErrUDPNotListening
+	}
+	_, err = conn.WriteToUDP(packet, addr)
+	return
+}
+
+func (t *UDP) getLink(addr *net.UDPAddr) (link *UDPLink) {
+	key := addr.String()
+
+	t.lock.RLock()
+	link, _ = t.links[key]
+	t.lock.RUnlock()
+	if link != nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if link, _ = t.links[key]; link == nil {
+		link = newUDPLink(t, addr)
+		t.links[key] = link
+	}
+	return
+}
+
+// Links reports live links.
+func (t *UDP) Links() (links []LinkInfo) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for _, link := range t.links {
+		link.lock.RLock()
+		if link.state == udpLinkEstablished {
+			links = append(links, LinkInfo{
+				Publish: link.publish,
+				Remote:  link.remote.String(),
+				PSKID:   link.pskID,
+				Stats:   link.stats.Stats(),
+			})
+		}
+		link.lock.RUnlock()
+	}
+	return
+}
+
+// LinkStats reports statistics of links by publish endpoint of peer.
+// Links never identified by publish endpoint are omitted.
+func (t *UDP) LinkStats() map[string]LinkStats {
+	stats := make(map[string]LinkStats)
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for _, link := range t.links {
+		link.lock.RLock()
+		publish := link.publish
+		link.lock.RUnlock()
+		if publish == "" {
+			continue
+		}
+		linkStats := link.stats.Stats()
+		sum := stats[publish]
+		sum.Add(&linkStats)
+		stats[publish] = sum
+	}
+	return stats
+}
+
+func (t *UDP) lookupLink(addr *net.UDPAddr) (link *UDPLink) {
+	t.lock.RLock()
+	link, _ = t.links[addr.String()]
+	t.lock.RUnlock()
+	return
+}
+
+func (t *UDP) deliver(frame []byte, publish string) {
+	t.watch.Range(func(k, v interface{}) bool {
+		if emit, ok := v.(func(Backend, []byte, string)); ok {
+			emit(t, frame, publish)
+		}
+		return true
+	})
+}
+
+func (t *UDP) dispatch(addr *net.UDPAddr, packet []byte) {
+	if len(packet) < 1 {
+		return
+	}
+	ty, payload := packet[0], packet[1:]
+	if ty == udpPacketHello {
+		t.onHello(addr, payload)
+		return
+	}
+	link := t.lookupLink(addr)
+	if link == nil {
+		return
+	}
+	link.onPacket(ty, payload)
+}
+
+func (t *UDP) resolve(endpoint string) (addr *net.UDPAddr, err error) {
+	v, ok := t.resolveCache.Load(endpoint)
+	if !ok || v == nil {
+		if addr, err = net.ResolveUDPAddr("udp", endpoint); err != nil {
+			t.log.Errorf("destination \"%v\" not resolved: %v", endpoint, err)
+			return nil, err
+		}
+		t.resolveCache.Store(endpoint, addr)
+	} else {
+		addr = v.(*net.UDPAddr)
+	}
+	return
+}
+
+// Reload applies new configuration in place. It reports false if bind or publish endpoint changed.
+// Established links are restarted only if encryption is switched.
+func (t *UDP) Reload(creator BackendCreator) (restarted []string, reloaded bool) {
+	c, isUDP := creator.(*udpCreator)
+	if !isUDP {
+		return nil, false
+	}
+	old, cfg := t.getConfig(), c.cfg
+	if cfg.Publish == "" {
+		cfg.Publish = cfg.Bind
+	}
+	if cfg.Bind != old.Bind || cfg.Publish != old.Publish {
+		return nil, false
+	}
+	if t.keys.store(newPSKRing(&cfg.raw.PSK, cfg.raw)) {
+		t.log.Info("pre-shared keys reloaded.")
+	}
+	t.guard.configure(cfg.raw)
+	if !reflect.DeepEqual(old.RateLimit, cfg.RateLimit) {
+		t.limiter.Store(newEgressLimiter(cfg.RateLimit))
+	}
+	t.config.Store(&cfg)
+
+	if cfg.SendBufferSize != old.SendBufferSize || cfg.RecvBufferSize != old.RecvBufferSize {
+		t.lock.RLock()
+		conn := t.conn
+		t.lock.RUnlock()
+		if conn != nil {
+			t.tuneConn(conn)
+		}
+	}
+	if cfg.raw.GetEncrypt() != old.raw.GetEncrypt() {
+		restarted = t.restartLinks()
+	}
+	return restarted, true
+}
+
+// restartLinks closes established links, which will be re-established with new configuration on demand.
+func (t *UDP) restartLinks() (restarted []string) {
+	t.lock.RLock()
+	links := make([]*UDPLink, 0, len(t.links))
+	for _, link := range t.links {
+		links = append(links, link)
+	}
+	t.lock.RUnlock()
+	for _, link := range links {
+		link.lock.RLock()
+		publish, established := link.publish, link.state == udpLinkEstablished
+		link.lock.RUnlock()
+		if !established {
+			continue
+		}
+		link.Close()
+		restarted = append(restarted, publish)
+	}
+	sort.Strings(restarted)
+	return
+}
+
+// Connect trys to establish data path to peer.
+func (t *UDP) Connect(endpoint string) (l Link, err error) {
+	if !t.Arbiter.ShouldRun() {
+		return nil, ErrOperationCanceled
+	}
+
+	var addr *net.UDPAddr
+	if addr, err = t.resolve(endpoint); err != nil {
+		return nil, err
+	}
+	link := t.getLink(addr)
+	link.lock.RLock()
+	established := link.state == udpLinkEstablished
+	link.lock.RUnlock()
+	if !established {
+		// peer behind NAT is reachable only at where its datagrams come from.
+		if published := t.getPublishedLink(endpoint); published != nil {
+			return published, nil
+		}
+	}
+	if err = link.connect(endpoint); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// getPublishedLink finds established link from peer with given publish endpoint.
+func (t *UDP) getPublishedLink(publish string) *UDPLink {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for _, link := range t.links {
+		link.lock.RLock()
+		established, linkPublish := link.state == udpLinkEstablished, link.publish
+		link.lock.RUnlock()
+		if established && linkPublish == publish {
+			return link
+		}
+	}
+	return nil
+}
+
+// ObservedAddress returns remote address of established link from peer.
+// Source of datagrams is where NAT maps peer to, regardless of who connects.
+func (t *UDP) ObservedAddress(publish string) string {
+	if link := t.getPublishedLink(publish); link != nil {
+		return link.remote.String()
+	}
+	return ""
+}
+
+// PunchPort reports the observed port is kept, since datagrams to all peers share one socket.
+func (t *UDP) PunchPort() (uint16, bool) {
+	return 0, true
+}
+
+// Punch establishes link to peer connecting us simultaneously.
+// Hellos of both sides open NAT mappings, and the one with lower HMAC becomes initiator.
+func (t *UDP) Punch(endpoint, identity string) (l Link, err error) {
+	if !t.Arbiter.ShouldRun() {
+		return nil, ErrOperationCanceled
+	}
+
+	var addr *net.UDPAddr
+	if addr, err = t.resolve(endpoint); err != nil {
+		return nil, err
+	}
+	link := t.getLink(addr)
+	link.lock.Lock()
+	if link.state == udpLinkEstablished {
+		link.lock.Unlock()
+		return link, nil
+	}
+	link.identity, link.publish = identity, endpoint
+	link.lock.Unlock()
+
+	t.log.Infof("punching to %v(%v)", identity, endpoint)
+	if err = link.connect(endpoint); err != nil {
+		link.lock.Lock()
+		if link.state != udpLinkEstablished {
+			link.identity = "" // later connections are not expected to be punched.
+		}
+		link.lock.Unlock()
+		return nil, err
+	}
+	return link, nil
+}
@@ -0,0 +1,410 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/crossmesh/fabric/cmd/version"
+	"github.com/crossmesh/fabric/mux"
+	"github.com/crossmesh/fabric/proto"
+)
+
+const (
+	udpPacketHello      = uint8(1)
+	udpPacketWelcome    = uint8(2)
+	udpPacketConnect    = uint8(3)
+	udpPacketConnectAck = uint8(4)
+	udpPacketData       = uint8(5)
+	udpPacketKeepalive  = uint8(6)
+
+	udpHandshakeRetryInterval = time.Second
+)
+
+func (t *UDP) onHello(addr *net.UDPAddr, payload []byte) {
+	log := t.log.WithField("remote", addr.String())
+
+	hello := proto.Hello{}
+	if err := hello.Decode(payload); err != nil {
+		return
+	}
+	psk, accepted := t.keys.load().verify(&hello)
+	if !accepted {
+		log.Info("deined for authentication failure.")
+		return
+	}
+
+	link := t.getLink(addr)
+
+	link.lock.Lock()
+	defer link.lock.Unlock()
+
+	if p := link.pending; p != nil && bytes.Equal(p.hello.HMAC[:], hello.HMAC[:]) {
+		// welcome may be lost. send again.
+		link.sendPendingWelcome()
+		return
+	}
+	if link.hello != nil {
+		if bytes.Equal(link.hello.HMAC[:], hello.HMAC[:]) {
+			if link.state == udpLinkAccepting {
+				// welcome may be lost. send again.
+				link.sendWelcome()
+			}
+			return
+		}
+
+		switch link.state {
+		case udpLinkHelloSent:
+			// both sides are connecting. the one with lower HMAC wins the initiator role.
+			if bytes.Compare(hello.HMAC[:], link.hello.HMAC[:]) >= 0 {
+				return
+			}
+			log.Debug("yield to simultaneous connecting from foreign peer.")
+
+		case udpLinkWelcomed:
+			// we have been already accepted by peer.
+			return
+		}
+	}
+
+	if err := t.guard.check(&hello, time.Now()); err != nil {
+		log.Warn("deined for authentication failure: ", err)
+		return
+	}
+	log.Debug("authentication success.")
+
+	key := helloSessionKey(&hello, psk)
+	aead, err := newUDPSessionAEAD(key)
+	if err != nil {
+		log.Error("cipher initializion failure: ", err)
+		return
+	}
+	kxKey, err := newHandshakeKey()
+	if err != nil {
+		log.Error("cannot generate key for key exchange: ", err)
+		return
+	}
+	if link.state == udpLinkEstablished {
+		// hello may be replayed. keep established session until foreign peer proves the new one.
+		log.Info("foreign peer restarts handshaking.")
+		link.pending = &udpPendingSession{
+			hello: &hello, key: key, aead: aead, kxKey: kxKey,
+			pskID: PSKID(psk),
+		}
+		link.sendPendingWelcome()
+		return
+	}
+	link.resetSession()
+	link.hello, link.key, link.aead, link.kxKey, link.initiator = &hello, key, aead, kxKey, false
+	link.pskID = PSKID(psk)
+	link.state = udpLinkAccepting
+	link.sendWelcome()
+}
+
+func newUDPSessionAEAD(key [32]byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (l *UDPLink) sendWelcome() {
+	l.sendWelcomeWith(l.aead, l.nonceRole(), &l.sendCounter, l.kxKey)
+}
+
+func (l *UDPLink) sendPendingWelcome() {
+	p := l.pending
+	l.sendWelcomeWith(p.aead, udpNonceRoleAcceptor, &p.sendCounter, p.kxKey)
+}
+
+func (l *UDPLink) sendWelcomeWith(aead cipher.AEAD, role byte, counter *uint64, kxKey *ecdh.PrivateKey) {
+	welcome := proto.Welcome{
+		Welcome:  true,
+		Identity: l.backend.getConfig().Publish,
+	}
+	if welcome.Identity == "" {
+		l.backend.log.Error("empty publish endpoint")
+		return
+	}
+	welcome.EncodeMessage("ok")
+	if kxKey != nil {
+		welcome.Features.Enable(version.LinkKeyExchange)
+		welcome.PublicKey = kxKey.PublicKey().Bytes()
+	}
+	fb := mux.NewFrameBuffer(0)
+	defer fb.Release()
+	l.sendSealedWith(aead, role, counter, udpPacketWelcome, welcome.Encode(fb.Bytes()))
+}
+
+func (l *UDPLink) sendHello() {
+	fb := mux.NewFrameBuffer(0)
+	defer fb.Release()
+	buf := append(fb.Bytes(), udpPacketHello)
+	buf = append(buf, l.hello.Encode(nil)...)
+	if err := l.backend.writeTo(buf, l.remote); err != nil {
+		l.backend.log.Error("send hello failure: ", err)
+	}
+}
+
+func (l *UDPLink) sendConnect() {
+	connectReq := proto.Connect{
+		Identity: l.backend.getConfig().Publish,
+		Version:  l.version,
+	}
+	if connectReq.Identity == "" {
+		l.backend.log.Error("empty publish endpoint")
+		return
+	}
+	if l.kxKey != nil {
+		connectReq.Features.Enable(version.LinkKeyExchange)
+		connectReq.PublicKey = l.kxKey.PublicKey().Bytes()
+	}
+	fb := mux.NewFrameBuffer(0)
+	defer fb.Release()
+	l.sendSealed(udpPacketConnect, connectReq.Encode(fb.Bytes()))
+}
+
+func (l *UDPLink) sendConnectAck() {
+	l.sendSealed(udpPacketConnectAck, nil)
+}
+
+func (l *UDPLink) onWelcome(payload []byte) {
+	if l.state != udpLinkHelloSent {
+		return
+	}
+	frame, err := l.open(udpPacketWelcome, payload)
+	if err != nil {
+		return
+	}
+	welcome := proto.Welcome{}
+	if err = welcome.Decode(frame); err != nil {
+		l.backend.log.Error("corrupted welcome handshake packet.")
+		return
+	}
+	if !welcome.Welcome { // denied.
+		l.backend.log.Errorf("denied by remote peer %v.", l.remote)
+		l.resetSession()
+		l.finish(ErrConnectionDeined)
+		return
+	}
+	if l.identity != "" && welcome.Identity != l.identity {
+		l.backend.log.Errorf("expect peer \"%v\" at %v, but got \"%v\".", l.identity, l.remote, welcome.Identity)
+		l.resetSession()
+		l.finish(ErrPunchIdentityMismatch)
+		return
+	}
+
+	l.backend.log.Debug("good authentication. connecting...")
+	if l.backend.getConfig().raw.GetEncrypt() {
+		l.version = proto.ConnectAES256GCM
+		if welcome.Features.Enabled(version.LinkKeyExchange) {
+			kxKey, err := newHandshakeKey()
+			if err != nil {
+				l.backend.log.Error("cannot generate key for key exchange: ", err)
+				return
+			}
+			if err = l.exchangeKey(kxKey, welcome.PublicKey, welcome.PublicKey, kxKey.PublicKey().Bytes()); err != nil {
+				l.backend.log.Error("key exchange failure: ", err)
+				l.resetSession()
+				l.finish(err)
+				return
+			}
+			l.kxKey = kxKey
+		}
+	} else {
+		l.version = proto.ConnectNoCrypt
+	}
+	l.state = udpLinkWelcomed
+	l.sendConnect()
+}
+
+func (l *UDPLink) onConnect(payload []byte) {
+	switch l.state {
+	case udpLinkAccepting:
+	case udpLinkEstablished:
+		if l.pending != nil && l.acceptPending(payload) {
+			break
+		}
+		if !l.initiator {
+			// ack may be lost.
+			if _, err := l.open(udpPacketConnect, payload); err == nil {
+				l.sendConnectAck()
+			}
+		}
+		return
+	default:
+		return
+	}
+	frame, err := l.open(udpPacketConnect, payload)
+	if err != nil {
+		return
+	}
+	connectReq := proto.Connect{}
+	if err = connectReq.Decode(frame); err != nil {
+		l.backend.log.Error("corrupted connect handshake packet.")
+		return
+	}
+	switch connectReq.Version {
+	case proto.ConnectNoCrypt, proto.ConnectAES256GCM:
+	default:
+		l.backend.log.Errorf("invalid connecting protocol version %v.", connectReq.Version)
+		return
+	}
+	if l.identity == "" {
+		l.publish = connectReq.Identity
+	} else if connectReq.Identity != l.identity {
+		// punched link keeps endpoint it is bound to.
+		l.backend.log.Errorf("expect peer \"%v\" at %v, but got \"%v\".", l.identity, l.remote, connectReq.Identity)
+		return
+	}
+	if connectReq.Version != proto.ConnectNoCrypt && l.kxKey != nil &&
+		connectReq.Features.Enabled(version.LinkKeyExchange) {
+		if err = l.exchangeKey(l.kxKey, connectReq.PublicKey, l.kxKey.PublicKey().Bytes(), connectReq.PublicKey); err != nil {
+			l.backend.log.Error("key exchange failure: ", err)
+			return
+		}
+	}
+	l.version = connectReq.Version
+	l.sendConnectAck()
+	l.established()
+}
+
+// acceptPending replaces established session with pending one if connect request is sealed with it.
+func (l *UDPLink) acceptPending(payload []byte) bool {
+	p := l.pending
+	if len(payload) < udpNonceSize+p.aead.Overhead() {
+		return false
+	}
+	nonce, sealed := payload[:udpNonceSize], payload[udpNonceSize:]
+	if nonce[0] != udpNonceRoleInitiator {
+		return false
+	}
+	// open into another buffer, keeping payload intact for established session.
+	if _, err := p.aead.Open(nil, nonce, sealed, udpPacketTypes[udpPacketConnect:udpPacketConnect+1]); err != nil {
+		return false
+	}
+	l.backend.log.Infof("foreign peer at %v restarts session.", l.remote)
+	l.resetSession()
+	l.hello, l.key, l.aead, l.kxKey, l.initiator = p.hello, p.key, p.aead, p.kxKey, false
+	l.pskID = p.pskID
+	atomic.StoreUint64(&l.sendCounter, p.sendCounter)
+	l.state = udpLinkAccepting
+	return true
+}
+
+// exchangeKey derives forward-secret key for data packets.
+// Handshake packets are still sealed with PSK session key, so that they can be retransmitted.
+func (l *UDPLink) exchangeKey(private *ecdh.PrivateKey, peer, welcomeKey, connectKey []byte) error {
+	key, err := exchangeSessionKey(l.key, private, peer, welcomeKey, connectKey)
+	if err != nil {
+		return err
+	}
+	if l.data, err = newUDPSessionAEAD(key); err != nil {
+		return err
+	}
+	l.backend.log.Debug("forward-secret session key negotiated.")
+	return nil
+}
+
+func (l *UDPLink) onConnectAck(payload []byte) {
+	if l.state != udpLinkWelcomed {
+		return
+	}
+	if _, err := l.open(udpPacketConnectAck, payload); err != nil {
+		return
+	}
+	l.established()
+}
+
+func (l *UDPLink) established() {
+	now := time.Now().UnixNano()
+	l.lastRecv, l.lastSend = now, now
+	l.state = udpLinkEstablished
+	l.stats.connected()
+	l.backend.log.Infof("link to foreign peer \"%v\" established. [remote = %v]", l.publish, l.remote)
+	l.finish(nil)
+}
+
+func (l *UDPLink) connect(publish string) (err error) {
+	l.lock.Lock()
+	if l.state == udpLinkEstablished {
+		l.lock.Unlock()
+		return nil
+	}
+	if l.state == udpLinkIdle {
+		hello := &proto.Hello{}
+		hello.Refresh()
+		keys := l.backend.keys.load()
+		keys.sign(hello)
+		l.key = helloSessionKey(hello, keys.active)
+		if l.aead, err = newUDPSessionAEAD(l.key); err != nil {
+			l.lock.Unlock()
+			l.backend.log.Error("cipher initializion failure: ", err)
+			return err
+		}
+		l.resetNonce()
+		l.hello, l.initiator, l.publish = hello, true, publish
+		l.pskID = PSKID(keys.active)
+		l.state = udpLinkHelloSent
+		l.backend.log.Infof("connecting to %v(%v)", publish, l.remote.String())
+		l.sendHello()
+	}
+	ready := l.waitReady()
+	l.lock.Unlock()
+
+	timeout := time.After(l.backend.getConnectTimeout())
+	ticker := time.NewTicker(udpHandshakeRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ready.done:
+			return ready.err
+
+		case <-l.backend.Arbiter.Exit():
+			return ErrOperationCanceled
+
+		case <-timeout:
+			l.lock.Lock()
+			if l.waiting == ready {
+				l.resetSession()
+				l.finish(ErrUDPHandshakeTimeout)
+			}
+			l.lock.Unlock()
+			return ErrUDPHandshakeTimeout
+
+		case <-ticker.C:
+			// retransmit.
+			l.lock.Lock()
+			switch l.state {
+			case udpLinkHelloSent:
+				l.sendHello()
+			case udpLinkWelcomed:
+				l.sendConnect()
+			}
+			l.lock.Unlock()
+		}
+	}
+}
+
+func (s udpLinkState) String() string {
+	switch s {
+	case udpLinkIdle:
+		return "idle"
+	case udpLinkHelloSent:
+		return "hello_sent"
+	case udpLinkWelcomed:
+		return "welcomed"
+	case udpLinkAccepting:
+		return "accepting"
+	case udpLinkEstablished:
+		return "established"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(s))
+}
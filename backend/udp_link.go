@@ -0,0 +1,382 @@
+package backend
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crossmesh/fabric/mux"
+	"github.com/crossmesh/fabric/proto"
+)
+
+type udpLinkState uint8
+
+const (
+	udpLinkIdle = udpLinkState(iota)
+	udpLinkHelloSent
+	udpLinkWelcomed
+	udpLinkAccepting
+	udpLinkEstablished
+)
+
+const (
+	udpNonceSize = 12
+
+	// udpPacketOverhead is size of packet type, nonce and AEAD tag.
+	udpPacketOverhead = 1 + udpNonceSize + 16
+
+	// udpReplayWindowSize is number of recent nonce counters remembered to reject replayed packets.
+	udpReplayWindowSize = 1024
+)
+
+// udpPacketTypes maps packet type to itself, so that packet type can be
+// authenticated as additional data without allocation.
+var udpPacketTypes = func() (types [256]byte) {
+	for i := range types {
+		types[i] = byte(i)
+	}
+	return
+}()
+
+// udpReplayWindow tracks nonce counters received recently. Packets reordered
+// within the window are accepted, while duplicated or older ones are rejected.
+type udpReplayWindow struct {
+	lock   sync.Mutex
+	top    uint64 // highest counter accepted.
+	bitmap [udpReplayWindowSize / 64]uint64
+}
+
+func (w *udpReplayWindow) reset() {
+	w.lock.Lock()
+	w.top, w.bitmap = 0, [udpReplayWindowSize / 64]uint64{}
+	w.lock.Unlock()
+}
+
+// accept records counter and reports whether it is seen for the first time.
+func (w *udpReplayWindow) accept(counter uint64) bool {
+	if counter == 0 { // counter starts from 1.
+		return false
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if counter > w.top {
+		if counter-w.top >= udpReplayWindowSize {
+			w.bitmap = [udpReplayWindowSize / 64]uint64{}
+		} else {
+			for c := w.top + 1; c <= counter; c++ {
+				idx := c % udpReplayWindowSize
+				w.bitmap[idx/64] &^= 1 << (idx % 64)
+			}
+		}
+		w.top = counter
+	} else if w.top-counter >= udpReplayWindowSize { // too old.
+		return false
+	}
+	idx := counter % udpReplayWindowSize
+	word, bit := idx/64, uint64(1)<<(idx%64)
+	if w.bitmap[word]&bit != 0 {
+		return false
+	}
+	w.bitmap[word] |= bit
+	return true
+}
+
+type udpLinkReady struct {
+	done chan struct{}
+	err  error
+}
+
+// UDPLink maintains datagram session between two peer.
+type UDPLink struct {
+	lock    sync.RWMutex
+	remote  *net.UDPAddr
+	publish string
+
+	identity string // publish endpoint expected of punched peer. not checked if empty.
+
+	// session.
+	state     udpLinkState
+	initiator bool
+	hello     *proto.Hello
+	key       [32]byte    // PSK session key. base of key exchange.
+	aead      cipher.AEAD // seals handshake packets, and data packets if no key is exchanged.
+	data      cipher.AEAD // seals data packets with forward-secret key. nil if not exchanged.
+	kxKey     *ecdh.PrivateKey
+	version   uint8
+	pskID     string // fingerprint of pre-shared key authenticating session.
+	waiting   *udpLinkReady
+	pending   *udpPendingSession // handshake restarted by foreign peer while established.
+
+	sendCounter        uint64
+	replay             udpReplayWindow // counters received in session.
+	lastRecv, lastSend int64
+
+	stats *linkCounters
+
+	backend *UDP
+}
+
+// udpPendingSession is session accepted from hello while link is established.
+// It replaces established session only after foreign peer proves it with sealed connect request,
+// so that replayed hellos cannot tear down established link.
+type udpPendingSession struct {
+	hello       *proto.Hello
+	key         [32]byte
+	aead        cipher.AEAD
+	kxKey       *ecdh.PrivateKey
+	pskID       string
+	sendCounter uint64
+}
+
+func newUDPLink(backend *UDP, remote *net.UDPAddr) *UDPLink {
+	return &UDPLink{
+		backend: backend,
+		remote:  remote,
+		state:   udpLinkIdle,
+		stats:   newLinkCounters(),
+	}
+}
+
+// Active determines whether link is avaliable.
+func (l *UDPLink) Active() bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.state == udpLinkEstablished
+}
+
+// resetNonce restarts nonce counter of new session in both direction.
+func (l *UDPLink) resetNonce() {
+	atomic.StoreUint64(&l.sendCounter, 0)
+	l.replay.reset()
+}
+
+func (l *UDPLink) resetSession() {
+	l.state = udpLinkIdle
+	l.hello, l.aead, l.data, l.kxKey, l.pskID = nil, nil, nil, nil, ""
+	l.pending = nil
+	l.key = [32]byte{}
+	l.version = proto.ConnectNoCrypt
+	l.resetNonce()
+}
+
+func (l *UDPLink) waitReady() *udpLinkReady {
+	if l.waiting == nil {
+		l.waiting = &udpLinkReady{done: make(chan struct{})}
+	}
+	return l.waiting
+}
+
+func (l *UDPLink) finish(err error) {
+	if err != nil {
+		l.stats.handshakeFailure()
+	}
+	if ready := l.waiting; ready != nil {
+		ready.err = err
+		close(ready.done)
+		l.waiting = nil
+	}
+}
+
+const (
+	udpNonceRoleInitiator = byte(0)
+	udpNonceRoleAcceptor  = byte(1)
+)
+
+func (l *UDPLink) nonceRole() byte {
+	if l.initiator {
+		return udpNonceRoleInitiator
+	}
+	return udpNonceRoleAcceptor
+}
+
+func (l *UDPLink) encrypted() bool {
+	return l.state != udpLinkEstablished || l.version != proto.ConnectNoCrypt
+}
+
+// aeadOf returns AEAD sealing packets of given type.
+func (l *UDPLink) aeadOf(ty uint8) cipher.AEAD {
+	if l.data != nil && (ty == udpPacketData || ty == udpPacketKeepalive) {
+		return l.data
+	}
+	return l.aead
+}
+
+func (l *UDPLink) seal(buf []byte, ty uint8, plain []byte) []byte {
+	return sealUDPPacket(l.aeadOf(ty), l.nonceRole(), &l.sendCounter, buf, ty, plain)
+}
+
+func sealUDPPacket(aead cipher.AEAD, role byte, counter *uint64, buf []byte, ty uint8, plain []byte) []byte {
+	buf = append(buf, ty, role, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	nonce := buf[1 : 1+udpNonceSize]
+	binary.BigEndian.PutUint64(nonce[4:], atomic.AddUint64(counter, 1))
+	return aead.Seal(buf, nonce, plain, udpPacketTypes[ty:ty+1])
+}
+
+func (l *UDPLink) open(ty uint8, payload []byte) ([]byte, error) {
+	aead := l.aeadOf(ty)
+	if aead == nil {
+		return nil, ErrConnectionClosed
+	}
+	if len(payload) < udpNonceSize+aead.Overhead() {
+		return nil, ErrInvalidUDPPacket
+	}
+	nonce := payload[:udpNonceSize]
+	if nonce[0] == l.nonceRole() { // reflected packet.
+		return nil, ErrInvalidUDPPacket
+	}
+	sealed := payload[udpNonceSize:]
+	plain, err := aead.Open(sealed[:0], nonce, sealed, udpPacketTypes[ty:ty+1])
+	if err != nil {
+		return nil, err
+	}
+	if !l.replay.accept(binary.BigEndian.Uint64(nonce[4:])) {
+		return nil, ErrUDPReplayedPacket
+	}
+	return plain, nil
+}
+
+func (l *UDPLink) sendSealed(ty uint8, plain []byte) error {
+	return l.sendSealedWith(l.aeadOf(ty), l.nonceRole(), &l.sendCounter, ty, plain)
+}
+
+func (l *UDPLink) sendSealedWith(aead cipher.AEAD, role byte, counter *uint64, ty uint8, plain []byte) error {
+	if aead == nil {
+		return ErrOperationCanceled
+	}
+	fb := mux.NewFrameBuffer(1 + udpNonceSize + len(plain) + aead.Overhead())
+	defer fb.Release()
+	if err := l.backend.writeTo(sealUDPPacket(aead, role, counter, fb.Bytes()[:0], ty, plain), l.remote); err != nil {
+		l.backend.log.Errorf("failed to send packet to %v. (err = \"%v\")", l.remote, err)
+		return err
+	}
+	atomic.StoreInt64(&l.lastSend, time.Now().UnixNano())
+	return nil
+}
+
+func (l *UDPLink) sendPacket(ty uint8, frame []byte) (err error) {
+	if l.encrypted() {
+		return l.sendSealed(ty, frame)
+	}
+	fb := mux.NewFrameBuffer(1 + len(frame))
+	defer fb.Release()
+	buf := fb.Bytes()
+	buf[0] = ty
+	copy(buf[1:], frame)
+	if err = l.backend.writeTo(buf, l.remote); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&l.lastSend, time.Now().UnixNano())
+	return nil
+}
+
+func (l *UDPLink) openPacket(ty uint8, payload []byte) ([]byte, error) {
+	if l.encrypted() {
+		return l.open(ty, payload)
+	}
+	return payload, nil
+}
+
+// Send sends data frame.
+func (l *UDPLink) Send(frame []byte) (err error) {
+	if len(frame) > l.backend.MaxFrameSize() {
+		return ErrUDPFrameTooLarge
+	}
+	l.lock.RLock()
+	publish := l.publish
+	l.lock.RUnlock()
+	if !l.backend.getLimiter().wait(publish, frame, l.backend.getSendTimeout()) {
+		l.stats.sendTimeout()
+		return ErrOperationCanceled
+	}
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if l.state != udpLinkEstablished {
+		return ErrOperationCanceled
+	}
+	if err = l.sendPacket(udpPacketData, frame); err != nil {
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			l.stats.sendTimeout()
+			err = ErrOperationCanceled
+		}
+		return
+	}
+	l.stats.sent(len(frame))
+	return
+}
+
+func (l *UDPLink) onPacket(ty uint8, payload []byte) {
+	switch ty {
+	case udpPacketData, udpPacketKeepalive:
+		l.lock.RLock()
+		if l.state != udpLinkEstablished {
+			l.lock.RUnlock()
+			return
+		}
+		frame, err := l.openPacket(ty, payload)
+		publish := l.publish
+		l.lock.RUnlock()
+		if err != nil {
+			l.stats.demuxError()
+			return
+		}
+		atomic.StoreInt64(&l.lastRecv, time.Now().UnixNano())
+		if ty == udpPacketData {
+			l.stats.received(len(frame))
+			l.backend.deliver(frame, publish)
+		}
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	switch ty {
+	case udpPacketWelcome:
+		l.onWelcome(payload)
+	case udpPacketConnect:
+		l.onConnect(payload)
+	case udpPacketConnectAck:
+		l.onConnectAck(payload)
+	}
+}
+
+func (l *UDPLink) keepalive(now time.Time, period time.Duration) {
+	l.lock.RLock()
+	if l.state != udpLinkEstablished {
+		l.lock.RUnlock()
+		return
+	}
+	lastRecv, lastSend := atomic.LoadInt64(&l.lastRecv), atomic.LoadInt64(&l.lastSend)
+	if now.Sub(time.Unix(0, lastRecv)) <= 3*period {
+		if now.Sub(time.Unix(0, lastSend)) >= period {
+			l.sendPacket(udpPacketKeepalive, nil)
+		}
+		l.lock.RUnlock()
+		return
+	}
+	l.lock.RUnlock()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.state == udpLinkEstablished && atomic.LoadInt64(&l.lastRecv) == lastRecv {
+		l.backend.log.Infof("link to foreign peer \"%v\" expired. [remote = %v]", l.publish, l.remote)
+		l.resetSession()
+	}
+}
+
+// Close terminates link.
+func (l *UDPLink) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.resetSession()
+	l.finish(ErrConnectionClosed)
+	return nil
+}
@@ -0,0 +1,313 @@
+package backend
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/proto"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func newTestUDP(t *testing.T, arbiter *arbit.Arbiter, bind string, encrypt bool) *UDP {
+	raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+	cfg := &UDPBackendConfig{Bind: bind, raw: raw}
+	b, err := NewUDP(arbiter, nil, cfg, &raw.PSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestUDPBackend(t *testing.T) {
+	for _, encrypt := range []bool{true, false} {
+		arbiter := arbit.New()
+
+		a := newTestUDP(t, arbiter, "127.0.0.1:39880", encrypt)
+		b := newTestUDP(t, arbiter, "127.0.0.1:39881", encrypt)
+
+		received := make(chan []byte, 1)
+		b.Watch(func(_ Backend, frame []byte, src string) {
+			assert.Equal(t, a.Publish(), src)
+			received <- append([]byte(nil), frame...)
+		})
+
+		link, err := a.Connect(b.Publish())
+		if !assert.NoError(t, err) {
+			arbiter.Shutdown()
+			arbiter.Join()
+			continue
+		}
+		assert.True(t, link.(*UDPLink).Active())
+		assert.Equal(t, uint16(39880), a.Port())
+		assert.True(t, net.IPv4(127, 0, 0, 1).Equal(a.IP()))
+		// data packets are sealed with exchanged key. delivery proves both sides agree on it.
+		link.(*UDPLink).lock.RLock()
+		assert.Equal(t, encrypt, link.(*UDPLink).data != nil)
+		link.(*UDPLink).lock.RUnlock()
+
+		payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatal("frame not delivered.")
+		}
+
+		assert.Equal(t, ErrUDPFrameTooLarge, link.Send(make([]byte, maxUDPDatagramSize)))
+
+		unbound := &UDP{}
+		assert.Zero(t, unbound.Port())
+		assert.Nil(t, unbound.IP())
+
+		arbiter.Shutdown()
+		arbiter.Join()
+	}
+}
+
+func TestUDPBackendIPv6(t *testing.T) {
+	if l, err := net.ListenPacket("udp", "[::1]:0"); err != nil {
+		t.Skip("IPv6 loopback unavailable.")
+	} else {
+		l.Close()
+	}
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	a := newTestUDP(t, arbiter, "[::1]:39992", true)
+	b := newTestUDP(t, arbiter, "[::1]:39993", true)
+
+	received := make(chan []byte, 1)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		assert.Equal(t, "[::1]:39992", src)
+		received <- append([]byte(nil), frame...)
+	})
+
+	var link Link
+	var err error
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect("[::1]:39993")
+		return err == nil
+	}))
+	if link == nil {
+		return
+	}
+	payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+	assert.NoError(t, link.Send(payload))
+	select {
+	case frame := <-received:
+		assert.True(t, bytes.Equal(payload, frame))
+	case <-time.After(time.Second * 5):
+		t.Fatal("frame not delivered.")
+	}
+}
+
+func TestUDPReload(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	a := newTestUDP(t, arbiter, "127.0.0.1:39997", true)
+	b := newTestUDP(t, arbiter, "127.0.0.1:39998", true)
+
+	var err error
+	assert.True(t, waitForBackend(func() bool {
+		_, err = a.Connect(b.Publish())
+		return err == nil
+	}))
+
+	encrypt := true
+	raw := &config.Backend{
+		PSK: "12345", Encrypt: &encrypt, Type: "udp",
+		Parameters: map[string]interface{}{"bind": "127.0.0.1:39997", "sendBuffer": 65536, "keepalivePeriod": 5, "sendTimeout": 20},
+	}
+	reload := func() ([]string, bool) {
+		cfg := *raw // configurations are never modified once loaded.
+		creator, err := GetCreator("udp", &cfg)
+		if !assert.NoError(t, err) {
+			return nil, false
+		}
+		return a.Reload(creator)
+	}
+	restarted, reloaded := reload()
+	assert.True(t, reloaded)
+	assert.Empty(t, restarted)
+	assert.Equal(t, time.Second*5, a.getKeepalivePeriod())
+	assert.Equal(t, time.Millisecond*20, a.getSendTimeout())
+	assert.Equal(t, 1, len(a.Links()))
+
+	raw.Encrypt = new(bool) // disabled.
+	restarted, reloaded = reload()
+	assert.True(t, reloaded)
+	assert.Equal(t, []string{b.Publish()}, restarted)
+
+	raw.Parameters["bind"] = "127.0.0.1:39999"
+	_, reloaded = reload()
+	assert.False(t, reloaded)
+}
+
+func TestUDPPunch(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newUDP := func(bind, publish string) *UDP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &UDPBackendConfig{Bind: bind, Publish: publish, raw: raw}
+		b, err := NewUDP(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// publish addresses nobody listens at, as if peers were behind NAT.
+	a, b := newUDP("127.0.0.1:39866", "127.0.0.2:39866"), newUDP("127.0.0.1:39867", "127.0.0.2:39867")
+	var _ Puncher = a
+
+	received := make(chan string, 1)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- src
+	})
+
+	punched := make(chan error, 1)
+	go func() {
+		_, err := b.Punch("127.0.0.1:39866", a.Publish())
+		punched <- err
+	}()
+	link, err := a.Punch("127.0.0.1:39867", b.Publish())
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, <-punched) {
+		return
+	}
+
+	assert.NoError(t, link.Send([]byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}))
+	select {
+	case src := <-received:
+		// punched link is bound to the endpoint.
+		assert.Equal(t, "127.0.0.1:39866", src)
+	case <-time.After(time.Second * 5):
+		t.Fatal("frame not delivered.")
+	}
+	assert.Equal(t, "127.0.0.1:39866", b.ObservedAddress("127.0.0.1:39866"))
+
+	// punched peer should have expected identity.
+	newUDP("127.0.0.1:39868", "127.0.0.2:39868")
+	_, err = a.Punch("127.0.0.1:39868", "wrong")
+	assert.Equal(t, ErrPunchIdentityMismatch, err)
+}
+
+func TestUDPHelloReplay(t *testing.T) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	a := newTestUDP(t, arbiter, "127.0.0.1:39882", true)
+	b := newTestUDP(t, arbiter, "127.0.0.1:39883", true)
+
+	received := make(chan []byte, 1)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		received <- append([]byte(nil), frame...)
+	})
+	expectDelivered := func(link Link) {
+		payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatal("frame not delivered.")
+		}
+	}
+
+	link, err := a.Connect(b.Publish())
+	if !assert.NoError(t, err) {
+		return
+	}
+	initiator := link.(*UDPLink)
+	accepted := b.getLink(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 39882})
+
+	// hello captured from former session, which is no longer remembered.
+	hello := &proto.Hello{}
+	hello.Refresh()
+	a.keys.load().sign(hello)
+	packet := append([]byte{udpPacketHello}, hello.Encode(nil)...)
+	assert.NoError(t, a.writeTo(packet, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 39883}))
+	time.Sleep(time.Millisecond * 200)
+
+	accepted.lock.RLock()
+	assert.Equal(t, udpLinkEstablished, accepted.state)
+	assert.NotNil(t, accepted.pending)
+	accepted.lock.RUnlock()
+	expectDelivered(link)
+
+	// foreign peer really restarts.
+	initiator.lock.Lock()
+	initiator.resetSession()
+	initiator.lock.Unlock()
+	link, err = a.Connect(b.Publish())
+	if !assert.NoError(t, err) {
+		return
+	}
+	accepted.lock.RLock()
+	assert.Equal(t, udpLinkEstablished, accepted.state)
+	assert.Nil(t, accepted.pending)
+	accepted.lock.RUnlock()
+	expectDelivered(link)
+}
+
+func TestUDPReplayWindow(t *testing.T) {
+	w := &udpReplayWindow{}
+	assert.False(t, w.accept(0))
+	assert.True(t, w.accept(1))
+	assert.False(t, w.accept(1))
+	assert.True(t, w.accept(3))
+	assert.True(t, w.accept(2)) // reordered.
+	assert.False(t, w.accept(2))
+
+	assert.True(t, w.accept(2+udpReplayWindowSize))
+	assert.False(t, w.accept(2)) // out of window.
+	assert.False(t, w.accept(3))
+	assert.True(t, w.accept(1+udpReplayWindowSize))
+	assert.True(t, w.accept(4))
+
+	w.reset()
+	assert.True(t, w.accept(1))
+
+	t.Run("link", func(t *testing.T) {
+		aead, err := newUDPSessionAEAD([32]byte{1})
+		if !assert.NoError(t, err) {
+			return
+		}
+		a, b := newUDPLink(nil, nil), newUDPLink(nil, nil)
+		a.aead, a.initiator, b.aead = aead, true, aead
+
+		packet := a.seal(nil, udpPacketData, []byte("frame"))
+		replay := func() []byte { return append([]byte(nil), packet[1:]...) }
+		plain, err := b.open(udpPacketData, replay())
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("frame"), plain)
+		_, err = b.open(udpPacketData, replay())
+		assert.Equal(t, ErrUDPReplayedPacket, err)
+
+		b.resetNonce()
+		_, err = b.open(udpPacketData, replay())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	logging "github.com/sirupsen/logrus"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+// Unix domain socket backend shares the same link implementation with TCP backend.
+// Endpoints are socket paths. A path beginning with '@' refers to a socket in
+// abstract namespace (Linux only).
+
+func newUnixCreator(cfg *config.Backend) (BackendCreator, error) {
+	return newStreamCreator("unix", cfg)
+}
+
+// NewUnix creates Unix domain socket backend.
+func NewUnix(arbiter *arbit.Arbiter, log *logging.Entry, cfg *TCPBackendConfig, psk *string) (t *TCP, err error) {
+	if log == nil {
+		log = logging.WithField("module", "backend_unix")
+	}
+	return newStreamBackend(arbiter, log, "unix", cfg, psk)
+}
+
+func isAbstractUnixAddr(addr *net.UnixAddr) bool {
+	return strings.HasPrefix(addr.Name, "@")
+}
+
+// removeStaleUnixSocket removes socket file left by a dead process.
+func removeStaleUnixSocket(addr *net.UnixAddr) error {
+	if isAbstractUnixAddr(addr) {
+		return nil
+	}
+	info, err := os.Lstat(addr.Name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return nil // not a socket. let listen fail.
+	}
+	conn, err := net.DialTimeout("unix", addr.Name, time.Second)
+	if err == nil {
+		// someone is listening.
+		conn.Close()
+		return nil
+	}
+	return os.Remove(addr.Name)
+}
+
+func listenUnix(addr *net.UnixAddr) (*net.UnixListener, error) {
+	if err := removeStaleUnixSocket(addr); err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(true)
+	return listener, nil
+}
@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func newTestUnix(t *testing.T, arbiter *arbit.Arbiter, bind string, encrypt bool) *TCP {
+	raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+	cfg := &TCPBackendConfig{Bind: bind, raw: raw}
+	b, err := NewUnix(arbiter, nil, cfg, &raw.PSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestUnixBackend(t *testing.T) {
+	dir, err := os.MkdirTemp("", "utt-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// leave a stale socket file.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(dir, "a.sock"), Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	for _, c := range []struct {
+		a, b    string
+		encrypt bool
+	}{
+		{a: filepath.Join(dir, "a.sock"), b: filepath.Join(dir, "b.sock"), encrypt: true},
+		{a: filepath.Join(dir, "a.sock"), b: filepath.Join(dir, "b.sock"), encrypt: false},
+		{a: "@utt-unix-test-a", b: "@utt-unix-test-b", encrypt: true},
+	} {
+		arbiter := arbit.New()
+
+		a := newTestUnix(t, arbiter, c.a, c.encrypt)
+		b := newTestUnix(t, arbiter, c.b, c.encrypt)
+		assert.Equal(t, UnixBackend, a.Type())
+
+		received := make(chan []byte, 1)
+		b.Watch(func(_ Backend, frame []byte, src string) {
+			assert.Equal(t, a.Publish(), src)
+			received <- append([]byte(nil), frame...)
+		})
+
+		var link Link
+		assert.True(t, waitForBackend(func() bool {
+			link, err = a.Connect(b.Publish())
+			return err == nil
+		}), "cannot connect to %v", b.Publish())
+		if link != nil {
+			payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+			assert.NoError(t, link.Send(payload))
+			select {
+			case frame := <-received:
+				assert.True(t, bytes.Equal(payload, frame))
+			case <-time.After(time.Second * 5):
+				t.Fatal("frame not delivered.")
+			}
+		}
+
+		arbiter.Shutdown()
+		arbiter.Join()
+	}
+}
+
+func waitForBackend(cond func() bool) bool {
+	for i := 0; i < 30; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return false
+}
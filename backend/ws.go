@@ -0,0 +1,345 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	logging "github.com/sirupsen/logrus"
+	arbit "github.com/sunmxt/arbiter"
+	"golang.org/x/net/proxy"
+	"golang.org/x/net/websocket"
+)
+
+// WebSocket backend shares the same link implementation with TCP backend.
+// Muxed stream is carried by binary messages. Endpoints are in form of
+// "host:port/path", for example "ws://example.com:80/utt".
+
+var (
+	ErrInvalidWSEndpoint = errors.New("invalid websocket endpoint")
+	ErrWSProxyRefused    = ErrProxyRefused
+)
+
+// wsReadHeaderTimeout limits time to read request header, so that idle clients don't hold connections.
+const wsReadHeaderTimeout = 10 * time.Second
+
+// WSSConfig describes websocket over TLS (wss).
+type WSSConfig struct {
+	// PEM files containing certificate chain and private key served by listener.
+	// Listener serves plain HTTP if absent, e.g. behind reverse proxy terminating TLS.
+	Cert string `json:"cert" yaml:"cert"`
+	Key  string `json:"key" yaml:"key"`
+	// PEM file containing CA certificates trusted to verify peers dialed. System roots are used if absent.
+	CA string `json:"ca" yaml:"ca"`
+}
+
+// serverTLSConfig returns TLS configuration of listener, or nil if plain HTTP is served.
+func (c *WSSConfig) serverTLSConfig() (*tls.Config, error) {
+	if c == nil || (c.Cert == "" && c.Key == "") {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("load wss key pair failure (%v)", err)
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+}
+
+// clientTLSConfig returns TLS configuration of dialing peers, or nil if peers are dialed with plain HTTP.
+func (c *WSSConfig) clientTLSConfig() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CA != "" {
+		pem, err := ioutil.ReadFile(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("load wss CA failure (%v)", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, ErrTLSInvalidCA
+		}
+	}
+	return cfg, nil
+}
+
+func newWSCreator(cfg *config.Backend) (BackendCreator, error) {
+	return newStreamCreator("ws", cfg)
+}
+
+// NewWS creates WebSocket backend.
+func NewWS(arbiter *arbit.Arbiter, log *logging.Entry, cfg *TCPBackendConfig, psk *string) (t *TCP, err error) {
+	if log == nil {
+		log = logging.WithField("module", "backend_ws")
+	}
+	if cfg.Publish == "" {
+		cfg.Publish = cfg.Bind + cfg.getWSPath()
+	}
+	return newStreamBackend(arbiter, log, "ws", cfg, psk)
+}
+
+func (c *TCPBackendConfig) getWSPath() string {
+	if c.Path == "" {
+		return "/"
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return "/" + c.Path
+	}
+	return c.Path
+}
+
+// trustedProxies parses trusted reverse proxies.
+func (c *TCPBackendConfig) trustedProxies() (nets []*net.IPNet, err error) {
+	for _, entry := range c.TrustedProxies {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy \"%v\"", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, subnet, perr := net.ParseCIDR(entry)
+		if perr != nil {
+			return nil, fmt.Errorf("invalid trusted proxy \"%v\"", entry)
+		}
+		nets = append(nets, subnet)
+	}
+	return
+}
+
+func ipTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, subnet := range trusted {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// wsClientAddr returns address of client sending request.
+// Behind trusted proxies, the last untrusted hop in X-Forwarded-For is the client.
+func wsClientAddr(req *http.Request, trusted []*net.IPNet) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", req.RemoteAddr)
+	if err != nil {
+		return &wsAddr{host: req.RemoteAddr}
+	}
+	if !ipTrusted(addr.IP, trusted) {
+		return addr
+	}
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if !ipTrusted(ip, trusted) {
+			return &net.TCPAddr{IP: ip}
+		}
+	}
+	return addr
+}
+
+type wsAddr struct {
+	host, path string
+}
+
+func (a *wsAddr) Network() string { return "ws" }
+func (a *wsAddr) String() string  { return a.host + a.path }
+
+func parseWSEndpoint(endpoint string) (*wsAddr, error) {
+	addr := &wsAddr{host: endpoint, path: "/"}
+	if idx := strings.Index(endpoint, "/"); idx >= 0 {
+		addr.host, addr.path = endpoint[:idx], endpoint[idx:]
+	}
+	if _, _, err := net.SplitHostPort(addr.host); err != nil {
+		return nil, ErrInvalidWSEndpoint
+	}
+	return addr, nil
+}
+
+// wsConn adapts websocket connection to stream connection.
+type wsConn struct {
+	*websocket.Conn
+
+	raw    net.Conn
+	remote net.Addr
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newWSConn(conn *websocket.Conn, raw net.Conn) *wsConn {
+	conn.PayloadType = websocket.BinaryFrame
+	c := &wsConn{
+		Conn: conn,
+		raw:  raw,
+		done: make(chan struct{}),
+	}
+	if raw != nil {
+		c.remote = raw.RemoteAddr()
+	}
+	return c
+}
+
+func (c *wsConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *wsConn) SetWriteBuffer(bytes int) error {
+	if tcpConn, isTCP := c.raw.(*net.TCPConn); isTCP {
+		return tcpConn.SetWriteBuffer(bytes)
+	}
+	return nil
+}
+
+func (c *wsConn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		err = c.Conn.Close()
+		close(c.done)
+	})
+	return
+}
+
+type wsTimeoutError struct{}
+
+func (e wsTimeoutError) Error() string   { return "accept timeout" }
+func (e wsTimeoutError) Timeout() bool   { return true }
+func (e wsTimeoutError) Temporary() bool { return true }
+
+// wsListener accepts websocket connections from HTTP server.
+type wsListener struct {
+	net.Listener
+
+	server  *http.Server
+	trusted []*net.IPNet // trusted reverse proxies.
+	conns   chan *wsConn
+	closed  chan struct{}
+
+	lock     sync.Mutex
+	deadline time.Time
+}
+
+// listenWS serves websocket connections. Connections are served over TLS if tlsCfg is not nil.
+func listenWS(addr *wsAddr, lc *net.ListenConfig, trusted []*net.IPNet, tlsCfg *tls.Config) (*wsListener, error) {
+	listener, err := lc.Listen(context.Background(), "tcp", addr.host)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		listener = tls.NewListener(listener, tlsCfg)
+	}
+	l := &wsListener{
+		Listener: listener,
+		trusted:  trusted,
+		conns:    make(chan *wsConn),
+		closed:   make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(addr.path, websocket.Server{
+		// non-browser peers do not send origin.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   l.serveConn,
+	})
+	l.server = &http.Server{Handler: mux, ReadHeaderTimeout: wsReadHeaderTimeout}
+	go l.server.Serve(listener)
+	return l, nil
+}
+
+func (l *wsListener) serveConn(conn *websocket.Conn) {
+	wc := newWSConn(conn, nil)
+	wc.remote = wsClientAddr(conn.Request(), l.trusted)
+	select {
+	case l.conns <- wc:
+	case <-l.closed:
+		return
+	}
+	// hijacked connection will be closed once handler returns.
+	<-wc.done
+}
+
+func (l *wsListener) SetDeadline(t time.Time) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.deadline = t
+	return nil
+}
+
+func (l *wsListener) Accept() (net.Conn, error) {
+	l.lock.Lock()
+	deadline := l.deadline
+	l.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, ErrConnectionClosed
+	case <-timeout:
+		return nil, wsTimeoutError{}
+	}
+}
+
+func (l *wsListener) Close() error {
+	select {
+	case <-l.closed:
+		return nil
+	default:
+	}
+	close(l.closed)
+	return l.server.Close()
+}
+
+// dialWS establishes websocket connection to peer. Connection is established over TLS (wss)
+// if tlsCfg is not nil.
+func dialWS(ctx context.Context, addr *wsAddr, dialer proxy.ContextDialer, tlsCfg *tls.Config) (conn *wsConn, err error) {
+	raw, err := dialer.DialContext(ctx, "tcp", addr.host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+		raw.SetDeadline(deadline)
+	}
+	var rw io.ReadWriteCloser = raw
+	scheme, origin := "ws://", "http://"
+	if tlsCfg != nil {
+		tlsCfg = tlsCfg.Clone()
+		tlsCfg.ServerName, _, _ = net.SplitHostPort(addr.host)
+		tlsConn := tls.Client(raw, tlsCfg)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			raw.Close()
+			return nil, err
+		}
+		rw, scheme, origin = tlsConn, "wss://", "https://"
+	}
+	cfg, err := websocket.NewConfig(scheme+addr.host+addr.path, origin+addr.host)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	ws, err := websocket.NewClient(cfg, rw)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return newWSConn(ws, raw), nil
+}
@@ -0,0 +1,217 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/config"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func newTestWS(t *testing.T, arbiter *arbit.Arbiter, bind, path, proxy string) *TCP {
+	encrypt := true
+	raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+	cfg := &TCPBackendConfig{Bind: bind, Path: path, Proxy: proxy, raw: raw}
+	b, err := NewWS(arbiter, nil, cfg, &raw.PSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// serveTestConnectProxy serves minimal HTTP CONNECT proxy.
+func serveTestConnectProxy(t *testing.T, listener net.Listener, connected chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err != nil || req.Method != http.MethodConnect {
+				return
+			}
+			if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" { // user:pass
+				io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+				return
+			}
+			upstream, err := net.Dial("tcp", req.Host)
+			if err != nil {
+				io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+				return
+			}
+			defer upstream.Close()
+			io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			connected <- req.Host
+			go io.Copy(upstream, conn)
+			io.Copy(conn, upstream)
+		}()
+	}
+}
+
+func TestWSEndpoint(t *testing.T) {
+	addr, err := parseWSEndpoint("127.0.0.1:80/utt/v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:80", addr.host)
+	assert.Equal(t, "/utt/v1", addr.path)
+
+	addr, err = parseWSEndpoint("example.com:80")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", addr.path)
+
+	_, err = parseWSEndpoint("example.com/utt")
+	assert.Equal(t, ErrInvalidWSEndpoint, err)
+}
+
+func TestWSClientAddr(t *testing.T) {
+	cfg := &TCPBackendConfig{TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8"}}
+	trusted, err := cfg.trustedProxies()
+	if !assert.NoError(t, err) {
+		return
+	}
+	req := &http.Request{RemoteAddr: "127.0.0.1:40000", Header: http.Header{}}
+	req.Header.Add("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	req.Header.Add("X-Forwarded-For", "10.1.2.3")
+	// the last untrusted hop is client. hops before it may be forged.
+	assert.Equal(t, "198.51.100.7:0", wsClientAddr(req, trusted).String())
+
+	// untrusted source is client itself.
+	req.RemoteAddr = "192.0.2.1:40000"
+	assert.Equal(t, "192.0.2.1:40000", wsClientAddr(req, trusted).String())
+	assert.Equal(t, "127.0.0.1:40000", wsClientAddr(&http.Request{RemoteAddr: "127.0.0.1:40000"}, nil).String())
+
+	_, err = (&TCPBackendConfig{TrustedProxies: []string{"proxy.local"}}).trustedProxies()
+	assert.Error(t, err)
+}
+
+func TestWSBackend(t *testing.T) {
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxyListener.Close()
+	connected := make(chan string, 4)
+	go serveTestConnectProxy(t, proxyListener, connected)
+
+	for _, proxy := range []string{"", "http://user:pass@" + proxyListener.Addr().String()} {
+		arbiter := arbit.New()
+
+		a := newTestWS(t, arbiter, "127.0.0.1:39890", "/utt", proxy)
+		b := newTestWS(t, arbiter, "127.0.0.1:39891", "/utt", proxy)
+		assert.Equal(t, WSBackend, a.Type())
+		assert.Equal(t, "127.0.0.1:39891/utt", b.Publish())
+
+		received := make(chan []byte, 1)
+		b.Watch(func(_ Backend, frame []byte, src string) {
+			assert.Equal(t, a.Publish(), src)
+			received <- append([]byte(nil), frame...)
+		})
+
+		var link Link
+		assert.True(t, waitForBackend(func() bool {
+			link, err = a.Connect(b.Publish())
+			return err == nil
+		}), "cannot connect to %v", b.Publish())
+		if link != nil {
+			payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+			assert.NoError(t, link.Send(payload))
+			select {
+			case frame := <-received:
+				assert.True(t, bytes.Equal(payload, frame))
+			case <-time.After(time.Second * 5):
+				t.Fatal("frame not delivered.")
+			}
+		}
+		if proxy != "" {
+			select {
+			case host := <-connected:
+				assert.Equal(t, "127.0.0.1:39891", host)
+			default:
+				t.Fatal("connection not via proxy.")
+			}
+		}
+
+		arbiter.Shutdown()
+		arbiter.Join()
+	}
+}
+
+func TestWSSBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "utt-wss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir, "ca")
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newWSS := func(bind string, wss *WSSConfig) *TCP {
+		encrypt := true
+		raw := &config.Backend{PSK: "12345", Encrypt: &encrypt}
+		cfg := &TCPBackendConfig{Bind: bind, Path: "/utt", WSS: wss, raw: raw}
+		b, err := NewWS(arbiter, nil, cfg, &raw.PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	issued := ca.issue(t, "server", "server", net.ParseIP("127.0.0.1"))
+	a := newWSS("127.0.0.1:39892", &WSSConfig{CA: issued.CA})
+	b := newWSS("127.0.0.1:39893", &WSSConfig{Cert: issued.Cert, Key: issued.Key, CA: issued.CA})
+	plain := newTestWS(t, arbiter, "127.0.0.1:39894", "/utt", "")
+	untrusted := newWSS("127.0.0.1:39895", &WSSConfig{}) // verified by system roots.
+
+	received := make(chan []byte, 1)
+	b.Watch(func(_ Backend, frame []byte, src string) {
+		assert.Equal(t, a.Publish(), src)
+		received <- append([]byte(nil), frame...)
+	})
+
+	var link Link
+	assert.True(t, waitForBackend(func() bool {
+		link, err = a.Connect(b.Publish())
+		return err == nil
+	}), "cannot connect to %v", b.Publish())
+	if link != nil {
+		payload := []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+		assert.NoError(t, link.Send(payload))
+		select {
+		case frame := <-received:
+			assert.True(t, bytes.Equal(payload, frame))
+		case <-time.After(time.Second * 5):
+			t.Fatal("frame not delivered.")
+		}
+	}
+
+	_, err = plain.Connect(b.Publish())
+	assert.Error(t, err)
+	_, err = untrusted.Connect(b.Publish())
+	assert.Error(t, err)
+
+	// idle client not sending request header is disconnected.
+	if testing.Short() {
+		return
+	}
+	conn, err := net.Dial("tcp", "127.0.0.1:39894")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(wsReadHeaderTimeout + time.Second*5))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/crossmesh/fabric/cmd/pb"
+	"github.com/crossmesh/fabric/edgerouter"
 	log "github.com/sirupsen/logrus"
 	arbit "github.com/sunmxt/arbiter"
 	"github.com/urfave/cli/v2"
@@ -58,6 +59,10 @@ type staticConfigurationAccpeter interface {
 	ReloadStaticConfig(path string)
 }
 
+type networkRouterProvider interface {
+	NetworkRouter(name string) (*edgerouter.EdgeRouter, error)
+}
+
 // ControlRPC contains configuration of control port.
 type controlRPCConfig struct {
 	Type     string `json:"type" yaml:"type" default:"unix"`
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/crossmesh/fabric/backend"
 	"github.com/crossmesh/fabric/control"
+	"github.com/crossmesh/fabric/edgerouter"
 	logging "github.com/sirupsen/logrus"
 	arbit "github.com/sunmxt/arbiter"
 	"github.com/urfave/cli/v2"
@@ -67,6 +70,21 @@ func (a *coreDaemonApplication) newCliApp(out, err io.Writer) (*cli.App, *coreDa
 						Usage:  "seed gossip peer.",
 						Action: a.cliRunSeedAction,
 					},
+					{
+						Name:   "links",
+						Usage:  "list live links with traffic and error statistics.",
+						Action: a.cliRunLinksAction,
+					},
+					{
+						Name:   "paths",
+						Usage:  "list paths to peers.",
+						Action: a.cliRunPathsAction,
+					},
+					{
+						Name:   "bans",
+						Usage:  "list sources banned for authentication failures.",
+						Action: a.cliRunBansAction,
+					},
 				},
 			},
 		},
@@ -193,25 +211,12 @@ func (a *coreDaemonApplication) cliRunSeedAction(ctx *cli.Context) error {
 	var endpoints []backend.Endpoint
 
 	for _, ep := range ctx.Args().Slice()[1:] {
-		parts := strings.SplitN(ep, ":", 2)
-		if len(parts) < 2 {
-			fmt.Fprintln(cmdCtx.err, "endpoint with invalid format: \""+ep+"\"")
+		endpoint, err := backend.ParseEndpoint(ep)
+		if err != nil {
+			fmt.Fprintln(cmdCtx.err, err)
 			return invalidParamsError
 		}
-		if len(parts[1]) < 1 {
-			fmt.Fprintln(cmdCtx.err, "got empty endpoint from \""+ep+"\"")
-			return invalidParamsError
-		}
-
-		ty, hasType := backend.TypeByName[parts[0]]
-		if !hasType || ty == backend.UnknownBackend {
-			fmt.Fprintln(cmdCtx.err, "unsupported endpoint type \""+parts[0]+"\"")
-			return invalidParamsError
-		}
-		endpoints = append(endpoints, backend.Endpoint{
-			Type:     ty,
-			Endpoint: parts[1],
-		})
+		endpoints = append(endpoints, endpoint)
 	}
 
 	net := a.mgr.GetNetwork(netName)
@@ -235,6 +240,170 @@ func (a *coreDaemonApplication) cliRunSeedAction(ctx *cli.Context) error {
 	return nil
 }
 
+func (a *coreDaemonApplication) cliRunLinksAction(ctx *cli.Context) error {
+	cmdCtx := ctx.Context.Value(coreDaemonRunContextRawArgsKey).(*coreDaemonApplicationCommandContext)
+	if cmdCtx == nil {
+		return errors.New("nil command context")
+	}
+
+	invalidParamsError := cmdError("invalid parameters")
+
+	if ctx.Args().Len() < 1 {
+		fmt.Fprintln(cmdCtx.err, "network missing.")
+		return invalidParamsError
+	}
+
+	router, err := a.NetworkRouter(ctx.Args().Get(0))
+	if err != nil {
+		fmt.Fprintln(cmdCtx.err, err)
+		return invalidParamsError
+	}
+
+	links := router.Links()
+	endpoints := make([]backend.Endpoint, 0, len(links))
+	for endpoint := range links {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].String() < endpoints[j].String() })
+
+	w := tabwriter.NewWriter(cmdCtx.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tPEER\tREMOTE\tKEY\tCOMPRESS\tTX (FRAMES/BYTES)\tRX (FRAMES/BYTES)\tTIMEOUTS\tDEMUX ERRORS\tHANDSHAKE FAILURES\tRECONNECTS")
+	for _, endpoint := range endpoints {
+		infos := links[endpoint]
+		sort.Slice(infos, func(i, j int) bool {
+			if infos[i].Publish != infos[j].Publish {
+				return infos[i].Publish < infos[j].Publish
+			}
+			return infos[i].Stripe < infos[j].Stripe
+		})
+		for _, info := range infos {
+			peer := info.Publish
+			if info.Stripe > 0 {
+				peer += fmt.Sprintf("#%v", info.Stripe)
+			}
+			compress := "-"
+			if info.Compression != "" {
+				compress = fmt.Sprintf("%v (%.2f)", info.Compression, info.CompressStats.Ratio())
+			}
+			stats := &info.Stats
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v/%v\t%v/%v\t%v\t%v\t%v\t%v\n", endpoint, peer, info.Remote, info.PSKID, compress,
+				stats.FramesSent, stats.BytesSent, stats.FramesReceived, stats.BytesReceived,
+				stats.SendTimeouts, stats.DemuxErrors, stats.HandshakeFailures, stats.Reconnects)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmdCtx.out)
+	w = tabwriter.NewWriter(cmdCtx.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PEER\tLINKS\tTX (FRAMES/BYTES)\tRX (FRAMES/BYTES)\tFORWARDED\tDELIVERED\tTIMEOUTS\tDEMUX ERRORS\tHANDSHAKE FAILURES\tRECONNECTS")
+	for _, stat := range router.PeerStatistics() {
+		fmt.Fprintf(w, "%v\t%v\t%v/%v\t%v/%v\t%v\t%v\t%v\t%v\t%v\t%v\n", stat.Name, stat.Links,
+			stat.FramesSent, stat.BytesSent, stat.FramesReceived, stat.BytesReceived, stat.Forwarded, stat.Delivered,
+			stat.SendTimeouts, stat.DemuxErrors, stat.HandshakeFailures, stat.Reconnects)
+	}
+	return w.Flush()
+}
+
+func (a *coreDaemonApplication) cliRunPathsAction(ctx *cli.Context) error {
+	cmdCtx := ctx.Context.Value(coreDaemonRunContextRawArgsKey).(*coreDaemonApplicationCommandContext)
+	if cmdCtx == nil {
+		return errors.New("nil command context")
+	}
+
+	invalidParamsError := cmdError("invalid parameters")
+
+	if ctx.Args().Len() < 1 {
+		fmt.Fprintln(cmdCtx.err, "network missing.")
+		return invalidParamsError
+	}
+
+	router, err := a.NetworkRouter(ctx.Args().Get(0))
+	if err != nil {
+		fmt.Fprintln(cmdCtx.err, err)
+		return invalidParamsError
+	}
+
+	w := tabwriter.NewWriter(cmdCtx.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PEER\tLOCAL\tREMOTE\tCOST\tSTATE")
+	for _, info := range router.Paths() {
+		active := false
+		for _, path := range info.Paths {
+			state := "standby"
+			if path.Disabled {
+				state = "disabled"
+			} else if !active {
+				state, active = "active", true
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", info.Name, path.Local, path.Remote, path.Cost, state)
+		}
+		if !active {
+			state := "unreachable"
+			if info.Relay != "" {
+				state = fmt.Sprintf("relay via %v (rtt %v)", info.Relay, info.RelayRTT.Round(time.Millisecond))
+			}
+			fmt.Fprintf(w, "%v\t-\t-\t-\t%v\n", info.Name, state)
+		}
+	}
+	return w.Flush()
+}
+
+func (a *coreDaemonApplication) cliRunBansAction(ctx *cli.Context) error {
+	cmdCtx := ctx.Context.Value(coreDaemonRunContextRawArgsKey).(*coreDaemonApplicationCommandContext)
+	if cmdCtx == nil {
+		return errors.New("nil command context")
+	}
+
+	invalidParamsError := cmdError("invalid parameters")
+
+	if ctx.Args().Len() < 1 {
+		fmt.Fprintln(cmdCtx.err, "network missing.")
+		return invalidParamsError
+	}
+
+	router, err := a.NetworkRouter(ctx.Args().Get(0))
+	if err != nil {
+		fmt.Fprintln(cmdCtx.err, err)
+		return invalidParamsError
+	}
+
+	bans := router.Bans()
+	endpoints := make([]backend.Endpoint, 0, len(bans))
+	for endpoint := range bans {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].String() < endpoints[j].String() })
+
+	w := tabwriter.NewWriter(cmdCtx.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tSOURCE\tFAILURES\tUNTIL")
+	for _, endpoint := range endpoints {
+		for _, info := range bans[endpoint] {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", endpoint, info.IP, info.Failures, info.Until.Format(time.RFC3339))
+		}
+	}
+	return w.Flush()
+}
+
+// NetworkRouter finds router of active network.
+func (a *coreDaemonApplication) NetworkRouter(name string) (*edgerouter.EdgeRouter, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if a.mgr == nil {
+		return nil, cmdError("network manager not started")
+	}
+	net := a.mgr.GetNetwork(name)
+	if net == nil {
+		return nil, cmdError("network \"%v\" not found.", name)
+	}
+	router := net.Router()
+	if router == nil || !net.Active() {
+		return nil, cmdError("network \"%v\" is down.", name)
+	}
+	return router, nil
+}
+
 func (a *coreDaemonApplication) ReloadStaticConfig(path string) {
 	a.lock.Lock()
 	defer a.lock.Unlock()
@@ -249,7 +418,7 @@ func (a *coreDaemonApplication) ReloadStaticConfig(path string) {
 	if a.mgr != nil {
 		errs := a.mgr.UpdateConfigFromFile(a.configPath)
 		if errs.AsError() != nil {
-			a.log.Info("failed to update static config. (err = \"%v\")", errs)
+			a.log.Infof("failed to update static config. (err = \"%v\")", errs)
 		}
 	}
 }
@@ -270,7 +439,7 @@ func (a *coreDaemonApplication) Launch(arbiter *arbit.Arbiter, log *logging.Entr
 	for _, netName := range ctx.Args().Slice() {
 		net := a.mgr.GetNetwork(netName)
 		if net == nil {
-			a.log.Error("network \"%v\" not found.", netName)
+			a.log.Errorf("network \"%v\" not found.", netName)
 			continue
 		}
 		if err = net.Up(); err != nil {
@@ -43,7 +43,7 @@ func (a *CrossmeshApplication) reconfigurateControlRPC(cfg *controlRPCConfig) (e
 			pb.RegisterDaemonControlServer(a.control.rpcServer, a)
 			a.arbiters.control.Go(func() {
 				if err = a.control.rpcServer.Serve(a.control.listener); err != nil {
-					a.log.Errorf("grpc.Server.Serve() failure. (err = \"\")", err)
+					a.log.Errorf("grpc.Server.Serve() failure. (err = \"%v\")", err)
 				}
 
 				a.control.listener.Close()
@@ -452,6 +452,377 @@ func (x *DaemonCommand) GetCategory() string {
 	return ""
 }
 
+type LinkStatisticsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Network string `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+}
+
+func (x *LinkStatisticsRequest) Reset() {
+	*x = LinkStatisticsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_pb_core_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LinkStatisticsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkStatisticsRequest) ProtoMessage() {}
+
+func (x *LinkStatisticsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_pb_core_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkStatisticsRequest.ProtoReflect.Descriptor instead.
+func (*LinkStatisticsRequest) Descriptor() ([]byte, []int) {
+	return file_cmd_pb_core_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LinkStatisticsRequest) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+type LinkStatistics struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FramesSent        uint64 `protobuf:"varint,1,opt,name=frames_sent,json=framesSent,proto3" json:"frames_sent,omitempty"`
+	BytesSent         uint64 `protobuf:"varint,2,opt,name=bytes_sent,json=bytesSent,proto3" json:"bytes_sent,omitempty"`
+	FramesReceived    uint64 `protobuf:"varint,3,opt,name=frames_received,json=framesReceived,proto3" json:"frames_received,omitempty"`
+	BytesReceived     uint64 `protobuf:"varint,4,opt,name=bytes_received,json=bytesReceived,proto3" json:"bytes_received,omitempty"`
+	SendTimeouts      uint64 `protobuf:"varint,5,opt,name=send_timeouts,json=sendTimeouts,proto3" json:"send_timeouts,omitempty"`
+	DemuxErrors       uint64 `protobuf:"varint,6,opt,name=demux_errors,json=demuxErrors,proto3" json:"demux_errors,omitempty"`
+	HandshakeFailures uint64 `protobuf:"varint,7,opt,name=handshake_failures,json=handshakeFailures,proto3" json:"handshake_failures,omitempty"`
+	Reconnects        uint64 `protobuf:"varint,8,opt,name=reconnects,proto3" json:"reconnects,omitempty"`
+}
+
+func (x *LinkStatistics) Reset() {
+	*x = LinkStatistics{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_pb_core_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LinkStatistics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkStatistics) ProtoMessage() {}
+
+func (x *LinkStatistics) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_pb_core_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkStatistics.ProtoReflect.Descriptor instead.
+func (*LinkStatistics) Descriptor() ([]byte, []int) {
+	return file_cmd_pb_core_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *LinkStatistics) GetFramesSent() uint64 {
+	if x != nil {
+		return x.FramesSent
+	}
+	return 0
+}
+
+func (x *LinkStatistics) GetBytesSent() uint64 {
+	if x != nil {
+		return x.BytesSent
+	}
+	return 0
+}
+
+func (x *LinkStatistics) GetFramesReceived() uint64 {
+	if x != nil {
+		return x.FramesReceived
+	}
+	return 0
+}
+
+func (x *LinkStatistics) GetBytesReceived() uint64 {
+	if x != nil {
+		return x.BytesReceived
+	}
+	return 0
+}
+
+func (x *LinkStatistics) GetSendTimeouts() uint64 {
+	if x != nil {
+		return x.SendTimeouts
+	}
+	return 0
+}
+
+func (x *LinkStatistics) GetDemuxErrors() uint64 {
+	if x != nil {
+		return x.DemuxErrors
+	}
+	return 0
+}
+
+func (x *LinkStatistics) GetHandshakeFailures() uint64 {
+	if x != nil {
+		return x.HandshakeFailures
+	}
+	return 0
+}
+
+func (x *LinkStatistics) GetReconnects() uint64 {
+	if x != nil {
+		return x.Reconnects
+	}
+	return 0
+}
+
+type LinkStatisticsResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result *Result                      `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	Links  []*LinkStatisticsResult_Link `protobuf:"bytes,2,rep,name=links,proto3" json:"links,omitempty"`
+	Peers  []*LinkStatisticsResult_Peer `protobuf:"bytes,3,rep,name=peers,proto3" json:"peers,omitempty"`
+}
+
+func (x *LinkStatisticsResult) Reset() {
+	*x = LinkStatisticsResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_pb_core_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LinkStatisticsResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkStatisticsResult) ProtoMessage() {}
+
+func (x *LinkStatisticsResult) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_pb_core_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkStatisticsResult.ProtoReflect.Descriptor instead.
+func (*LinkStatisticsResult) Descriptor() ([]byte, []int) {
+	return file_cmd_pb_core_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *LinkStatisticsResult) GetResult() *Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *LinkStatisticsResult) GetLinks() []*LinkStatisticsResult_Link {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *LinkStatisticsResult) GetPeers() []*LinkStatisticsResult_Peer {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+type LinkStatisticsResult_Link struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Endpoint   string          `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Peer       string          `protobuf:"bytes,2,opt,name=peer,proto3" json:"peer,omitempty"`
+	Remote     string          `protobuf:"bytes,3,opt,name=remote,proto3" json:"remote,omitempty"`
+	Stripe     uint32          `protobuf:"varint,4,opt,name=stripe,proto3" json:"stripe,omitempty"`
+	Statistics *LinkStatistics `protobuf:"bytes,5,opt,name=statistics,proto3" json:"statistics,omitempty"`
+}
+
+func (x *LinkStatisticsResult_Link) Reset() {
+	*x = LinkStatisticsResult_Link{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_pb_core_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LinkStatisticsResult_Link) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkStatisticsResult_Link) ProtoMessage() {}
+
+func (x *LinkStatisticsResult_Link) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_pb_core_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkStatisticsResult_Link.ProtoReflect.Descriptor instead.
+func (*LinkStatisticsResult_Link) Descriptor() ([]byte, []int) {
+	return file_cmd_pb_core_proto_rawDescGZIP(), []int{8, 0}
+}
+
+func (x *LinkStatisticsResult_Link) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *LinkStatisticsResult_Link) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *LinkStatisticsResult_Link) GetRemote() string {
+	if x != nil {
+		return x.Remote
+	}
+	return ""
+}
+
+func (x *LinkStatisticsResult_Link) GetStripe() uint32 {
+	if x != nil {
+		return x.Stripe
+	}
+	return 0
+}
+
+func (x *LinkStatisticsResult_Link) GetStatistics() *LinkStatistics {
+	if x != nil {
+		return x.Statistics
+	}
+	return nil
+}
+
+type LinkStatisticsResult_Peer struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name            string          `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Links           uint32          `protobuf:"varint,2,opt,name=links,proto3" json:"links,omitempty"`
+	Statistics      *LinkStatistics `protobuf:"bytes,3,opt,name=statistics,proto3" json:"statistics,omitempty"`
+	FramesForwarded uint64          `protobuf:"varint,4,opt,name=frames_forwarded,json=framesForwarded,proto3" json:"frames_forwarded,omitempty"`
+	FramesDelivered uint64          `protobuf:"varint,5,opt,name=frames_delivered,json=framesDelivered,proto3" json:"frames_delivered,omitempty"`
+}
+
+func (x *LinkStatisticsResult_Peer) Reset() {
+	*x = LinkStatisticsResult_Peer{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_pb_core_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LinkStatisticsResult_Peer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkStatisticsResult_Peer) ProtoMessage() {}
+
+func (x *LinkStatisticsResult_Peer) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_pb_core_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkStatisticsResult_Peer.ProtoReflect.Descriptor instead.
+func (*LinkStatisticsResult_Peer) Descriptor() ([]byte, []int) {
+	return file_cmd_pb_core_proto_rawDescGZIP(), []int{8, 1}
+}
+
+func (x *LinkStatisticsResult_Peer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *LinkStatisticsResult_Peer) GetLinks() uint32 {
+	if x != nil {
+		return x.Links
+	}
+	return 0
+}
+
+func (x *LinkStatisticsResult_Peer) GetStatistics() *LinkStatistics {
+	if x != nil {
+		return x.Statistics
+	}
+	return nil
+}
+
+func (x *LinkStatisticsResult_Peer) GetFramesForwarded() uint64 {
+	if x != nil {
+		return x.FramesForwarded
+	}
+	return 0
+}
+
+func (x *LinkStatisticsResult_Peer) GetFramesDelivered() uint64 {
+	if x != nil {
+		return x.FramesDelivered
+	}
+	return 0
+}
+
 var File_cmd_pb_core_proto protoreflect.FileDescriptor
 
 var file_cmd_pb_core_proto_rawDesc = []byte{
@@ -490,24 +861,83 @@ var file_cmd_pb_core_proto_rawDesc = []byte{
 	0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x6c, 0x69, 0x61, 0x73, 0x65, 0x73, 0x18, 0x03,
 	0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x61, 0x6c, 0x69, 0x61, 0x73, 0x65, 0x73, 0x12, 0x1a, 0x0a,
 	0x08, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x08, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x32, 0xd7, 0x01, 0x0a, 0x0d, 0x44, 0x61,
-	0x65, 0x6d, 0x6f, 0x6e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x12, 0x2f, 0x0a, 0x0c, 0x52,
-	0x65, 0x6c, 0x6f, 0x61, 0x64, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x11, 0x2e, 0x70, 0x62,
-	0x2e, 0x52, 0x65, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0a,
-	0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x00, 0x12, 0x4b, 0x0a, 0x0e,
-	0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x65, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x19,
-	0x2e, 0x70, 0x62, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x45, 0x78, 0x65, 0x63, 0x75,
-	0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x70, 0x62, 0x2e, 0x43,
-	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x65, 0x52, 0x65, 0x73,
-	0x75, 0x6c, 0x74, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x48, 0x0a, 0x11, 0x47, 0x65, 0x74,
-	0x44, 0x61, 0x65, 0x6d, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x1c,
-	0x2e, 0x70, 0x62, 0x2e, 0x44, 0x61, 0x65, 0x6d, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
-	0x64, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x11, 0x2e, 0x70,
-	0x62, 0x2e, 0x44, 0x61, 0x65, 0x6d, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x22,
-	0x00, 0x30, 0x01, 0x42, 0x24, 0x5a, 0x22, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
-	0x6d, 0x2f, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x6d, 0x65, 0x73, 0x68, 0x2f, 0x66, 0x61, 0x62, 0x72,
-	0x69, 0x63, 0x2f, 0x63, 0x6d, 0x64, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x08, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x22, 0x31, 0x0a, 0x15, 0x4c, 0x69, 0x6e,
+	0x6b, 0x53, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x22, 0xb7, 0x02, 0x0a,
+	0x0e, 0x4c, 0x69, 0x6e, 0x6b, 0x53, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x12,
+	0x1f, 0x0a, 0x0b, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x5f, 0x73, 0x65, 0x6e, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x53, 0x65, 0x6e, 0x74,
+	0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x79, 0x74, 0x65, 0x73, 0x5f, 0x73, 0x65, 0x6e, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x62, 0x79, 0x74, 0x65, 0x73, 0x53, 0x65, 0x6e, 0x74, 0x12,
+	0x27, 0x0a, 0x0f, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x5f, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76,
+	0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73,
+	0x52, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x12, 0x25, 0x0a, 0x0e, 0x62, 0x79, 0x74, 0x65,
+	0x73, 0x5f, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x0d, 0x62, 0x79, 0x74, 0x65, 0x73, 0x52, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x12,
+	0x23, 0x0a, 0x0d, 0x73, 0x65, 0x6e, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x73,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x73, 0x65, 0x6e, 0x64, 0x54, 0x69, 0x6d, 0x65,
+	0x6f, 0x75, 0x74, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x65, 0x6d, 0x75, 0x78, 0x5f, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x64, 0x65, 0x6d, 0x75,
+	0x78, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x12, 0x2d, 0x0a, 0x12, 0x68, 0x61, 0x6e, 0x64, 0x73,
+	0x68, 0x61, 0x6b, 0x65, 0x5f, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x11, 0x68, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x46, 0x61,
+	0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x63, 0x6f, 0x6e, 0x6e,
+	0x65, 0x63, 0x74, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x72, 0x65, 0x63, 0x6f,
+	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x73, 0x22, 0xfe, 0x03, 0x0a, 0x14, 0x4c, 0x69, 0x6e, 0x6b, 0x53,
+	0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12,
+	0x22, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0a, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x33, 0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x69, 0x6e, 0x6b, 0x53, 0x74, 0x61, 0x74,
+	0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x4c, 0x69, 0x6e,
+	0x6b, 0x52, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x33, 0x0a, 0x05, 0x70, 0x65, 0x65, 0x72,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x69, 0x6e,
+	0x6b, 0x53, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x52, 0x05, 0x70, 0x65, 0x65, 0x72, 0x73, 0x1a, 0x9a, 0x01,
+	0x0a, 0x04, 0x4c, 0x69, 0x6e, 0x6b, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x12, 0x16,
+	0x0a, 0x06, 0x73, 0x74, 0x72, 0x69, 0x70, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06,
+	0x73, 0x74, 0x72, 0x69, 0x70, 0x65, 0x12, 0x32, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x74, 0x69, 0x73,
+	0x74, 0x69, 0x63, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x62, 0x2e,
+	0x4c, 0x69, 0x6e, 0x6b, 0x53, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x0a,
+	0x73, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x1a, 0xba, 0x01, 0x0a, 0x04, 0x50,
+	0x65, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x32, 0x0a,
+	0x0a, 0x73, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x12, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x69, 0x6e, 0x6b, 0x53, 0x74, 0x61, 0x74, 0x69,
+	0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x0a, 0x73, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63,
+	0x73, 0x12, 0x29, 0x0a, 0x10, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x5f, 0x66, 0x6f, 0x72, 0x77,
+	0x61, 0x72, 0x64, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x66, 0x72, 0x61,
+	0x6d, 0x65, 0x73, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x65, 0x64, 0x12, 0x29, 0x0a, 0x10,
+	0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x5f, 0x64, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x44, 0x65,
+	0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x32, 0xa3, 0x02, 0x0a, 0x0d, 0x44, 0x61, 0x65, 0x6d,
+	0x6f, 0x6e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x12, 0x2f, 0x0a, 0x0c, 0x52, 0x65, 0x6c,
+	0x6f, 0x61, 0x64, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x11, 0x2e, 0x70, 0x62, 0x2e, 0x52,
+	0x65, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0a, 0x2e, 0x70,
+	0x62, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x00, 0x12, 0x4b, 0x0a, 0x0e, 0x45, 0x78,
+	0x65, 0x63, 0x75, 0x74, 0x65, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x19, 0x2e, 0x70,
+	0x62, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x70, 0x62, 0x2e, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x48, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x44, 0x61,
+	0x65, 0x6d, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x1c, 0x2e, 0x70,
+	0x62, 0x2e, 0x44, 0x61, 0x65, 0x6d, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x4c,
+	0x69, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x11, 0x2e, 0x70, 0x62, 0x2e,
+	0x44, 0x61, 0x65, 0x6d, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x22, 0x00, 0x30,
+	0x01, 0x12, 0x4a, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x4c, 0x69, 0x6e, 0x6b, 0x53, 0x74, 0x61, 0x74,
+	0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x12, 0x19, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x69, 0x6e, 0x6b,
+	0x53, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x18, 0x2e, 0x70, 0x62, 0x2e, 0x4c, 0x69, 0x6e, 0x6b, 0x53, 0x74, 0x61, 0x74, 0x69,
+	0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x00, 0x42, 0x24, 0x5a,
+	0x22, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x72, 0x6f, 0x73,
+	0x73, 0x6d, 0x65, 0x73, 0x68, 0x2f, 0x66, 0x61, 0x62, 0x72, 0x69, 0x63, 0x2f, 0x63, 0x6d, 0x64,
+	0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -523,31 +953,43 @@ func file_cmd_pb_core_proto_rawDescGZIP() []byte {
 }
 
 var file_cmd_pb_core_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_cmd_pb_core_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_cmd_pb_core_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_cmd_pb_core_proto_goTypes = []interface{}{
-	(Result_Type)(0),                 // 0: pb.Result.Type
-	(CommandExecuteResult_Type)(0),   // 1: pb.CommandExecuteResult.Type
-	(*ReloadRequest)(nil),            // 2: pb.ReloadRequest
-	(*Result)(nil),                   // 3: pb.Result
-	(*CommandExecuteRequest)(nil),    // 4: pb.CommandExecuteRequest
-	(*CommandExecuteResult)(nil),     // 5: pb.CommandExecuteResult
-	(*DaemonCommandListRequest)(nil), // 6: pb.DaemonCommandListRequest
-	(*DaemonCommand)(nil),            // 7: pb.DaemonCommand
+	(Result_Type)(0),                  // 0: pb.Result.Type
+	(CommandExecuteResult_Type)(0),    // 1: pb.CommandExecuteResult.Type
+	(*ReloadRequest)(nil),             // 2: pb.ReloadRequest
+	(*Result)(nil),                    // 3: pb.Result
+	(*CommandExecuteRequest)(nil),     // 4: pb.CommandExecuteRequest
+	(*CommandExecuteResult)(nil),      // 5: pb.CommandExecuteResult
+	(*DaemonCommandListRequest)(nil),  // 6: pb.DaemonCommandListRequest
+	(*DaemonCommand)(nil),             // 7: pb.DaemonCommand
+	(*LinkStatisticsRequest)(nil),     // 8: pb.LinkStatisticsRequest
+	(*LinkStatistics)(nil),            // 9: pb.LinkStatistics
+	(*LinkStatisticsResult)(nil),      // 10: pb.LinkStatisticsResult
+	(*LinkStatisticsResult_Link)(nil), // 11: pb.LinkStatisticsResult.Link
+	(*LinkStatisticsResult_Peer)(nil), // 12: pb.LinkStatisticsResult.Peer
 }
 var file_cmd_pb_core_proto_depIdxs = []int32{
-	0, // 0: pb.Result.type:type_name -> pb.Result.Type
-	1, // 1: pb.CommandExecuteResult.type:type_name -> pb.CommandExecuteResult.Type
-	2, // 2: pb.DaemonControl.ReloadConfig:input_type -> pb.ReloadRequest
-	4, // 3: pb.DaemonControl.ExecuteCommand:input_type -> pb.CommandExecuteRequest
-	6, // 4: pb.DaemonControl.GetDaemonCommands:input_type -> pb.DaemonCommandListRequest
-	3, // 5: pb.DaemonControl.ReloadConfig:output_type -> pb.Result
-	5, // 6: pb.DaemonControl.ExecuteCommand:output_type -> pb.CommandExecuteResult
-	7, // 7: pb.DaemonControl.GetDaemonCommands:output_type -> pb.DaemonCommand
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: pb.Result.type:type_name -> pb.Result.Type
+	1,  // 1: pb.CommandExecuteResult.type:type_name -> pb.CommandExecuteResult.Type
+	3,  // 2: pb.LinkStatisticsResult.result:type_name -> pb.Result
+	11, // 3: pb.LinkStatisticsResult.links:type_name -> pb.LinkStatisticsResult.Link
+	12, // 4: pb.LinkStatisticsResult.peers:type_name -> pb.LinkStatisticsResult.Peer
+	9,  // 5: pb.LinkStatisticsResult.Link.statistics:type_name -> pb.LinkStatistics
+	9,  // 6: pb.LinkStatisticsResult.Peer.statistics:type_name -> pb.LinkStatistics
+	2,  // 7: pb.DaemonControl.ReloadConfig:input_type -> pb.ReloadRequest
+	4,  // 8: pb.DaemonControl.ExecuteCommand:input_type -> pb.CommandExecuteRequest
+	6,  // 9: pb.DaemonControl.GetDaemonCommands:input_type -> pb.DaemonCommandListRequest
+	8,  // 10: pb.DaemonControl.GetLinkStatistics:input_type -> pb.LinkStatisticsRequest
+	3,  // 11: pb.DaemonControl.ReloadConfig:output_type -> pb.Result
+	5,  // 12: pb.DaemonControl.ExecuteCommand:output_type -> pb.CommandExecuteResult
+	7,  // 13: pb.DaemonControl.GetDaemonCommands:output_type -> pb.DaemonCommand
+	10, // 14: pb.DaemonControl.GetLinkStatistics:output_type -> pb.LinkStatisticsResult
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_cmd_pb_core_proto_init() }
@@ -628,6 +1070,66 @@ func file_cmd_pb_core_proto_init() {
 				return nil
 			}
 		}
+		file_cmd_pb_core_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LinkStatisticsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_pb_core_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LinkStatistics); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_pb_core_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LinkStatisticsResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_pb_core_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LinkStatisticsResult_Link); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_pb_core_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LinkStatisticsResult_Peer); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -635,7 +1137,7 @@ func file_cmd_pb_core_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_cmd_pb_core_proto_rawDesc,
 			NumEnums:      2,
-			NumMessages:   6,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
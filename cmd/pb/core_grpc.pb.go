@@ -20,6 +20,7 @@ type DaemonControlClient interface {
 	ReloadConfig(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*Result, error)
 	ExecuteCommand(ctx context.Context, opts ...grpc.CallOption) (DaemonControl_ExecuteCommandClient, error)
 	GetDaemonCommands(ctx context.Context, in *DaemonCommandListRequest, opts ...grpc.CallOption) (DaemonControl_GetDaemonCommandsClient, error)
+	GetLinkStatistics(ctx context.Context, in *LinkStatisticsRequest, opts ...grpc.CallOption) (*LinkStatisticsResult, error)
 }
 
 type daemonControlClient struct {
@@ -102,6 +103,15 @@ func (x *daemonControlGetDaemonCommandsClient) Recv() (*DaemonCommand, error) {
 	return m, nil
 }
 
+func (c *daemonControlClient) GetLinkStatistics(ctx context.Context, in *LinkStatisticsRequest, opts ...grpc.CallOption) (*LinkStatisticsResult, error) {
+	out := new(LinkStatisticsResult)
+	err := c.cc.Invoke(ctx, "/pb.DaemonControl/GetLinkStatistics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DaemonControlServer is the server API for DaemonControl service.
 // All implementations must embed UnimplementedDaemonControlServer
 // for forward compatibility
@@ -109,6 +119,7 @@ type DaemonControlServer interface {
 	ReloadConfig(context.Context, *ReloadRequest) (*Result, error)
 	ExecuteCommand(DaemonControl_ExecuteCommandServer) error
 	GetDaemonCommands(*DaemonCommandListRequest, DaemonControl_GetDaemonCommandsServer) error
+	GetLinkStatistics(context.Context, *LinkStatisticsRequest) (*LinkStatisticsResult, error)
 	mustEmbedUnimplementedDaemonControlServer()
 }
 
@@ -125,6 +136,9 @@ func (UnimplementedDaemonControlServer) ExecuteCommand(DaemonControl_ExecuteComm
 func (UnimplementedDaemonControlServer) GetDaemonCommands(*DaemonCommandListRequest, DaemonControl_GetDaemonCommandsServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetDaemonCommands not implemented")
 }
+func (UnimplementedDaemonControlServer) GetLinkStatistics(context.Context, *LinkStatisticsRequest) (*LinkStatisticsResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLinkStatistics not implemented")
+}
 func (UnimplementedDaemonControlServer) mustEmbedUnimplementedDaemonControlServer() {}
 
 // UnsafeDaemonControlServer may be embedded to opt out of forward compatibility for this service.
@@ -203,6 +217,24 @@ func (x *daemonControlGetDaemonCommandsServer) Send(m *DaemonCommand) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _DaemonControl_GetLinkStatistics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LinkStatisticsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonControlServer).GetLinkStatistics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.DaemonControl/GetLinkStatistics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonControlServer).GetLinkStatistics(ctx, req.(*LinkStatisticsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _DaemonControl_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.DaemonControl",
 	HandlerType: (*DaemonControlServer)(nil),
@@ -211,6 +243,10 @@ var _DaemonControl_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ReloadConfig",
 			Handler:    _DaemonControl_ReloadConfig_Handler,
 		},
+		{
+			MethodName: "GetLinkStatistics",
+			Handler:    _DaemonControl_GetLinkStatistics_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"sort"
+
+	"github.com/crossmesh/fabric/backend"
+	"github.com/crossmesh/fabric/cmd/pb"
+)
+
+func linkStatisticsToPB(s *backend.LinkStats) *pb.LinkStatistics {
+	return &pb.LinkStatistics{
+		FramesSent:        s.FramesSent,
+		BytesSent:         s.BytesSent,
+		FramesReceived:    s.FramesReceived,
+		BytesReceived:     s.BytesReceived,
+		SendTimeouts:      s.SendTimeouts,
+		DemuxErrors:       s.DemuxErrors,
+		HandshakeFailures: s.HandshakeFailures,
+		Reconnects:        s.Reconnects,
+	}
+}
+
+// GetLinkStatistics implements gRPC method "GetLinkStatistics".
+func (a *CrossmeshApplication) GetLinkStatistics(ctx context.Context, req *pb.LinkStatisticsRequest) (*pb.LinkStatisticsResult, error) {
+	if req == nil {
+		return &pb.LinkStatisticsResult{Result: resultInvalidRequest}, nil
+	}
+	if len(req.Network) < 1 {
+		return &pb.LinkStatisticsResult{Result: &pb.Result{Type: pb.Result_failed, Message: "invalid request: missing network."}}, nil
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	for _, app := range a.apps {
+		provider, isProvider := app.(networkRouterProvider)
+		if !isProvider {
+			continue
+		}
+		router, err := provider.NetworkRouter(req.Network)
+		if err != nil {
+			return &pb.LinkStatisticsResult{Result: &pb.Result{Type: pb.Result_failed, Message: err.Error()}}, nil
+		}
+
+		result := &pb.LinkStatisticsResult{Result: resultOK}
+		links := router.Links()
+		endpoints := make([]backend.Endpoint, 0, len(links))
+		for endpoint := range links {
+			endpoints = append(endpoints, endpoint)
+		}
+		sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].String() < endpoints[j].String() })
+		for _, endpoint := range endpoints {
+			for idx := range links[endpoint] {
+				info := &links[endpoint][idx]
+				result.Links = append(result.Links, &pb.LinkStatisticsResult_Link{
+					Endpoint:   endpoint.String(),
+					Peer:       info.Publish,
+					Remote:     info.Remote,
+					Stripe:     uint32(info.Stripe),
+					Statistics: linkStatisticsToPB(&info.Stats),
+				})
+			}
+		}
+		for idx, stats := 0, router.PeerStatistics(); idx < len(stats); idx++ {
+			stat := &stats[idx]
+			result.Peers = append(result.Peers, &pb.LinkStatisticsResult_Peer{
+				Name:            stat.Name,
+				Links:           uint32(stat.Links),
+				Statistics:      linkStatisticsToPB(&stat.LinkStats),
+				FramesForwarded: stat.Forwarded,
+				FramesDelivered: stat.Delivered,
+			})
+		}
+		return result, nil
+	}
+
+	return &pb.LinkStatisticsResult{Result: &pb.Result{Type: pb.Result_failed, Message: "network manager not started."}}, nil
+}
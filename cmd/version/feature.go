@@ -8,6 +8,20 @@ import (
 const (
 	// HealthProbing is ID of metanet's health probing feature.
 	HealthProbing = 0
+	// LinkKeyExchange is ID of ephemeral key exchange feature in link handshake.
+	LinkKeyExchange = 1
+	// LinkRekey is ID of in-band rekeying feature of link.
+	LinkRekey = 2
+	// LinkChaCha20Poly1305 is ID of ChaCha20-Poly1305 cipher suite support of link.
+	LinkChaCha20Poly1305 = 3
+	// LinkStriping is ID of parallel connections support of link.
+	LinkStriping = 4
+	// LinkCompression is ID of payload compression support of link.
+	LinkCompression = 5
+	// LinkLengthFraming is ID of length-prefixed framing support of unencrypted link.
+	LinkLengthFraming = 6
+	// LinkParallelRecords is ID of sequenced record format sealing frames in parallel.
+	LinkParallelRecords = 7
 )
 
 var (
@@ -20,7 +34,14 @@ var (
 
 // FeatureNames maps feature to it's name.
 var FeatureNames map[int]string = map[int]string{
-	HealthProbing: "health_probe",
+	HealthProbing:        "health_probe",
+	LinkKeyExchange:      "link_kx",
+	LinkRekey:            "link_rekey",
+	LinkChaCha20Poly1305: "link_chacha20poly1305",
+	LinkStriping:         "link_striping",
+	LinkCompression:      "link_compression",
+	LinkLengthFraming:    "link_length_framing",
+	LinkParallelRecords:  "link_parallel_records",
 }
 
 // FeatureSet contains feature enabling states.
@@ -159,6 +159,16 @@ func TestIPNetSet(t *testing.T) {
 		assert.Contains(t, set, nets[0])
 	})
 
+	t.Run("remove_tail", func(t *testing.T) {
+		set := ipSetFromCIDRList("10.240.5.0/24", "fd00:5::/64")
+		set.Build()
+		assert.True(t, set.Remove(ipSetFromCIDRList("fd00:5::/64")...))
+		assert.Equal(t, ipSetFromCIDRList("10.240.5.0/24"), set)
+		assert.True(t, set.Remove(ipSetFromCIDRList("10.240.5.0/24", "fd00:5::/64")...))
+		assert.Equal(t, 0, set.Len())
+		assert.False(t, set.Remove(ipSetFromCIDRList("10.240.5.0/24")...))
+	})
+
 	t.Run("overlap_find", func(t *testing.T) {
 		var nets [3]*net.IPNet
 		var err error
@@ -89,12 +89,12 @@ func SortedSetSubstract(l, r SortedSetInterface, less func(x, y interface{}) boo
 			lh++
 			eli++
 		}
-		if lh < l.Len() && eli != lh {
-			for lh < l.Len() {
+		for ; lh < l.Len(); lh, eli = lh+1, eli+1 { // keep the rest.
+			if eli != lh {
 				l.Swap(eli, lh)
-				lh++
-				eli++
 			}
+		}
+		if eli != lh {
 			l.Pop(lh - eli)
 			changed = true
 		}
@@ -31,6 +31,17 @@ type Backend struct {
 
 	// pre-shared key.
 	PSK string `json:"psk" yaml:"psk"`
+	// pre-shared keys accepted for incoming handshakes besides PSK. PSK is the active one
+	// signing outgoing handshakes.
+	AcceptedPSK []string `json:"acceptedPSK" yaml:"acceptedPSK"`
+
+	// maximum clock skew in second of handshake timestamp. Stale handshakes are rejected as replays.
+	// (default: 120. 0 to disable, accepting peers in older versions without timestamp.)
+	HelloWindow *int `json:"helloWindow" yaml:"helloWindow"`
+	// failed authentications in a minute before source is banned. (default: 5, negative to disable)
+	BanThreshold int `json:"banThreshold" yaml:"banThreshold"`
+	// ban period in second. (default: 600)
+	BanPeriod int `json:"banPeriod" yaml:"banPeriod"`
 
 	// backend engine.
 	Type string `json:"type" yaml:"type"`
@@ -1,8 +1,10 @@
 package control
 
 import (
+	"strings"
 	"sync"
 
+	"github.com/crossmesh/fabric/backend"
 	"github.com/crossmesh/fabric/config"
 	"github.com/crossmesh/fabric/edgerouter"
 	arbit "github.com/sunmxt/arbiter"
@@ -15,6 +17,18 @@ type Network struct {
 	router  *edgerouter.EdgeRouter
 	arbiter *arbit.Arbiter
 	cfg     *config.Network
+	tls     string // fingerprint of TLS credentials applied.
+}
+
+// tlsFingerprint digests TLS credentials of backends.
+func tlsFingerprint(cfg *config.Network) string {
+	var b strings.Builder
+	for _, backendCfg := range cfg.Backend {
+		if backendCfg != nil {
+			b.WriteString(backend.TLSFingerprint(backendCfg))
+		}
+	}
+	return b.String()
 }
 
 func newNetwork(mgr *NetworkManager) *Network {
@@ -54,6 +68,7 @@ func (n *Network) Up() (err error) {
 			return err
 		}
 		n.router.ApplyConfig(n.cfg)
+		n.tls = tlsFingerprint(n.cfg)
 	}
 
 	return nil
@@ -71,9 +86,14 @@ func (n *Network) Reload(net *config.Network) error {
 		return nil
 	}
 	if net.Equal(n.cfg) {
+		// certificates may be updated.
+		if fingerprint := tlsFingerprint(net); fingerprint != n.tls {
+			n.tls = fingerprint
+			return n.router.ReloadBackends()
+		}
 		return nil
 	}
-	n.cfg = net
+	n.cfg, n.tls = net, tlsFingerprint(net)
 
 	return n.router.ApplyConfig(net)
 }
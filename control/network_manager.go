@@ -96,7 +96,7 @@ func (n *NetworkManager) UpdateConfig(cfg *coreDaemonConfig) (errs common.Errors
 		}
 		// update
 		if err := net.Reload(netCfg); err != nil {
-			n.log.Error("reload network \"%v\" failure: ", err)
+			n.log.Errorf("reload network \"%v\" failure: %v", name, err)
 			errs.Trace(errs)
 		}
 		delete(networks, name)
@@ -105,7 +105,7 @@ func (n *NetworkManager) UpdateConfig(cfg *coreDaemonConfig) (errs common.Errors
 	for name, netCfg := range networks {
 		net := newNetwork(n)
 		if err := net.Reload(netCfg); err != nil {
-			n.log.Error("start network \"%v\" failure: ", err)
+			n.log.Errorf("start network \"%v\" failure: %v", name, err)
 			errs.Trace(errs)
 			continue
 		}
@@ -103,7 +103,7 @@ func (r *EdgeRouter) goApplyConfig(cfg *config.Network, cidr string) {
 
 				case "ip":
 					log.Info("network mode: ip")
-					r.route = route.NewP2PL3IPv4MeshNetworkRouter()
+					r.route = route.NewP2PL3MeshNetworkRouter()
 				}
 
 			}
@@ -119,7 +119,7 @@ func (r *EdgeRouter) goApplyConfig(cfg *config.Network, cidr string) {
 			}
 
 			if old, err := r.metaNet.SetRegion(cfg.Region); err != nil {
-				log.Error("cannot set region for metadata network. (err = \"%v\")", err)
+				log.Errorf("cannot set region for metadata network. (err = \"%v\")", err)
 				succeed = false
 				continue
 			} else if old != cfg.Region {
@@ -158,6 +158,21 @@ func (r *EdgeRouter) goApplyConfig(cfg *config.Network, cidr string) {
 	})
 }
 
+// ReloadBackends reapplies current backend configuration.
+// Backends reload credentials in place without breaking established links.
+func (r *EdgeRouter) ReloadBackends() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.cfg == nil {
+		return nil
+	}
+	if !r.updateBackends(r.cfg.Backend) {
+		return fmt.Errorf("no valid backend")
+	}
+	return nil
+}
+
 func (r *EdgeRouter) ApplyConfig(cfg *config.Network) (err error) {
 	defer func() {
 		if err != nil {
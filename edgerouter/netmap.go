@@ -124,7 +124,7 @@ func (r *EdgeRouter) processOnPeerJoin(peer *metanet.MetaPeer) {
 	}))
 
 	if err := errs.AsError(); err != nil {
-		r.log.Error("some errors raised during processing peer join event. retry later. (err = \"%v\")", err)
+		r.log.Errorf("some errors raised during processing peer join event. retry later. (err = \"%v\")", err)
 		r.delayProcessOnPeerJoin(peer, time.Second*5)
 	}
 }
@@ -149,6 +149,8 @@ func (r *EdgeRouter) onPeerLeave(peer *metanet.MetaPeer) bool {
 	delete(r.networkMap, peer)
 	r.lock.Unlock()
 
+	r.forwards.Delete(peer)
+
 	return true
 }
 
@@ -174,7 +176,7 @@ func (r *EdgeRouter) rebuildRoute(lock bool) {
 		}
 
 	case "ip":
-		route := r.route.(*route.P2PL3IPv4MeshNetworkRouter)
+		route := r.route.(*route.P2PL3MeshNetworkRouter)
 		for peer, netMap := range r.networkMap {
 			paramContainer, appeared := netMap[gossip.NetworkID{
 				ID:         0,
@@ -190,7 +192,9 @@ func (r *EdgeRouter) rebuildRoute(lock bool) {
 			params := paramContainer.(*gossip.CrossmeshOverlayParamV1)
 			if len(params.Subnets) > 0 {
 				r.log.Infof("add static route %v to peer %v.", params.Subnets, names)
-				route.AddStaticCIDRRoutes(peer, params.Subnets...)
+				if err := route.AddStaticCIDRRoutes(peer, params.Subnets...); err != nil {
+					r.log.Errorf("cannot add static routes to peer %v. (err = \"%v\")", names, err)
+				}
 			}
 		}
 	}
@@ -198,6 +202,24 @@ func (r *EdgeRouter) rebuildRoute(lock bool) {
 	r.delayProcessOnPeerJoin(r.metaNet.Publish.Self, 0)
 }
 
+// updateStaticRoutes applies changes of subnets advertised by peer.
+func (r *EdgeRouter) updateStaticRoutes(router *route.P2PL3MeshNetworkRouter, netID gossip.NetworkID, peer route.MeshNetPeer, old, new common.IPNetSet) {
+	toRemove := old.Clone()
+	toRemove.Remove(new...)
+	if toRemove.Len() > 0 {
+		r.log.Infof("network %v removes static routes: %v --> %v.", netID, toRemove, peer)
+		router.RemoveStaticCIDRRoutes(peer, toRemove...)
+	}
+	additions := new.Clone()
+	additions.Remove(old...)
+	if additions.Len() > 0 {
+		r.log.Infof("network %v adds static routes: %v --> %v.", netID, additions, peer)
+		if err := router.AddStaticCIDRRoutes(peer, additions...); err != nil {
+			r.log.Errorf("network %v cannot add static routes to peer %v. (err = \"%v\")", netID, peer, err)
+		}
+	}
+}
+
 func (r *EdgeRouter) networkMapLearnNetworkAppeared(peer *metanet.MetaPeer, v1 *gossip.OverlayNetworksV1) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -245,7 +267,7 @@ func (r *EdgeRouter) networkMapLearnNetworkAppeared(peer *metanet.MetaPeer, v1 *
 			}
 
 			if r.Mode() == "ip" {
-				route := r.route.(*route.P2PL3IPv4MeshNetworkRouter)
+				route := r.route.(*route.P2PL3MeshNetworkRouter)
 				if !hasPrev {
 					r.log.Infof("network %v learns a new peer %v.", netID, peer)
 					if isActivityWatcher {
@@ -253,23 +275,13 @@ func (r *EdgeRouter) networkMapLearnNetworkAppeared(peer *metanet.MetaPeer, v1 *
 					}
 					if param.Subnets.Len() > 0 {
 						r.log.Infof("network %v adds static routes: %v --> %v.", netID, param.Subnets, peer)
-						route.AddStaticCIDRRoutes(peer, param.Subnets...)
+						if err := route.AddStaticCIDRRoutes(peer, param.Subnets...); err != nil {
+							r.log.Errorf("network %v cannot add static routes to peer %v. (err = \"%v\")", netID, peer, err)
+						}
 					}
 				} else {
-					// update static routes.
 					oldParam := oldParamContainer.(*gossip.CrossmeshOverlayParamV1)
-					toRemove := oldParam.Subnets.Clone()
-					toRemove.Remove(param.Subnets...)
-					if toRemove.Len() > 0 {
-						r.log.Infof("network %v removes static routes: %v --> %v.", netID, toRemove, peer)
-						route.RemoveStaticCIDRRoutes(peer, toRemove...)
-					}
-					additions := param.Subnets.Clone()
-					additions.Remove(oldParam.Subnets...)
-					if additions.Len() > 0 {
-						r.log.Infof("network %v adds static routes: %v --> %v.", netID, additions, peer)
-						route.AddStaticCIDRRoutes(peer, param.Subnets...)
-					}
+					r.updateStaticRoutes(route, netID, peer, oldParam.Subnets, param.Subnets)
 				}
 			}
 
@@ -284,14 +296,14 @@ func (r *EdgeRouter) networkMapLearnNetworkAppeared(peer *metanet.MetaPeer, v1 *
 		switch netID.DriverType {
 		case gossip.CrossmeshSymmetryEthernet:
 			if r.Mode() == "ethernet" {
-				r.log.Info("peer %v left network %v.", peer, netID)
+				r.log.Infof("peer %v left network %v.", peer, netID)
 				if isActivityWatcher {
 					watcher.PeerLeave(peer)
 				}
 			}
 		case gossip.CrossmeshSymmetryRoute:
 			if r.Mode() == "ip" {
-				r.log.Info("peer %v left network %v.", peer, netID)
+				r.log.Infof("peer %v left network %v.", peer, netID)
 				if isActivityWatcher {
 					watcher.PeerLeave(peer)
 				}
@@ -0,0 +1,64 @@
+package edgerouter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/crossmesh/fabric/common"
+	"github.com/crossmesh/fabric/gossip"
+	"github.com/crossmesh/fabric/route"
+	logging "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMeshPeer struct {
+	id   string
+	self bool
+}
+
+func (p *testMeshPeer) HashID() string { return p.id }
+func (p *testMeshPeer) IsSelf() bool   { return p.self }
+
+func TestUpdateDualStackStaticRoutes(t *testing.T) {
+	subnets := func(cidrs ...string) (set common.IPNetSet) {
+		for _, cidr := range cidrs {
+			_, subnet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			set = append(set, subnet)
+		}
+		set.Build()
+		return
+	}
+	v4Packet := []byte{
+		0x45, 0x00, 0x00, 0x54, 0xa8, 0x52, 0x00, 0x00, 0x40, 0x01, 0xd5, 0xed,
+		10, 240, 4, 2, // src IP: 10.240.4.2
+		10, 240, 5, 1, // dst IP: 10.240.5.1
+	}
+	v6Packet := append([]byte{0x60, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3a, 0x40},
+		append(net.ParseIP("fd00:4::2").To16(), net.ParseIP("fd00:5::1").To16()...)...)
+
+	r := &EdgeRouter{log: logging.WithField("module", "edge_router")}
+	router := route.NewP2PL3MeshNetworkRouter()
+	self, peer1, peer2 := &testMeshPeer{id: "self", self: true}, &testMeshPeer{id: "peer1"}, &testMeshPeer{id: "peer2"}
+	router.PeerJoin(self)
+	router.PeerJoin(peer1)
+	router.PeerJoin(peer2)
+	netID := gossip.NetworkID{ID: 0, DriverType: gossip.CrossmeshSymmetryRoute}
+
+	old := subnets("10.240.5.0/24")
+	assert.NoError(t, router.AddStaticCIDRRoutes(peer1, old...))
+
+	// ipv6 subnet is added while ipv4 subnet is kept.
+	updated := subnets("10.240.5.0/24", "fd00:5::/64")
+	r.updateStaticRoutes(router, netID, peer1, old, updated)
+	assert.Equal(t, []route.MeshNetPeer{peer1}, router.Route(v4Packet, self))
+	assert.Equal(t, []route.MeshNetPeer{peer1}, router.Route(v6Packet, self))
+
+	// ipv4 subnet is withdrawn.
+	old, updated = updated, subnets("fd00:5::/64")
+	r.updateStaticRoutes(router, netID, peer1, old, updated)
+	assert.Equal(t, 2, len(router.Route(v4Packet, self)))
+	assert.Equal(t, []route.MeshNetPeer{peer1}, router.Route(v6Packet, self))
+}
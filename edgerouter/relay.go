@@ -4,22 +4,72 @@ import (
 	"errors"
 	"io"
 	"os"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/crossmesh/fabric/metanet"
+	"github.com/crossmesh/fabric/mux"
 	"github.com/crossmesh/fabric/proto"
 	"github.com/songgao/water"
 )
 
+// forwardStatistics counts frames exchanged between local VTEP and a peer.
 type forwardStatistics struct {
-	read uint32
-	sent uint32
+	forwarded uint64 // frames read from VTEP and sent to peer.
+	delivered uint64 // frames received from peer and written to VTEP.
+}
+
+// PeerStatistics describes traffic exchanged with a peer.
+type PeerStatistics struct {
+	metanet.PeerStatistics
+
+	Forwarded uint64 // frames read from local VTEP and sent to peer.
+	Delivered uint64 // frames received from peer and written to local VTEP.
+}
+
+func (r *EdgeRouter) getForwardStatistics(peer *metanet.MetaPeer) *forwardStatistics {
+	if v, ok := r.forwards.Load(peer); ok {
+		return v.(*forwardStatistics)
+	}
+	v, _ := r.forwards.LoadOrStore(peer, &forwardStatistics{})
+	return v.(*forwardStatistics)
+}
+
+// PeerStatistics reports link and forwarding statistics by peer.
+func (r *EdgeRouter) PeerStatistics() (stats []PeerStatistics) {
+	byName := make(map[string]*PeerStatistics)
+	for _, stat := range r.metaNet.PeerStatistics() {
+		byName[stat.Name] = &PeerStatistics{PeerStatistics: stat}
+	}
+	r.forwards.Range(func(k, v interface{}) bool {
+		peer, forward := k.(*metanet.MetaPeer), v.(*forwardStatistics)
+		names := peer.Names()
+		if len(names) < 1 {
+			return true
+		}
+		stat, _ := byName[names[0]]
+		if stat == nil {
+			stat = &PeerStatistics{PeerStatistics: metanet.PeerStatistics{Name: names[0]}}
+			byName[names[0]] = stat
+		}
+		stat.Forwarded = atomic.LoadUint64(&forward.forwarded)
+		stat.Delivered = atomic.LoadUint64(&forward.delivered)
+		return true
+	})
+	for _, stat := range byName {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return
 }
 
 var (
 	ErrRelayNoBackend = errors.New("backend unavaliable")
 )
 
+const maxVTEPFrameSize = 2048
+
 func (r *EdgeRouter) writeLocalVTEP(lease *vtepQueueLease, frame []byte) (err error) {
 	if err = lease.Tx(func(rw *water.Interface) error {
 		_, err := rw.Write(frame)
@@ -32,7 +82,8 @@ func (r *EdgeRouter) writeLocalVTEP(lease *vtepQueueLease, frame []byte) (err er
 }
 
 func (r *EdgeRouter) receiveRemote(msg *metanet.Message) {
-	peers := r.route.Route(msg.Payload, msg.Peer())
+	from := msg.Peer()
+	peers := r.route.Route(msg.Payload, from)
 
 	eli, isSelf := 0, false
 	for i := 0; i < len(peers); i++ {
@@ -58,7 +109,9 @@ func (r *EdgeRouter) receiveRemote(msg *metanet.Message) {
 		if lease == nil {
 			break
 		}
-		r.writeLocalVTEP(lease, msg.Payload)
+		if err = r.writeLocalVTEP(lease, msg.Payload); err == nil && from != nil {
+			atomic.AddUint64(&r.getForwardStatistics(from).delivered, 1)
+		}
 		break
 	}
 	for _, p := range peers {
@@ -68,15 +121,19 @@ func (r *EdgeRouter) receiveRemote(msg *metanet.Message) {
 }
 
 func (r *EdgeRouter) goForwardVTEP() {
-	buf := make([]byte, 2048)
-
 	r.arbiters.forward.Go(func() {
 		var (
 			lease        *vtepQueueLease
 			err, readErr error
 			read         int
 			peers        []*metanet.MetaPeer
+			frame        *mux.FrameBuffer // frames sent to peers are taken over by metanet.
 		)
+		defer func() {
+			if frame != nil {
+				frame.Release()
+			}
+		}()
 
 		for r.arbiters.forward.ShouldRun() {
 			// acquire queue lease.
@@ -107,7 +164,12 @@ func (r *EdgeRouter) goForwardVTEP() {
 			// forward frames.
 			for r.arbiters.forward.ShouldRun() {
 				// encode frame.
-				readBuf := buf[:]
+				if frame == nil {
+					frame = mux.NewFrameBuffer(maxVTEPFrameSize)
+				} else {
+					frame.Resize(maxVTEPFrameSize)
+				}
+				readBuf := frame.Bytes()
 				err = lease.Tx(func(rw *water.Interface) error {
 					read, readErr = rw.Read(readBuf)
 					if readErr != nil {
@@ -131,7 +193,8 @@ func (r *EdgeRouter) goForwardVTEP() {
 				if read < 1 {
 					continue
 				}
-				readBuf = readBuf[:read]
+				frame.Resize(read)
+				readBuf = frame.Bytes()
 
 				// forward.
 				isSelf := false
@@ -158,7 +221,11 @@ func (r *EdgeRouter) goForwardVTEP() {
 					r.writeLocalVTEP(lease, readBuf)
 				}
 				if len(peers) > 0 {
-					r.metaNet.SendToPeers(proto.MsgTypeRawFrame, readBuf, peers...)
+					r.metaNet.SendFrameToPeers(proto.MsgTypeRawFrame, frame, peers...)
+					frame = nil
+					for _, peer := range peers {
+						atomic.AddUint64(&r.getForwardStatistics(peer).forwarded, 1)
+					}
 				}
 			}
 		}
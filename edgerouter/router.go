@@ -30,6 +30,7 @@ type EdgeRouter struct {
 	vtep *virtualTunnelEndpoint
 
 	endpointFailures sync.Map // map[backend.Endpoint]time.Time
+	forwards         sync.Map // map[*metanet.MetaPeer]*forwardStatistics
 
 	configID uint32
 	cfg      *config.Network
@@ -79,6 +80,21 @@ func (r *EdgeRouter) SeedPeer(endpoints ...backend.Endpoint) error {
 	return r.metaNet.SeedEndpoints(endpoints...)
 }
 
+// Links reports live links of local endpoints.
+func (r *EdgeRouter) Links() map[backend.Endpoint][]backend.LinkInfo {
+	return r.metaNet.Links()
+}
+
+// Bans reports sources banned by local endpoints for authentication failures.
+func (r *EdgeRouter) Bans() map[backend.Endpoint][]backend.BanInfo {
+	return r.metaNet.Bans()
+}
+
+// Paths reports link paths and relays to peers.
+func (r *EdgeRouter) Paths() []metanet.PeerPathInfo {
+	return r.metaNet.Paths()
+}
+
 func (r *EdgeRouter) waitCleanUp() {
 	r.arbiters.main.Go(func() {
 		<-r.arbiters.main.Exit() // watch exit signal.
@@ -1,25 +1,41 @@
 module github.com/crossmesh/fabric
 
-go 1.13
+go 1.20
+
+require (
+	github.com/coreos/go-systemd v0.0.0-20181012123002-c6f51f82210d
+	github.com/crossmesh/sladder v0.0.0-20201018042605-a601ea2299ee
+	github.com/golang/protobuf v1.4.3
+	github.com/golang/snappy v0.0.4
+	github.com/jinzhu/configor v1.1.1
+	github.com/sirupsen/logrus v1.4.2
+	github.com/songgao/water v0.0.0-20190725173103-fd331bda3f4b
+	github.com/stretchr/testify v1.4.0
+	github.com/sunmxt/arbiter v0.0.0-20200507185653-186784ed7c42
+	github.com/urfave/cli/v2 v2.1.1
+	golang.org/x/crypto v0.24.0
+	golang.org/x/net v0.25.0
+	google.golang.org/grpc v1.32.0
+	google.golang.org/protobuf v1.25.0
+)
 
 require (
 	cloud.google.com/go/bigtable v1.3.0 // indirect
 	cloud.google.com/go/firestore v1.1.1 // indirect
 	cloud.google.com/go/logging v1.0.0 // indirect
 	cloud.google.com/go/spanner v1.2.1 // indirect
-	github.com/coreos/go-systemd v0.0.0-20181012123002-c6f51f82210d
+	github.com/BurntSushi/toml v0.3.1 // indirect
 	github.com/coreos/pkg v0.0.0-20180928190104-399ea9e2e55f // indirect
+	github.com/cpuguy83/go-md2man/v2 v2.0.0-20190314233015-f79a8a8ca69d // indirect
 	github.com/creack/pty v1.1.9 // indirect
-	github.com/crossmesh/sladder v0.0.0-20201018042605-a601ea2299ee
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/denisbrodbeck/machineid v1.0.1 // indirect
 	github.com/godbus/dbus v4.1.0+incompatible // indirect
 	github.com/gogo/protobuf v1.3.1 // indirect
-	github.com/golang/protobuf v1.4.3
 	github.com/googleapis/gax-go v2.0.2+incompatible // indirect
 	github.com/grpc-ecosystem/grpc-gateway v1.13.0 // indirect
 	github.com/haya14busa/goplay v1.0.0 // indirect
 	github.com/iancoleman/strcase v0.0.0-20191112232945-16388991a334 // indirect
-	github.com/jinzhu/configor v1.1.1
 	github.com/karrick/godirwalk v1.15.2 // indirect
 	github.com/kr/pretty v0.2.0 // indirect
 	github.com/kr/pty v1.1.8 // indirect
@@ -27,15 +43,13 @@ require (
 	github.com/petar/GoLLRB v0.0.0-20190514000832-33fb24c13b99 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pkg/sftp v1.11.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/russross/blackfriday v2.0.0+incompatible // indirect
-	github.com/sirupsen/logrus v1.4.2
+	github.com/russross/blackfriday/v2 v2.0.1 // indirect
+	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
 	github.com/skratchdot/open-golang v0.0.0-20200116055534-eef842397966 // indirect
-	github.com/songgao/water v0.0.0-20190725173103-fd331bda3f4b
 	github.com/spf13/afero v1.2.2 // indirect
 	github.com/stretchr/objx v0.2.0 // indirect
-	github.com/stretchr/testify v1.4.0
-	github.com/sunmxt/arbiter v0.0.0-20200507185653-186784ed7c42
-	github.com/urfave/cli/v2 v2.1.1
 	github.com/uudashr/gopkgs v2.0.1+incompatible // indirect
 	github.com/vektra/mockery v1.0.0 // indirect
 	gitlab.com/golang-commonmark/html v0.0.0-20191124015941-a22733972181 // indirect
@@ -46,10 +60,11 @@ require (
 	golang.org/x/build v0.0.0-20200226193612-7ece5dab5e4e // indirect
 	golang.org/x/image v0.0.0-20200119044424-58c23975cae1 // indirect
 	golang.org/x/mobile v0.0.0-20200212152714-2b26a4705d24 // indirect
-	google.golang.org/grpc v1.32.0
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto v0.0.0-20200806141610-86f49bd18e98 // indirect
 	google.golang.org/grpc/cmd/protoc-gen-go-grpc v0.0.0-20200917190803-0f7e218c2cf4 // indirect
 	google.golang.org/grpc/examples v0.0.0-20200930182750-2e2833c718b5 // indirect
-	google.golang.org/protobuf v1.25.0
 	gopkg.in/yaml.v1 v1.0.0-20140924161607-9f9df34309c0 // indirect
 	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
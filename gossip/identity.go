@@ -20,7 +20,13 @@ func NewPeerNameResolver() *PeerNameResolver {
 }
 
 // BuildNodeName builds node name from endpoint.
-func BuildNodeName(ep backend.Endpoint) string { return ep.Type.String() + ":" + ep.Endpoint }
+// IP literals are canonicalized so that one address always gives one name. e.g. udp:[2001:db8::1]:3880
+func BuildNodeName(ep backend.Endpoint) string {
+	if endpoint, err := backend.NormalizeEndpoint(ep.Type, ep.Endpoint); err == nil {
+		ep.Endpoint = endpoint
+	}
+	return ep.Type.String() + ":" + ep.Endpoint
+}
 
 func (r *PeerNameResolver) fromNetworkEndpointV1(v1 *NetworkEndpointsV1) (names []string) {
 	for _, ep := range v1.Endpoints {
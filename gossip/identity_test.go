@@ -45,3 +45,30 @@ func TestIdentity(t *testing.T) {
 	assert.Contains(t, names, backend.TCPBackend.String()+":10.240.0.1:3891")
 	assert.Contains(t, names, backend.TCPBackend.String()+":10.240.0.1:3898")
 }
+
+func TestIdentityDualStack(t *testing.T) {
+	resolver := NewPeerNameResolver()
+
+	set := NetworkEndpointSetV1{
+		&NetworkEndpointV1{Type: backend.TCPBackend, Priority: 10, Endpoint: "10.240.0.1:3880"},
+		&NetworkEndpointV1{Type: backend.TCPBackend, Priority: 10, Endpoint: "[2001:db8::1]:3880"},
+		&NetworkEndpointV1{Type: backend.UDPBackend, Priority: 9, Endpoint: "[2001:DB8:0::1]:3880"},
+	}
+	set.Build()
+	v1 := NetworkEndpointsV1{Version: 1, Endpoints: set.Clone()}
+	s, err := v1.EncodeString()
+	assert.NoError(t, err)
+
+	names, err := resolver.Resolve(&sladder.KeyValue{
+		Value: s, Key: resolver.NetworkEndpointKey,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(names))
+	assert.Contains(t, names, "tcp:10.240.0.1:3880")
+	assert.Contains(t, names, "tcp:[2001:db8::1]:3880")
+	assert.Contains(t, names, "udp:[2001:db8::1]:3880")
+
+	assert.Equal(t, "udp:[2001:db8::1]:3880", BuildNodeName(backend.Endpoint{
+		Type: backend.UDPBackend, Endpoint: "[2001:db8:0:0::1]:3880",
+	}))
+}
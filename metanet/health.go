@@ -27,7 +27,12 @@ type lastProbingContext struct {
 }
 
 const defaultHealthyCheckProbeBrust = 5
-const defaultHealthyCheckProbeTimeout = time.Second * 10
+
+// health checking timings. They are variables so that tests can shorten them.
+var (
+	defaultHealthyCheckProbeTimeout  = time.Second * 10
+	defaultHealthyCheckProbeInterval = time.Second * 10
+)
 
 // ErrMessageStreamTooShort raised when there are no enough bytes to decode message.
 // Mostly, it indicates that the message stream is incompleted.
@@ -121,7 +126,7 @@ func (n *MetadataNetwork) onHealthProbingRequest(header *endpointProbeHeader, ms
 		endpointProbeHeader: *header,
 	}
 	resp.isResponse = true
-	payload := resp.Encode()
+	payload := append(resp.Encode(), n.reflectEndpoint(msg, bodyPayload)...)
 	n.log.Debugf("sending probe response to %v. [via = %v, request ID = %v]", msg.Endpoint, msg.Via, header.id)
 	n.SendViaEndpoint(proto.MsgTypePing, payload, msg.Via, msg.Endpoint.Endpoint)
 }
@@ -178,11 +183,12 @@ func (n *MetadataNetwork) onHealthProbingMessage(msg *Message) {
 	header := endpointProbeHeader{}
 	used, err := header.Decode(msg.Payload)
 	if err != nil {
-		n.log.Error("failed to decode a endpoint probing message. [from = %v, via = %v] (err = \"%v\")", msg.Endpoint, msg.Via, err)
+		n.log.Errorf("failed to decode a endpoint probing message. [from = %v, via = %v] (err = \"%v\")", msg.Endpoint, msg.Via, err)
 		return
 	}
 	if header.isResponse {
 		n.onHealthProbingResponse(&header, msg, msg.Payload[used:])
+		n.onEndpointReflection(msg, msg.Payload[used:])
 	} else {
 		n.onHealthProbingRequest(&header, msg, msg.Payload[used:])
 	}
@@ -497,12 +503,16 @@ func (n *MetadataNetwork) goHealthProbe() {
 				n.probes[target.path] = target // save context.
 
 				n.log.Debugf("probe link %v. [request ID = %v]", &target.path, req.id)
+				local := backend.Endpoint{Type: target.path.ty, Endpoint: target.path.local}
 				payload := req.Encode()
-				n.SendViaEndpoint(proto.MsgTypePing, payload, backend.Endpoint{
-					Type: target.path.ty, Endpoint: target.path.local,
-				}, target.path.remote)
+				if candidates := n.reflexiveCandidatesOf(local); len(candidates) > 0 {
+					// ask peer to verify reflexive candidates.
+					reflection := endpointReflection{candidates: candidates}
+					payload = append(payload, reflection.Encode(false)...)
+				}
+				n.SendViaEndpoint(proto.MsgTypePing, payload, local, target.path.remote)
 			}
 		}
 
-	}, time.Second*10, 1)
+	}, defaultHealthyCheckProbeInterval, 1)
 }
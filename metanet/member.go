@@ -12,6 +12,12 @@ import (
 	"github.com/crossmesh/sladder/engine/gossip"
 )
 
+// gossip timings. They are variables so that tests can shorten them.
+var (
+	defaultGossipPeriod      = time.Second * 3
+	defaultGossipQuitTimeout = time.Second * 30
+)
+
 // SeedEndpoints add seed endpoints.
 func (n *MetadataNetwork) SeedEndpoints(endpoints ...backend.Endpoint) (err error) {
 	var errs common.Errors
@@ -97,7 +103,8 @@ func (n *MetadataNetwork) initializeMembership() (err error) {
 	engineLogger := n.log.WithField("submodule", "sladder/gossip")
 	n.gossip.engine = gossip.New(n.gossip.transport,
 		gossip.WithLogger(engineLogger),
-		gossip.WithGossipPeriod(time.Second*3)).(*gossip.EngineInstance)
+		gossip.WithGossipPeriod(defaultGossipPeriod),
+		gossip.WithQuitTimeout(defaultGossipQuitTimeout)).(*gossip.EngineInstance)
 	n.gossip.nameResolver = gossipUtils.NewPeerNameResolver()
 	if n.gossip.cluster, n.gossip.self, err =
 		sladder.NewClusterWithNameResolver(n.gossip.engine, n.gossip.nameResolver, sladder.Logger(logger)); err != nil {
@@ -289,6 +296,28 @@ func (n *MetadataNetwork) onGossipNodeRemoved(node *sladder.Node) {
 	peer.left = true // lazy deletion.
 	delete(n.peers, node)
 
+	// names taken by left peer are recovered for peers conflicting with it.
+	name2Peer, copied := n.Publish.Name2Peer, false
+	for conflict := range n.nameConflictNodes {
+		if conflict.left {
+			continue
+		}
+		for _, name := range conflict.names {
+			if name2Peer[name] != peer {
+				continue
+			}
+			if !copied {
+				name2Peer, copied = make(map[string]*MetaPeer, len(n.Publish.Name2Peer)), true
+				for name, metaPeer := range n.Publish.Name2Peer {
+					name2Peer[name] = metaPeer
+				}
+			}
+			n.log.Warnf("conflict name \"%v\" of peer %v is recovered.", name, conflict)
+			name2Peer[name] = conflict
+		}
+	}
+	n.Publish.Name2Peer = name2Peer
+
 	n.lock.Unlock()
 
 	n.notifyPeerWatcher(&n.peerLeaveWatcher, peer)
@@ -430,6 +459,21 @@ func (n *MetadataNetwork) delayPublishEndpoint(epoch uint32, delay bool) {
 				Priority: backend.Priority(),
 			})
 			newBackends[endpoint] = backend
+
+			// verified reflexive endpoints reach the same backend.
+			for _, candidate := range n.verifiedReflexiveCandidates(endpoint) {
+				reflexive := endpoint
+				reflexive.Endpoint = candidate
+				localPublish = append(localPublish, &MetaPeerEndpoint{
+					Endpoint: reflexive,
+					Priority: backend.Priority(),
+				})
+				newEndpoints = append(newEndpoints, &gossipUtils.NetworkEndpointV1{
+					Type:     reflexive.Type,
+					Endpoint: reflexive.Endpoint,
+					Priority: backend.Priority(),
+				})
+			}
 		}
 
 		var errs common.Errors
@@ -6,18 +6,23 @@ import (
 
 	"github.com/crossmesh/fabric/backend"
 	gossipUtils "github.com/crossmesh/fabric/gossip"
+	"github.com/crossmesh/fabric/mux"
 	"github.com/crossmesh/fabric/proto"
 )
 
 // MessageHandler handle incoming message.
+// Message is pooled and reused once handler returns, so handlers should neither keep it
+// nor use it asynchronously.
 type MessageHandler func(*Message)
 
 // Message contains context of message.
+// Message and its payload are valid only during handling. Handlers should copy what they keep.
 type Message struct {
 	n *MetadataNetwork
 
 	Endpoint backend.Endpoint
 	Via      backend.Endpoint
+	Relay    *MetaPeer // peer relaying the message, if not received directly.
 
 	peer    *MetaPeer
 	TypeID  uint16
@@ -27,7 +32,59 @@ type Message struct {
 
 // GetPeerName calculates name of sender.
 func (m *Message) GetPeerName() string {
-	return gossipUtils.BuildNodeName(m.Endpoint)
+	name := m.n.nodeName(m.Endpoint)
+	if _, known := m.n.Publish.Name2Peer[name]; !known {
+		// sender reached via punched path is named after the endpoint it is punched at.
+		if peer := m.punchedPeer(); peer != nil {
+			if names := peer.Names(); len(names) > 0 {
+				return names[0]
+			}
+		}
+	}
+	return name
+}
+
+func (m *Message) punchedPeer() *MetaPeer {
+	return m.n.punchedPeer(m.Endpoint)
+}
+
+func (n *MetadataNetwork) punchedPeer(endpoint backend.Endpoint) *MetaPeer {
+	v, punched := n.punched.Load(endpoint)
+	if !punched {
+		return nil
+	}
+	return v.(*MetaPeer)
+}
+
+// maxCachedNodeNames limits cached node names. Cache is rebuilt once it's full.
+const maxCachedNodeNames = 4096
+
+// nodeName builds node name of endpoint. Names are cached since peers are looked up per message.
+func (n *MetadataNetwork) nodeName(endpoint backend.Endpoint) string {
+	n.nameLock.RLock()
+	name, cached := n.nodeNames[endpoint]
+	n.nameLock.RUnlock()
+	if cached {
+		return name
+	}
+	name = gossipUtils.BuildNodeName(endpoint)
+
+	n.nameLock.Lock()
+	defer n.nameLock.Unlock()
+	if len(n.nodeNames) >= maxCachedNodeNames {
+		n.nodeNames = make(map[backend.Endpoint]string)
+	}
+	n.nodeNames[endpoint] = name
+	return name
+}
+
+// lookupPeer finds peer by endpoint sending from.
+func (n *MetadataNetwork) lookupPeer(endpoint backend.Endpoint) (peer *MetaPeer) {
+	name := n.nodeName(endpoint)
+	if peer, _ = n.Publish.Name2Peer[name]; peer == nil {
+		peer = n.punchedPeer(endpoint)
+	}
+	return
 }
 
 // Peer reports the message sender.
@@ -35,24 +92,19 @@ func (m *Message) Peer() (peer *MetaPeer) {
 	if peer = m.peer; peer != nil {
 		return peer
 	}
-	name := gossipUtils.BuildNodeName(m.Endpoint)
-	name2Peer := m.n.Publish.Name2Peer
-	peer, _ = name2Peer[name]
+	peer = m.n.lookupPeer(m.Endpoint)
 	m.peer = peer
 	return
 }
 
+var messagePool = sync.Pool{
+	New: func() interface{} { return &Message{} },
+}
+
 func (n *MetadataNetwork) receiveRemote(b backend.Backend, packed []byte, src string) {
 	typeID, payload := proto.UnpackProtocolMessageHeader(packed)
-	rh, hasHandler := n.messageHandlers.Load(typeID)
-	if !hasHandler || rh == nil {
-		return
-	}
-	handler, isHandler := rh.(MessageHandler)
-	if !isHandler || handler == nil {
-		return
-	}
-	msg := Message{
+	msg := messagePool.Get().(*Message)
+	*msg = Message{
 		n:       n,
 		Packed:  packed,
 		Payload: payload,
@@ -66,7 +118,21 @@ func (n *MetadataNetwork) receiveRemote(b backend.Backend, packed []byte, src st
 			Endpoint: b.Publish(),
 		},
 	}
-	handler(&msg)
+	n.dispatchMessage(msg)
+	*msg = Message{}
+	messagePool.Put(msg)
+}
+
+func (n *MetadataNetwork) dispatchMessage(msg *Message) {
+	rh, hasHandler := n.messageHandlers.Load(msg.TypeID)
+	if !hasHandler || rh == nil {
+		return
+	}
+	handler, isHandler := rh.(MessageHandler)
+	if !isHandler || handler == nil {
+		return
+	}
+	handler(msg)
 }
 
 // RegisterMessageHandler registers message handler for specific message type.
@@ -88,24 +154,45 @@ func (n *MetadataNetwork) RegisterMessageHandler(typeID uint16, handler MessageH
 	return rv.(MessageHandler)
 }
 
+// packMessage packs message into pooled frame.
+func packMessage(typeID uint16, payload []byte) *mux.FrameBuffer {
+	frame := mux.NewFrameBuffer(proto.ProtocolMessageHeaderSize + len(payload))
+	packed := frame.Bytes()
+	proto.PackProtocolMessageHeader(packed[:proto.ProtocolMessageHeaderSize], typeID)
+	copy(packed[proto.ProtocolMessageHeaderSize:], payload)
+	return frame
+}
+
 // SendToPeers sends a message to peers.
 func (n *MetadataNetwork) SendToPeers(typeID uint16, payload []byte, peers ...*MetaPeer) {
 	if len(peers) < 1 {
 		return
 	}
+	frame := packMessage(typeID, payload)
+	n.sendFrameToPeers(frame, peers)
+	frame.Release()
+}
 
-	packed := make([]byte, proto.ProtocolMessageHeaderSize, len(payload)+proto.ProtocolMessageHeaderSize)
+// SendFrameToPeers sends a message carrying frame as payload to peers.
+// Message header is prepended to frame in place, so caller should hold the only reference,
+// which is taken over and released once sent.
+func (n *MetadataNetwork) SendFrameToPeers(typeID uint16, frame *mux.FrameBuffer, peers ...*MetaPeer) {
+	packed := frame.Prepend(proto.ProtocolMessageHeaderSize)
 	proto.PackProtocolMessageHeader(packed[:proto.ProtocolMessageHeaderSize], typeID)
-	packed = append(packed, payload...)
+	n.sendFrameToPeers(frame, peers)
+	frame.Release()
+}
 
+func (n *MetadataNetwork) sendFrameToPeers(frame *mux.FrameBuffer, peers []*MetaPeer) {
 	// TODO(xutao): deliver directly if a message is sent to self.
 
 	for _, peer := range peers {
 		path := peer.chooseLinkPath(n.Publish.Epoch, n.Publish.Backends)
 		if path == nil {
+			n.sendViaRelay(frame.Bytes(), peer)
 			continue
 		}
-		if err := n.nakedSendViaBackend(packed, path.Backend, path.remote); err != nil {
+		if err := n.nakedSendFrameViaBackend(frame, path.Backend, path.remote); err != nil {
 			n.lastFails.Store(linkPathKey{
 				remote: path.remote, local: path.local, ty: path.Backend.Type(),
 			}, peer)
@@ -156,22 +243,41 @@ func (n *MetadataNetwork) SendViaEndpoint(typeID uint16, payload []byte, via bac
 		return
 	}
 
-	packed := make([]byte, proto.ProtocolMessageHeaderSize, len(payload)+proto.ProtocolMessageHeaderSize)
-	proto.PackProtocolMessageHeader(packed[:proto.ProtocolMessageHeaderSize], typeID)
-	packed = append(packed, payload...)
-
-	n.nakedSendViaBackend(packed, backend, to)
+	frame := packMessage(typeID, payload)
+	n.nakedSendFrameViaBackend(frame, backend, to)
+	frame.Release()
 }
 
 // SendViaBackend sends a message via given backend.
 func (n *MetadataNetwork) SendViaBackend(typeID uint16, payload []byte, via backend.Backend, to string) {
-	packed := make([]byte, proto.ProtocolMessageHeaderSize, len(payload)+proto.ProtocolMessageHeaderSize)
-	proto.PackProtocolMessageHeader(packed[:proto.ProtocolMessageHeaderSize], typeID)
-	packed = append(packed, payload...)
-	n.nakedSendViaBackend(packed, via, to)
+	frame := packMessage(typeID, payload)
+	n.nakedSendFrameViaBackend(frame, via, to)
+	frame.Release()
 }
 
 func (n *MetadataNetwork) nakedSendViaBackend(packed []byte, b backend.Backend, to string) error {
+	link, err := n.connectLink(b, to)
+	if link == nil {
+		return err
+	}
+	return n.checkSendError(link.Send(packed))
+}
+
+// nakedSendFrameViaBackend sends packed message in frame. Frame is shared with link if possible.
+func (n *MetadataNetwork) nakedSendFrameViaBackend(frame *mux.FrameBuffer, b backend.Backend, to string) error {
+	link, err := n.connectLink(b, to)
+	if link == nil {
+		return err
+	}
+	if sender, ok := link.(backend.FrameSender); ok {
+		err = sender.SendFrame(frame)
+	} else {
+		err = link.Send(frame.Bytes())
+	}
+	return n.checkSendError(err)
+}
+
+func (n *MetadataNetwork) connectLink(b backend.Backend, to string) (backend.Link, error) {
 	// TODO(xutao): implement reliable sending.
 	link, err := b.Connect(to)
 	if err != nil {
@@ -180,14 +286,17 @@ func (n *MetadataNetwork) nakedSendViaBackend(packed []byte, b backend.Backend,
 		} else {
 			err = nil
 		}
-		return err
+		return nil, err
 	}
-	if err = link.Send(packed); err != nil {
-		if err != backend.ErrOperationCanceled {
-			n.log.Errorf("failed to send packet. (err = \"%v\")", err)
-		} else {
-			err = nil
-		}
+	return link, nil
+}
+
+func (n *MetadataNetwork) checkSendError(err error) error {
+	if err == backend.ErrOperationCanceled {
+		return nil
+	}
+	if err != nil {
+		n.log.Errorf("failed to send packet. (err = \"%v\")", err)
 	}
 	return err
 }
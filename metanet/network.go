@@ -1,6 +1,8 @@
 package metanet
 
 import (
+	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -114,6 +116,29 @@ type MetadataNetwork struct {
 	probeCounter    uint64
 	probes          map[linkPathKey]*endpointProbingContext
 	recentSuccesses map[linkPathKey]*lastProbingContext
+
+	// reflexive endpoint discovery fields.
+	reflectLock       sync.Mutex
+	candidates        map[backend.Endpoint]*reflexiveCandidate
+	verifies          map[reflexiveVerifyKey]*reflexiveVerifyContext
+	acceptReflexiveIP func(net.IP) bool
+
+	// hole punching fields.
+	punchLock     sync.Mutex
+	punchCounter  uint64
+	punchSessions map[uint64]*holePunchSession
+	punchRelays   map[uint64]*holePunchRelay
+	punched       sync.Map // map[backend.Endpoint]*MetaPeer
+
+	// node names of endpoints messages are received from.
+	nameLock  sync.RWMutex
+	nodeNames map[backend.Endpoint]string
+
+	// relay fields.
+	relayLock    sync.Mutex
+	relayCounter uint64
+	relayProbes  map[uint64]*relayProbingContext
+	relayRoutes  map[*MetaPeer]*relayRoute
 }
 
 // NewMetadataNetwork creates a metadata network.
@@ -128,6 +153,18 @@ func NewMetadataNetwork(arbiter *arbit.Arbiter, log *logging.Entry) (n *Metadata
 		ProbeTimeout:    defaultHealthyCheckProbeTimeout,
 		probes:          make(map[linkPathKey]*endpointProbingContext),
 		recentSuccesses: make(map[linkPathKey]*lastProbingContext),
+
+		candidates:        make(map[backend.Endpoint]*reflexiveCandidate),
+		verifies:          make(map[reflexiveVerifyKey]*reflexiveVerifyContext),
+		acceptReflexiveIP: defaultAcceptReflexiveIP,
+
+		punchSessions: make(map[uint64]*holePunchSession),
+		punchRelays:   make(map[uint64]*holePunchRelay),
+
+		relayProbes: make(map[uint64]*relayProbingContext),
+		relayRoutes: make(map[*MetaPeer]*relayRoute),
+
+		nodeNames: make(map[backend.Endpoint]string),
 	}
 
 	n.arbiters.main = arbit.NewWithParent(arbiter)
@@ -153,6 +190,9 @@ func NewMetadataNetwork(arbiter *arbit.Arbiter, log *logging.Entry) (n *Metadata
 	})
 
 	n.initializeEndpointHealthCheck()
+	n.initializeReflexiveDiscovery()
+	n.initializeHolePunch()
+	n.initializeRelay()
 
 	return n, nil
 }
@@ -262,6 +302,143 @@ func (n *MetadataNetwork) Close() {
 	n.lock.Unlock()
 }
 
+// Links reports live links of local backends.
+func (n *MetadataNetwork) Links() map[backend.Endpoint][]backend.LinkInfo {
+	links := make(map[backend.Endpoint][]backend.LinkInfo)
+	for endpoint, b := range n.publishedBackends() {
+		if lister, ok := b.(backend.LinkLister); ok {
+			links[endpoint] = lister.Links()
+		}
+	}
+	return links
+}
+
+// PeerStatistics summarizes statistics of links to a peer.
+type PeerStatistics struct {
+	Name  string
+	Links int // live links.
+
+	backend.LinkStats
+}
+
+// PeerStatistics reports statistics of links by peer. Links not connected are counted in, so that
+// failed handshakes are reported.
+func (n *MetadataNetwork) PeerStatistics() (stats []PeerStatistics) {
+	byPeer := make(map[*MetaPeer]*PeerStatistics)
+	statOf := func(ty backend.Type, publish string) *PeerStatistics {
+		peer := n.lookupPeer(backend.Endpoint{Type: ty, Endpoint: publish})
+		if peer == nil {
+			return nil
+		}
+		stat, _ := byPeer[peer]
+		if stat == nil {
+			names := peer.Names()
+			if len(names) < 1 {
+				return nil
+			}
+			stat = &PeerStatistics{Name: names[0]}
+			byPeer[peer] = stat
+		}
+		return stat
+	}
+	for endpoint, b := range n.publishedBackends() {
+		lister, ok := b.(backend.LinkLister)
+		if !ok {
+			continue
+		}
+		for publish, linkStats := range lister.LinkStats() {
+			if stat := statOf(endpoint.Type, publish); stat != nil {
+				stat.Add(&linkStats)
+			}
+		}
+		for _, link := range lister.Links() {
+			if stat := statOf(endpoint.Type, link.Publish); stat != nil {
+				stat.Links++
+			}
+		}
+	}
+	for _, stat := range byPeer {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return
+}
+
+// publishedBackends returns published local backends.
+// The map is replaced rather than modified, so it can be iterated once loaded.
+func (n *MetadataNetwork) publishedBackends() map[backend.Endpoint]backend.Backend {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.Publish.Backends
+}
+
+// Bans reports sources banned by local backends.
+func (n *MetadataNetwork) Bans() map[backend.Endpoint][]backend.BanInfo {
+	bans := make(map[backend.Endpoint][]backend.BanInfo)
+	for endpoint, b := range n.publishedBackends() {
+		if lister, ok := b.(backend.BanLister); ok {
+			bans[endpoint] = lister.Bans()
+		}
+	}
+	return bans
+}
+
+// PathInfo describes a link path to peer.
+type PathInfo struct {
+	Local, Remote backend.Endpoint
+	Cost          uint32
+	Disabled      bool
+}
+
+// PeerPathInfo describes how a peer is reached.
+type PeerPathInfo struct {
+	Name  string
+	Paths []PathInfo
+
+	// relaying peer, if no direct path is healthy.
+	Relay    string
+	RelayRTT time.Duration
+}
+
+// Paths reports link paths to peers.
+func (n *MetadataNetwork) Paths() (infos []PeerPathInfo) {
+	n.lock.RLock()
+	peers := make([]*MetaPeer, 0, len(n.peers))
+	for _, peer := range n.peers {
+		if !peer.IsSelf() && !peer.left {
+			peers = append(peers, peer)
+		}
+	}
+	epoch, backends := n.Publish.Epoch, n.Publish.Backends
+	n.lock.RUnlock()
+
+	for _, peer := range peers {
+		names := peer.Names()
+		if len(names) < 1 {
+			continue
+		}
+		info := PeerPathInfo{Name: names[0]}
+		for _, path := range peer.getLinkPaths(epoch, backends) {
+			info.Paths = append(info.Paths, PathInfo{
+				Local:    backend.Endpoint{Type: path.ty, Endpoint: path.local},
+				Remote:   backend.Endpoint{Type: path.ty, Endpoint: path.remote},
+				Cost:     path.cost,
+				Disabled: path.Disabled,
+			})
+		}
+		if peer.chooseLinkPath(epoch, backends) == nil {
+			if route := n.getRelayRoute(peer); route != nil {
+				if relayNames := route.relay.Names(); len(relayNames) > 0 {
+					info.Relay, info.RelayRTT = relayNames[0], route.rtt
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return
+}
+
 func (n *MetadataNetwork) delayLocalEndpointCreation(epoch uint32, creators ...backend.BackendCreator) {
 	n.arbiters.main.Go(func() {
 		select {
@@ -287,7 +464,7 @@ func (n *MetadataNetwork) delayLocalEndpointCreation(epoch uint32, creators ...b
 			// new.
 			new, err := creator.New(n.arbiters.backend, nil)
 			if err != nil {
-				n.log.Errorf("failed to create backend %v:%v. (err = \"%v\")", creator.Type().String(), creator.Publish, err)
+				n.log.Errorf("failed to create backend %v:%v. (err = \"%v\")", creator.Type().String(), creator.Publish(), err)
 				failCreation = append(failCreation, creator)
 			} else {
 				n.backends[endpoint] = new
@@ -346,8 +523,21 @@ func (n *MetadataNetwork) UpdateLocalEndpoints(creators ...backend.BackendCreato
 	for endpoint, creator := range indexCreators {
 		rb, hasBackend := n.backends[endpoint]
 		b, isBackend := rb.(backend.Backend)
-		// TODO(xutao): backend support configuration change.
 		if hasBackend && isBackend && b != nil { // update
+			if reloadable, canReload := b.(backend.Reloadable); canReload {
+				priority := b.Priority()
+				if restarted, reloaded := reloadable.Reload(creator); reloaded {
+					if len(restarted) > 0 {
+						n.log.Infof("endpoint %v reloaded. restarted links: %v", endpoint, restarted)
+					} else {
+						n.log.Infof("endpoint %v reloaded.", endpoint)
+					}
+					if b.Priority() != priority {
+						changed = true // republish.
+					}
+					continue
+				}
+			}
 			delete(n.backends, endpoint)
 			n.log.Infof("try to restart endpoint %v.", endpoint)
 			b.Shutdown()
@@ -357,7 +547,7 @@ func (n *MetadataNetwork) UpdateLocalEndpoints(creators ...backend.BackendCreato
 		// new.
 		new, err := creator.New(n.arbiters.backend, nil)
 		if err != nil {
-			n.log.Errorf("failed to create backend %v:%v. (err = \"%v\")", creator.Type().String(), creator.Publish, err)
+			n.log.Errorf("failed to create backend %v:%v. (err = \"%v\")", creator.Type().String(), creator.Publish(), err)
 			failCreation = append(failCreation, creator)
 		} else {
 			changed = true
@@ -0,0 +1,664 @@
+package metanet
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/crossmesh/fabric/backend"
+	"github.com/crossmesh/fabric/config"
+	"github.com/crossmesh/fabric/mux"
+	"github.com/crossmesh/fabric/proto"
+	"github.com/crossmesh/fabric/route"
+	"github.com/stretchr/testify/assert"
+	arbit "github.com/sunmxt/arbiter"
+)
+
+func init() {
+	// gossip and probe frequently, so that tests need not wait for production intervals.
+	defaultGossipPeriod, defaultGossipQuitTimeout = time.Millisecond*500, time.Second*2
+	defaultHealthyCheckProbeInterval = time.Millisecond * 200
+	defaultHealthyCheckProbeTimeout = time.Millisecond * 500
+}
+
+func newTestMemMetadataNetwork(t testing.TB, arbiter *arbit.Arbiter, network, publish string) *MetadataNetwork {
+	n, err := NewMetadataNetwork(arbiter, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, err := backend.GetCreator("mem", &config.Backend{
+		Type: "mem",
+		Parameters: map[string]interface{}{
+			"network": network,
+			"publish": publish,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.UpdateLocalEndpoints(creator)
+	return n
+}
+
+// publishedPeers loads peers by name under lock. The map is replaced rather than modified.
+func (n *MetadataNetwork) publishedPeers() map[string]*MetaPeer {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.Publish.Name2Peer
+}
+
+// pathDisabled reports whether direct paths to peer are all disabled. It reports false if peer has no path.
+func (n *MetadataNetwork) pathDisabled(name string) bool {
+	for _, info := range n.Paths() {
+		if info.Name != name || len(info.Paths) < 1 {
+			continue
+		}
+		for _, path := range info.Paths {
+			if !path.Disabled {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func waitForCondition(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return cond()
+}
+
+func TestMetadataNetworkOverMemBackend(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip end-to-end test in short mode.")
+	}
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	memNetName := t.Name()
+
+	var nets []*MetadataNetwork
+	for i := 0; i < 3; i++ {
+		nets = append(nets, newTestMemMetadataNetwork(t, arbiter, memNetName, "n"+strconv.FormatInt(int64(i), 10)))
+	}
+	for _, n := range nets[1:] {
+		assert.NoError(t, n.SeedEndpoints(backend.Endpoint{Type: backend.MemBackend, Endpoint: "n0"}))
+	}
+
+	t.Run("gossip_convergence", func(t *testing.T) {
+		assert.True(t, waitForCondition(time.Second*10, func() bool {
+			for _, n := range nets {
+				name2Peer := n.publishedPeers()
+				for i := range nets {
+					if _, hasPeer := name2Peer["mem:n"+strconv.FormatInt(int64(i), 10)]; !hasPeer {
+						return false
+					}
+				}
+			}
+			return true
+		}), "gossip not converged.")
+	})
+
+	t.Run("message_delivery", func(t *testing.T) {
+		const msgType = uint16(0xFF00)
+
+		received := make(chan string, 1)
+		nets[2].RegisterMessageHandler(msgType, func(msg *Message) {
+			received <- msg.GetPeerName() + "/" + string(msg.Payload)
+		})
+		nets[1].SendToNames(msgType, []byte("hello"), "mem:n2")
+		select {
+		case msg := <-received:
+			assert.Equal(t, "mem:n1/hello", msg)
+		case <-time.After(time.Second * 5):
+			t.Fatal("message not delivered.")
+		}
+
+		backend.GetMemNetwork(memNetName).Partition([]string{"n1"}, []string{"n2"})
+		defer backend.GetMemNetwork(memNetName).Heal()
+		nets[1].SendToNames(msgType, []byte("hello"), "mem:n2")
+		select {
+		case <-received:
+			t.Fatal("message should be blocked by partition.")
+		case <-time.After(time.Millisecond * 500):
+		}
+
+		// partitioned paths are found unhealthy by probing, and recover once healed.
+		// features of peers may be missed in test, so health probing is enabled by hand.
+		for _, n := range nets {
+			n.lock.Lock()
+			for _, peer := range n.peers {
+				peer.healthProbe = true
+			}
+			n.lock.Unlock()
+		}
+		assert.True(t, waitForCondition(time.Second*10, func() bool {
+			return nets[1].pathDisabled("mem:n2") && nets[2].pathDisabled("mem:n1")
+		}), "partitioned path not disabled.")
+		backend.GetMemNetwork(memNetName).Heal()
+		assert.True(t, waitForCondition(time.Second*10, func() bool {
+			return !nets[1].pathDisabled("mem:n2") && !nets[2].pathDisabled("mem:n1")
+		}), "healed path not enabled.")
+		for len(received) > 0 { // relayed while partitioned.
+			<-received
+		}
+		nets[1].SendToNames(msgType, []byte("hello"), "mem:n2")
+		select {
+		case msg := <-received:
+			assert.Equal(t, "mem:n1/hello", msg)
+		case <-time.After(time.Second * 5):
+			t.Fatal("message not delivered after healed.")
+		}
+	})
+	t.Run("routed_frame", func(t *testing.T) {
+		// frames are routed by ethernet routers as edge routers do.
+		routers := make([]*route.P2PL2MeshNetworkRouter, len(nets))
+		received := make([]chan []byte, len(nets))
+		for i, n := range nets {
+			router, frames := route.NewP2PL2MeshNetworkRouter(), make(chan []byte, 4)
+			for _, peer := range n.publishedPeers() {
+				router.PeerJoin(peer)
+			}
+			n.RegisterMessageHandler(proto.MsgTypeRawFrame, func(msg *Message) {
+				for _, peer := range router.Route(msg.Payload, msg.Peer()) {
+					if peer.IsSelf() {
+						frames <- append([]byte(nil), msg.Payload...)
+					}
+				}
+			})
+			routers[i], received[i] = router, frames
+		}
+		send := func(i int, frame []byte) {
+			var peers []*MetaPeer
+			for _, peer := range routers[i].Route(frame, nets[i].Publish.Self) {
+				if !peer.IsSelf() {
+					peers = append(peers, peer.(*MetaPeer))
+				}
+			}
+			nets[i].SendToPeers(proto.MsgTypeRawFrame, frame, peers...)
+		}
+		mac := func(i int) []byte { return []byte{0x02, 0, 0, 0, 0, byte(i)} }
+		newFrame := func(dst, src []byte) (frame []byte) {
+			frame = append(append(frame, dst...), src...)
+			return append(frame, make([]byte, 48)...)
+		}
+		expect := func(i int, frame []byte) {
+			select {
+			case got := <-received[i]:
+				assert.Equal(t, frame, got)
+			case <-time.After(time.Second * 5):
+				t.Fatalf("frame not delivered to n%v.", i)
+			}
+		}
+
+		// broadcast is flooded, and source address is learned.
+		broadcast := newFrame(route.EthernetBoardcastAddress[:], mac(2))
+		send(2, broadcast)
+		expect(0, broadcast)
+		expect(1, broadcast)
+
+		unicast := newFrame(mac(2), mac(1))
+		send(1, unicast)
+		expect(2, unicast)
+		select {
+		case <-received[0]:
+			t.Fatal("unicast frame should be routed to n2 only.")
+		case <-time.After(time.Millisecond * 500):
+		}
+	})
+}
+
+// BenchmarkMessageForwarding measures full-size frames sent to peer as edge router forwards them,
+// and received through the path handlers are called from. Steady-state forwarding should report
+// no allocation over in-memory links, which take pooled frames as they are. TCP and UDP links copy
+// frames while sending, see benchmarks of backend.
+func BenchmarkMessageForwarding(b *testing.B) {
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	memNetName := b.Name()
+	from := newTestMemMetadataNetwork(b, arbiter, memNetName, "n0")
+	to := newTestMemMetadataNetwork(b, arbiter, memNetName, "n1")
+	if err := to.SeedEndpoints(backend.Endpoint{Type: backend.MemBackend, Endpoint: "n0"}); err != nil {
+		b.Fatal(err)
+	}
+	var peer *MetaPeer
+	if !waitForCondition(time.Second*10, func() bool {
+		from.lock.RLock()
+		epoch, backends := from.Publish.Epoch, from.Publish.Backends
+		from.lock.RUnlock()
+		peer = from.publishedPeers()["mem:n1"]
+		return peer != nil && peer.chooseLinkPath(epoch, backends) != nil
+	}) {
+		b.Fatal("gossip not converged.")
+	}
+
+	const msgType = uint16(0xFF00)
+	received := make(chan struct{}, 1)
+	to.RegisterMessageHandler(msgType, func(msg *Message) { received <- struct{}{} })
+
+	payload := make([]byte, 1400)
+	forward := func() {
+		frame := mux.NewFrameBuffer(len(payload))
+		copy(frame.Bytes(), payload)
+		from.SendFrameToPeers(msgType, frame, peer)
+		<-received
+	}
+	forward() // warm up.
+
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		forward()
+	}
+}
+
+func newTestTCPCreator(t *testing.T, bind string) backend.BackendCreator {
+	creator, err := backend.GetCreator("tcp", &config.Backend{
+		PSK: "12345", Type: "tcp",
+		Parameters: map[string]interface{}{
+			"bind": bind,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creator
+}
+
+func TestMetadataNetworkDualStack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip end-to-end test in short mode.")
+	}
+	if l, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skip("IPv6 loopback unavailable.")
+	} else {
+		l.Close()
+	}
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	var nets []*MetadataNetwork
+	for _, port := range []string{"39990", "39991"} {
+		n, err := NewMetadataNetwork(arbiter, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// one backend per address family.
+		n.UpdateLocalEndpoints(newTestTCPCreator(t, "127.0.0.1:"+port), newTestTCPCreator(t, "[::1]:"+port))
+		nets = append(nets, n)
+	}
+	// seed by IPv6 only.
+	seed, err := backend.ParseEndpoint("tcp:[0:0::1]:39990")
+	assert.NoError(t, err)
+	assert.Equal(t, "[::1]:39990", seed.Endpoint)
+	assert.NoError(t, nets[1].SeedEndpoints(seed))
+
+	names := []string{"tcp:127.0.0.1:39990", "tcp:[::1]:39990", "tcp:127.0.0.1:39991", "tcp:[::1]:39991"}
+	assert.True(t, waitForCondition(time.Second*10, func() bool {
+		for _, n := range nets {
+			name2Peer := n.publishedPeers()
+			for _, name := range names {
+				if _, hasPeer := name2Peer[name]; !hasPeer {
+					return false
+				}
+			}
+			// both families resolve to the same peer.
+			if name2Peer[names[0]] != name2Peer[names[1]] || name2Peer[names[2]] != name2Peer[names[3]] {
+				return false
+			}
+		}
+		return true
+	}), "gossip not converged.")
+
+	const msgType = uint16(0xFF00)
+	received := make(chan string, 1)
+	nets[0].RegisterMessageHandler(msgType, func(msg *Message) {
+		received <- string(msg.Payload)
+	})
+	nets[1].SendToNames(msgType, []byte("hello"), names[1])
+	select {
+	case msg := <-received:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(time.Second * 5):
+		t.Fatal("message not delivered.")
+	}
+}
+
+func TestMetadataNetworkReflexiveEndpoint(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip end-to-end test in short mode.")
+	}
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	newNet := func(bind, publish string) *MetadataNetwork {
+		n, err := NewMetadataNetwork(arbiter, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.acceptReflexiveIP = func(net.IP) bool { return true } // loopback.
+		creator, err := backend.GetCreator("tcp", &config.Backend{
+			PSK: "12345", Type: "tcp",
+			Parameters: map[string]interface{}{
+				"bind":    bind,
+				"publish": publish,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.UpdateLocalEndpoints(creator)
+		return n
+	}
+	// nobody listens at published address of natted, as if it were behind NAT.
+	natted, public := newNet("127.0.0.1:39870", "127.0.0.2:39870"), newNet("127.0.0.1:39871", "")
+	assert.NoError(t, natted.SeedEndpoints(backend.Endpoint{Type: backend.TCPBackend, Endpoint: "127.0.0.1:39871"}))
+	assert.True(t, waitForCondition(time.Second*10, func() bool {
+		_, hasPeer := public.publishedPeers()["tcp:127.0.0.2:39870"]
+		return hasPeer
+	}), "gossip not converged.")
+
+	// build version is missing in test, so health probing is driven by hand.
+	local := backend.Endpoint{Type: backend.TCPBackend, Endpoint: "127.0.0.2:39870"}
+	probe := endpointProbeHeader{id: 1}
+	natted.SendViaEndpoint(proto.MsgTypePing, probe.Encode(), local, "127.0.0.1:39871")
+
+	assert.True(t, waitForCondition(time.Second*10, func() bool {
+		name2Peer := public.publishedPeers()
+		peer, _ := name2Peer["tcp:127.0.0.2:39870"]
+		return peer != nil && name2Peer["tcp:127.0.0.1:39870"] == peer
+	}), "reflexive endpoint not advertised.")
+
+	// unverified observation never gets advertised.
+	natted.reflectLock.Lock()
+	natted.candidates[backend.Endpoint{Type: backend.TCPBackend, Endpoint: "127.0.0.3:39870"}] = &reflexiveCandidate{
+		local:    local,
+		endpoint: "127.0.0.3:39870",
+		seen:     time.Now(),
+	}
+	natted.reflectLock.Unlock()
+	assert.Equal(t, []string{"127.0.0.1:39870"}, natted.verifiedReflexiveCandidates(local))
+}
+
+func TestEndpointReflectionCodec(t *testing.T) {
+	req := endpointReflection{candidates: []string{"1.2.3.4:3880", "[2001:db8::1]:3880"}}
+	decoded := endpointReflection{}
+	assert.NoError(t, decoded.Decode(req.Encode(false), false))
+	assert.Equal(t, req, decoded)
+
+	resp := endpointReflection{observed: "1.2.3.4:51234", candidates: []string{"1.2.3.4:3880"}}
+	decoded = endpointReflection{}
+	bins := resp.Encode(true)
+	assert.NoError(t, decoded.Decode(bins, true))
+	assert.Equal(t, resp, decoded)
+	assert.Error(t, decoded.Decode(bins[:len(bins)-1], true))
+	assert.Error(t, decoded.Decode([]byte{0x02}, true))
+}
+
+func TestMetadataNetworkHolePunch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip end-to-end test in short mode.")
+	}
+
+	for _, c := range []struct {
+		ty         backend.Type
+		ports      [3]string
+		parameters map[string]interface{}
+	}{
+		{ty: backend.UDPBackend, ports: [3]string{"39872", "39873", "39874"}},
+		{ty: backend.TCPBackend, ports: [3]string{"39875", "39876", "39877"}, parameters: map[string]interface{}{"holePunch": true}},
+	} {
+		t.Run(c.ty.String(), func(t *testing.T) {
+			arbiter := arbit.New()
+			defer func() {
+				arbiter.Shutdown()
+				arbiter.Join()
+			}()
+
+			newNet := func(bind, publish string) *MetadataNetwork {
+				n, err := NewMetadataNetwork(arbiter, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parameters := map[string]interface{}{"bind": bind, "publish": publish}
+				for k, v := range c.parameters {
+					parameters[k] = v
+				}
+				creator, err := backend.GetCreator(c.ty.String(), &config.Backend{PSK: "12345", Type: c.ty.String(), Parameters: parameters})
+				if err != nil {
+					t.Fatal(err)
+				}
+				n.UpdateLocalEndpoints(creator)
+				return n
+			}
+			// nobody listens at published addresses of a and b, as if they were behind NAT.
+			a := newNet("127.0.0.1:"+c.ports[0], "127.0.0.2:"+c.ports[0])
+			b := newNet("127.0.0.1:"+c.ports[1], "127.0.0.2:"+c.ports[1])
+			newNet("127.0.0.1:"+c.ports[2], "127.0.0.1:"+c.ports[2]) // relay.
+			for _, n := range []*MetadataNetwork{a, b} {
+				assert.NoError(t, n.SeedEndpoints(backend.Endpoint{Type: c.ty, Endpoint: "127.0.0.1:" + c.ports[2]}))
+			}
+			nameA, nameB := c.ty.String()+":127.0.0.2:"+c.ports[0], c.ty.String()+":127.0.0.2:"+c.ports[1]
+			assert.True(t, waitForCondition(time.Second*10, func() bool {
+				_, hasA := b.publishedPeers()[nameA]
+				_, hasB := a.publishedPeers()[nameB]
+				return hasA && hasB
+			}), "gossip not converged.")
+
+			// build version is missing in test, so unhealthy paths are disabled by hand.
+			for _, n := range []*MetadataNetwork{a, b} {
+				for _, name := range []string{nameA, nameB} {
+					peer := n.publishedPeers()[name]
+					if peer.IsSelf() {
+						continue
+					}
+					peer.getLinkPaths(n.Publish.Epoch, n.Publish.Backends)
+					peer.filterLinkPath(func(paths []*linkPath) []*linkPath {
+						for _, path := range paths {
+							path.Disabled = true
+						}
+						return paths
+					})
+					assert.Nil(t, peer.chooseLinkPath(n.Publish.Epoch, n.Publish.Backends))
+				}
+			}
+			peer := a.publishedPeers()[nameB]
+
+			const msgType = uint16(0xFF00)
+			received := make(chan string, 4)
+			for _, n := range []*MetadataNetwork{a, b} {
+				n.RegisterMessageHandler(msgType, func(msg *Message) {
+					received <- msg.GetPeerName() + "/" + string(msg.Payload)
+				})
+			}
+
+			assert.True(t, waitForCondition(time.Second*10, func() bool {
+				if peer.chooseLinkPath(a.Publish.Epoch, a.Publish.Backends) == nil {
+					a.holePunch(peer)
+					return false
+				}
+				return true
+			}), "hole not punched.")
+			path := peer.chooseLinkPath(a.Publish.Epoch, a.Publish.Backends)
+			assert.Equal(t, "127.0.0.1:"+c.ports[1], path.remote)
+
+			a.SendToNames(msgType, []byte("hello"), nameB)
+			select {
+			case msg := <-received:
+				assert.Equal(t, nameA+"/hello", msg)
+			case <-time.After(time.Second * 5):
+				t.Fatal("message not delivered.")
+			}
+			assert.True(t, waitForCondition(time.Second*5, func() bool {
+				peer := b.publishedPeers()[nameA]
+				path := peer.chooseLinkPath(b.Publish.Epoch, b.Publish.Backends)
+				return path != nil && path.remote == "127.0.0.1:"+c.ports[0]
+			}), "punched path not registered by b.")
+			b.SendToNames(msgType, []byte("hello"), nameA)
+			select {
+			case msg := <-received:
+				assert.Equal(t, nameB+"/hello", msg)
+			case <-time.After(time.Second * 5):
+				t.Fatal("message not delivered.")
+			}
+
+			// links punched are counted for peer.
+			var stat *PeerStatistics
+			stats := a.PeerStatistics()
+			for idx := range stats {
+				if stats[idx].Name == nameB {
+					stat = &stats[idx]
+				}
+			}
+			if assert.NotNil(t, stat) {
+				assert.True(t, stat.Links > 0)
+				assert.True(t, stat.FramesSent > 0)
+				assert.True(t, stat.FramesReceived > 0)
+			}
+		})
+	}
+}
+
+func TestHolePunchMessageCodec(t *testing.T) {
+	m := holePunchMessage{kind: holePunchOffer, session: 12, port: 3880, peer: "1.2.3.4:3880", endpoint: "5.6.7.8:51234"}
+	decoded := holePunchMessage{}
+	bins := m.Encode()
+	assert.NoError(t, decoded.Decode(bins))
+	assert.Equal(t, m, decoded)
+	assert.Error(t, decoded.Decode(bins[:len(bins)-1]))
+	assert.Error(t, decoded.Decode([]byte{0x02}))
+
+	assert.Equal(t, "1.2.3.4:51234", punchCandidate("1.2.3.4:51234", 0))
+	assert.Equal(t, "1.2.3.4:3880", punchCandidate("1.2.3.4:51234", 3880))
+	assert.Equal(t, "[2001:db8::1]:3880", punchCandidate("[2001:db8::1]:51234", 3880))
+	assert.Equal(t, "", punchCandidate("broken", 3880))
+}
+
+func TestMetadataNetworkRelay(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip end-to-end test in short mode.")
+	}
+
+	arbiter := arbit.New()
+	defer func() {
+		arbiter.Shutdown()
+		arbiter.Join()
+	}()
+
+	memNetName := t.Name()
+
+	var nets []*MetadataNetwork
+	for i := 0; i < 3; i++ {
+		nets = append(nets, newTestMemMetadataNetwork(t, arbiter, memNetName, "n"+strconv.FormatInt(int64(i), 10)))
+	}
+	for _, n := range nets[1:] {
+		assert.NoError(t, n.SeedEndpoints(backend.Endpoint{Type: backend.MemBackend, Endpoint: "n0"}))
+	}
+	assert.True(t, waitForCondition(time.Second*10, func() bool {
+		for _, n := range nets {
+			for i := range nets {
+				if _, hasPeer := n.publishedPeers()["mem:n"+strconv.FormatInt(int64(i), 10)]; !hasPeer {
+					return false
+				}
+			}
+		}
+		return true
+	}), "gossip not converged.")
+
+	backend.GetMemNetwork(memNetName).Partition([]string{"n1"}, []string{"n2"})
+	defer backend.GetMemNetwork(memNetName).Heal()
+
+	// build version is missing in test, so unhealthy paths are disabled by hand.
+	for _, c := range []struct {
+		n    *MetadataNetwork
+		name string
+	}{{nets[1], "mem:n2"}, {nets[2], "mem:n1"}} {
+		peer := c.n.publishedPeers()[c.name]
+		peer.getLinkPaths(c.n.Publish.Epoch, c.n.Publish.Backends)
+		peer.filterLinkPath(func(paths []*linkPath) []*linkPath {
+			for _, path := range paths {
+				path.Disabled = true
+			}
+			return paths
+		})
+	}
+
+	const msgType = uint16(0xFF00)
+	received := make(chan string, 1)
+	nets[2].RegisterMessageHandler(msgType, func(msg *Message) {
+		relay := ""
+		if msg.Relay != nil {
+			relay = msg.Relay.Names()[0]
+		}
+		received <- msg.GetPeerName() + "/" + relay + "/" + string(msg.Payload)
+	})
+
+	// no route before relay probed.
+	nets[1].SendToNames(msgType, []byte("hello"), "mem:n2")
+	select {
+	case <-received:
+		t.Fatal("message should be blocked by partition.")
+	case <-time.After(time.Millisecond * 500):
+	}
+
+	assert.True(t, waitForCondition(time.Second*10, func() bool {
+		nets[1].probeRelays(time.Now())
+		time.Sleep(time.Millisecond * 200)
+		return nets[1].getRelayRoute(nets[1].publishedPeers()["mem:n2"]) != nil
+	}), "relay not probed.")
+
+	nets[1].SendToNames(msgType, []byte("hello"), "mem:n2")
+	select {
+	case msg := <-received:
+		assert.Equal(t, "mem:n1/mem:n0/hello", msg)
+	case <-time.After(time.Second * 5):
+		t.Fatal("message not relayed.")
+	}
+
+	found := false
+	for _, info := range nets[1].Paths() {
+		if info.Name == "mem:n2" {
+			found = true
+			assert.Equal(t, "mem:n0", info.Relay)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestRelayHeaderCodec(t *testing.T) {
+	h := relayHeader{kind: relayProbeRequest, id: 7, source: "tcp:1.2.3.4:3880", destination: "udp:[2001:db8::1]:3880"}
+	bins := append(h.Encode(), 0xFF)
+	decoded := relayHeader{}
+	used, err := decoded.Decode(bins)
+	assert.NoError(t, err)
+	assert.Equal(t, len(bins)-1, used)
+	assert.Equal(t, h, decoded)
+	_, err = decoded.Decode(bins[:len(bins)-2])
+	assert.Error(t, err)
+	_, err = decoded.Decode([]byte{0x02})
+	assert.Error(t, err)
+}
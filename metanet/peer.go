@@ -90,6 +90,7 @@ type MetaPeer struct {
 
 	log *logging.Entry
 
+	hashID      string
 	isSelf      bool
 	left        bool
 	healthProbe bool
@@ -104,6 +105,7 @@ type MetaPeer struct {
 	localEpoch     uint32
 	linkPaths      []*linkPath // (COW)
 	localEndpoints map[backend.Endpoint]backend.Backend
+	punchedPaths   []linkPathKey // paths established by hole punching. (COW)
 }
 
 func newMetaPeer(n *sladder.Node, log *logging.Entry, isSelf bool) (p *MetaPeer) {
@@ -118,6 +120,7 @@ func newMetaPeer(n *sladder.Node, log *logging.Entry, isSelf bool) (p *MetaPeer)
 		// features.
 		healthProbe: isSelf,
 	}
+	p.hashID = p.buildHashID()
 	return p
 }
 
@@ -136,7 +139,9 @@ func (p *MetaPeer) SladderNode() *sladder.Node { return p.Node }
 
 // HashID returns unique id for hash.
 // This ID will nerver change within lifecycle of peer.
-func (p *MetaPeer) HashID() string {
+func (p *MetaPeer) HashID() string { return p.hashID }
+
+func (p *MetaPeer) buildHashID() string {
 	ptr := uintptr(unsafe.Pointer(p))
 
 	switch sz := unsafe.Sizeof(ptr); sz {
@@ -186,9 +191,9 @@ func (p *MetaPeer) _rebuildLinkPath() {
 		return
 	}
 
-	expectPathCount := len(p.localEndpoints) * len(p.Endpoints)
+	expectPathCount := len(p.localEndpoints)*len(p.Endpoints) + len(p.punchedPaths)
 	newLinkPaths := make([]*linkPath, 0, expectPathCount)
-	if expectPathCount < 1 {
+	if len(p.localEndpoints) < 1 || expectPathCount < 1 {
 		p.linkPaths = newLinkPaths
 		return
 	}
@@ -214,6 +219,9 @@ func (p *MetaPeer) _rebuildLinkPath() {
 			local := localEndpoints[begin]
 			backend := p.localEndpoints[local]
 			for _, remote := range p.Endpoints {
+				if remote.Type != local.Type {
+					continue
+				}
 				new := &linkPath{
 					linkPathKey: linkPathKey{
 						local: local.Endpoint, remote: remote.Endpoint.Endpoint,
@@ -232,6 +240,28 @@ func (p *MetaPeer) _rebuildLinkPath() {
 			begin++
 		}
 	}
+	for _, key := range p.punchedPaths {
+		backend, hasLocal := p.localEndpoints[backend.Endpoint{Type: key.ty, Endpoint: key.local}]
+		if !hasLocal {
+			continue
+		}
+		new := &linkPath{
+			linkPathKey: key,
+			Backend:     backend,
+		}
+		if old, _ := oldSet[key]; old != nil {
+			new.Disabled = old.Disabled
+		}
+		// punched path costs a little more than published endpoints of the same type.
+		remotePriority := uint32(0)
+		for _, remote := range p.Endpoints {
+			if remote.Type == key.ty && (remotePriority == 0 || remote.Priority < remotePriority) {
+				remotePriority = remote.Priority
+			}
+		}
+		new.cost = (backend.Priority()+1)*(remotePriority+1) + 1
+		newLinkPaths = append(newLinkPaths, new)
+	}
 	sort.Slice(newLinkPaths, func(i, j int) bool { return newLinkPaths[i].Higher(newLinkPaths[j]) })
 
 	p.linkPaths = newLinkPaths
@@ -267,6 +297,46 @@ func (p *MetaPeer) publishInterval(prepare func(interval *MetaPeerStatePublicati
 	}
 }
 
+// addPunchedPath registers path established by hole punching.
+func (p *MetaPeer) addPunchedPath(key linkPathKey) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, punched := range p.punchedPaths {
+		if punched == key {
+			return
+		}
+	}
+	punchedPaths := make([]linkPathKey, 0, len(p.punchedPaths)+1)
+	punchedPaths = append(punchedPaths, p.punchedPaths...)
+	p.punchedPaths = append(punchedPaths, key)
+	p._rebuildLinkPath()
+}
+
+// removePunchedPaths drops punched paths matched by filter. Removed paths are returned.
+func (p *MetaPeer) removePunchedPaths(filter func(*linkPath) bool) (removed []linkPathKey) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	paths := make(map[linkPathKey]*linkPath, len(p.linkPaths))
+	for _, path := range p.linkPaths {
+		paths[path.linkPathKey] = path
+	}
+	punchedPaths := make([]linkPathKey, 0, len(p.punchedPaths))
+	for _, key := range p.punchedPaths {
+		if path, _ := paths[key]; path != nil && filter(path) {
+			removed = append(removed, key)
+			continue
+		}
+		punchedPaths = append(punchedPaths, key)
+	}
+	if len(removed) > 0 {
+		p.punchedPaths = punchedPaths
+		p._rebuildLinkPath()
+	}
+	return
+}
+
 func (p *MetaPeer) getLinkPaths(epoch uint32, localEndpoints map[backend.Endpoint]backend.Backend) []*linkPath {
 	cacheEpoch, paths := p.localEpoch, p.linkPaths
 	if epoch > cacheEpoch && localEndpoints != nil {
@@ -0,0 +1,404 @@
+package metanet
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/crossmesh/fabric/backend"
+	gossipUtils "github.com/crossmesh/fabric/gossip"
+	"github.com/crossmesh/fabric/proto"
+)
+
+const (
+	defaultHolePunchInterval = time.Second * 20
+	defaultHolePunchTimeout  = time.Second * 30
+)
+
+const (
+	holePunchRequest = uint8(1) // requester --> relay.
+	holePunchOffer   = uint8(2) // relay --> target.
+	holePunchAnswer  = uint8(3) // target --> relay.
+	holePunchConfirm = uint8(4) // relay --> requester.
+)
+
+// holePunchMessage coordinates hole punching between two peers through a relay reachable by both.
+//
+// Requester asks relay to punch to target, with port it is going to punch from. Relay offers
+// endpoint it observes requester at to target, which answers with its port and starts punching.
+// Then relay confirms endpoint of target to requester, which starts punching too.
+type holePunchMessage struct {
+	kind     uint8
+	session  uint64
+	port     uint16
+	peer     string // requester in offer. target in request and confirmation.
+	endpoint string // endpoint to punch to.
+}
+
+func (m *holePunchMessage) Encode() (bins []byte) {
+	var buf [8]byte
+
+	bins = append(bins, 0x01, m.kind) // version, kind.
+	binary.BigEndian.PutUint64(buf[:], m.session)
+	bins = append(bins, buf[:]...)
+	binary.BigEndian.PutUint16(buf[:2], m.port)
+	bins = append(bins, buf[:2]...)
+	for _, s := range []string{m.peer, m.endpoint} {
+		binary.BigEndian.PutUint16(buf[:2], uint16(len(s)))
+		bins = append(bins, buf[:2]...)
+		bins = append(bins, s...)
+	}
+	return
+}
+
+func (m *holePunchMessage) Decode(bins []byte) error {
+	if len(bins) < 1 {
+		return ErrMessageStreamTooShort
+	}
+	if version := bins[0]; version != 0x01 {
+		return &MessageBrokenError{reason: "unknown hole punching version"}
+	}
+	if len(bins) < 12 {
+		return ErrMessageStreamTooShort
+	}
+	m.kind = bins[1]
+	m.session = binary.BigEndian.Uint64(bins[2:10])
+	m.port = binary.BigEndian.Uint16(bins[10:12])
+	bins = bins[12:]
+
+	peer, used, err := decodeReflectionString(bins)
+	if err != nil {
+		return err
+	}
+	bins = bins[used:]
+	endpoint, _, err := decodeReflectionString(bins)
+	if err != nil {
+		return err
+	}
+	m.peer, m.endpoint = peer, endpoint
+	return nil
+}
+
+// holePunchSession is hole punching requested by us.
+type holePunchSession struct {
+	peer   *MetaPeer
+	local  backend.Endpoint
+	target string
+	at     time.Time
+}
+
+// holePunchRelay is hole punching we coordinate for others.
+type holePunchRelay struct {
+	requester, target string
+	session           uint64
+	via               backend.Endpoint
+	observed          string // where target is observed at.
+	at                time.Time
+}
+
+// punchCandidate builds endpoint to punch to from address peer is observed at.
+// Zero port means that the observed port is kept.
+func punchCandidate(observed string, port uint16) string {
+	if port == 0 {
+		return observed
+	}
+	host, _, err := net.SplitHostPort(observed)
+	if err != nil {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+}
+
+func (n *MetadataNetwork) initializeHolePunch() {
+	n.RegisterMessageHandler(proto.MsgTypeHolePunch, n.onHolePunchMessage)
+	n.arbiters.main.TickGo(func(cancel func(), deadline time.Time) {
+		now := time.Now()
+		n.expireHolePunch(now)
+
+		n.lock.RLock()
+		peers := make([]*MetaPeer, 0, len(n.peers))
+		for _, peer := range n.peers {
+			if !peer.IsSelf() {
+				peers = append(peers, peer)
+			}
+		}
+		epoch, backends := n.Publish.Epoch, n.Publish.Backends
+		n.lock.RUnlock()
+
+		for _, peer := range peers {
+			if peer.chooseLinkPath(epoch, backends) == nil {
+				n.holePunch(peer)
+			}
+		}
+	}, defaultHolePunchInterval, 1)
+}
+
+// expireHolePunch drops stale sessions and punched paths of left peers.
+func (n *MetadataNetwork) expireHolePunch(now time.Time) {
+	n.punchLock.Lock()
+	for id, session := range n.punchSessions {
+		if now.Sub(session.at) >= defaultHolePunchTimeout {
+			delete(n.punchSessions, id)
+		}
+	}
+	for id, relay := range n.punchRelays {
+		if now.Sub(relay.at) >= defaultHolePunchTimeout {
+			delete(n.punchRelays, id)
+		}
+	}
+	n.punchLock.Unlock()
+
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	n.punched.Range(func(k, v interface{}) bool {
+		if peer := v.(*MetaPeer); peer.left {
+			n.punched.Delete(k)
+		}
+		return true
+	})
+}
+
+// holePunch asks relays to coordinate hole punching to peer unreachable by any path.
+func (n *MetadataNetwork) holePunch(peer *MetaPeer) {
+	// broken punched paths would be punched again.
+	for _, key := range peer.removePunchedPaths(func(path *linkPath) bool { return path.Disabled }) {
+		n.punched.Delete(backend.Endpoint{Type: key.ty, Endpoint: key.remote})
+		n.log.Infof("drop unhealthy punched path %v of %v.", &key, peer)
+	}
+
+	n.lock.RLock()
+	relays := make([]*MetaPeer, 0, len(n.peers))
+	for _, relay := range n.peers {
+		if !relay.IsSelf() && relay != peer && !relay.left {
+			relays = append(relays, relay)
+		}
+	}
+	epoch, backends := n.Publish.Epoch, n.Publish.Backends
+	n.lock.RUnlock()
+
+	now := time.Now()
+	for local, b := range backends {
+		puncher, canPunch := b.(backend.Puncher)
+		if !canPunch {
+			continue
+		}
+		port, enabled := puncher.PunchPort()
+		if !enabled {
+			continue
+		}
+		target := ""
+		for _, endpoint := range peer.Endpoints {
+			if endpoint.Type == local.Type {
+				target = endpoint.Endpoint.Endpoint
+				break
+			}
+		}
+		if target == "" {
+			continue
+		}
+
+		// relay should observe us via the local backend.
+		var via *linkPath
+		for _, relay := range relays {
+			if path := relay.chooseLinkPath(epoch, backends); path != nil && path.local == local.Endpoint && path.ty == local.Type {
+				via = path
+				break
+			}
+		}
+		if via == nil {
+			n.log.Debugf("no relay to punch to %v via %v.", peer, local)
+			continue
+		}
+
+		n.punchLock.Lock()
+		pending := false
+		for _, session := range n.punchSessions {
+			if session.peer == peer && session.local == local {
+				pending = true
+				break
+			}
+		}
+		if pending {
+			n.punchLock.Unlock()
+			continue
+		}
+		n.punchCounter++
+		req := holePunchMessage{kind: holePunchRequest, session: n.punchCounter, port: port, peer: target}
+		n.punchSessions[req.session] = &holePunchSession{peer: peer, local: local, target: target, at: now}
+		n.punchLock.Unlock()
+
+		n.log.Infof("request hole punching to %v via %v. [relay = %v, session = %v]", peer, local, via.remote, req.session)
+		n.SendViaEndpoint(proto.MsgTypeHolePunch, req.Encode(), local, via.remote)
+	}
+}
+
+func (n *MetadataNetwork) onHolePunchMessage(msg *Message) {
+	m := holePunchMessage{}
+	if err := m.Decode(msg.Payload); err != nil {
+		n.log.Errorf("failed to decode a hole punching message. [from = %v, via = %v] (err = \"%v\")", msg.Endpoint, msg.Via, err)
+		return
+	}
+	if msg.Peer() == nil {
+		n.log.Debugf("ignore hole punching message from unknown peer. [from = %v, via = %v]", msg.Endpoint, msg.Via)
+		return
+	}
+	switch m.kind {
+	case holePunchRequest:
+		n.onHolePunchRequest(msg, &m)
+	case holePunchOffer:
+		n.onHolePunchOffer(msg, &m)
+	case holePunchAnswer:
+		n.onHolePunchAnswer(msg, &m)
+	case holePunchConfirm:
+		n.onHolePunchConfirm(msg, &m)
+	default:
+		n.log.Debugf("ignore unknown hole punching message kind %v. [from = %v, via = %v]", m.kind, msg.Endpoint, msg.Via)
+	}
+}
+
+func (n *MetadataNetwork) getObserver(via backend.Endpoint) backend.Observer {
+	n.lock.RLock()
+	b, _ := n.backends[via]
+	n.lock.RUnlock()
+	o, _ := b.(backend.Observer)
+	return o
+}
+
+func (n *MetadataNetwork) getPuncher(via backend.Endpoint) (backend.Puncher, uint16, bool) {
+	n.lock.RLock()
+	b, _ := n.backends[via]
+	n.lock.RUnlock()
+	p, canPunch := b.(backend.Puncher)
+	if !canPunch {
+		return nil, 0, false
+	}
+	port, enabled := p.PunchPort()
+	return p, port, enabled
+}
+
+// onHolePunchRequest offers requester to target, both of which we are observing.
+func (n *MetadataNetwork) onHolePunchRequest(msg *Message, req *holePunchMessage) {
+	o := n.getObserver(msg.Via)
+	if o == nil {
+		return
+	}
+	candidate := punchCandidate(o.ObservedAddress(msg.Endpoint.Endpoint), req.port)
+	if candidate == "" {
+		n.log.Debugf("requester %v of hole punching not observed. [via = %v]", msg.Endpoint, msg.Via)
+		return
+	}
+
+	name := gossipUtils.BuildNodeName(backend.Endpoint{Type: msg.Via.Type, Endpoint: req.peer})
+	n.lock.RLock()
+	target, _ := n.Publish.Name2Peer[name]
+	n.lock.RUnlock()
+	if target == nil {
+		n.log.Debugf("target %v of hole punching not found. [requester = %v]", req.peer, msg.Endpoint)
+		return
+	}
+	identity, observed := "", ""
+	for _, endpoint := range target.Endpoints {
+		if endpoint.Type != msg.Via.Type {
+			continue
+		}
+		if observed = o.ObservedAddress(endpoint.Endpoint.Endpoint); observed != "" {
+			identity = endpoint.Endpoint.Endpoint
+			break
+		}
+	}
+	if identity == "" {
+		n.log.Debugf("target %v of hole punching not observed. [requester = %v, via = %v]", target, msg.Endpoint, msg.Via)
+		return
+	}
+
+	n.punchLock.Lock()
+	n.punchCounter++
+	offer := holePunchMessage{kind: holePunchOffer, session: n.punchCounter, peer: msg.Endpoint.Endpoint, endpoint: candidate}
+	n.punchRelays[offer.session] = &holePunchRelay{
+		requester: msg.Endpoint.Endpoint, target: identity,
+		session: req.session, via: msg.Via, observed: observed,
+		at: time.Now(),
+	}
+	n.punchLock.Unlock()
+
+	n.log.Infof("relay hole punching from %v(%v) to %v(%v). [via = %v]", msg.Endpoint.Endpoint, candidate, identity, observed, msg.Via)
+	n.SendViaEndpoint(proto.MsgTypeHolePunch, offer.Encode(), msg.Via, identity)
+}
+
+// onHolePunchOffer answers relay and starts punching to requester.
+func (n *MetadataNetwork) onHolePunchOffer(msg *Message, offer *holePunchMessage) {
+	puncher, port, enabled := n.getPuncher(msg.Via)
+	if !enabled {
+		return
+	}
+	name := gossipUtils.BuildNodeName(backend.Endpoint{Type: msg.Via.Type, Endpoint: offer.peer})
+	n.lock.RLock()
+	peer, _ := n.Publish.Name2Peer[name]
+	n.lock.RUnlock()
+	if peer == nil || peer.IsSelf() {
+		n.log.Debugf("requester %v of hole punching not found. [relay = %v]", offer.peer, msg.Endpoint)
+		return
+	}
+
+	answer := holePunchMessage{kind: holePunchAnswer, session: offer.session, port: port}
+	n.SendViaEndpoint(proto.MsgTypeHolePunch, answer.Encode(), msg.Via, msg.Endpoint.Endpoint)
+
+	n.goPunch(puncher, peer, msg.Via, offer.endpoint, offer.peer)
+}
+
+// onHolePunchAnswer confirms target to requester.
+func (n *MetadataNetwork) onHolePunchAnswer(msg *Message, answer *holePunchMessage) {
+	n.punchLock.Lock()
+	relay, _ := n.punchRelays[answer.session]
+	if relay == nil || relay.via != msg.Via || relay.target != msg.Endpoint.Endpoint {
+		n.punchLock.Unlock()
+		return
+	}
+	delete(n.punchRelays, answer.session)
+	n.punchLock.Unlock()
+
+	candidate := punchCandidate(relay.observed, answer.port)
+	if candidate == "" {
+		return
+	}
+	confirm := holePunchMessage{kind: holePunchConfirm, session: relay.session, peer: relay.target, endpoint: candidate}
+	n.SendViaEndpoint(proto.MsgTypeHolePunch, confirm.Encode(), relay.via, relay.requester)
+}
+
+// onHolePunchConfirm starts punching to target.
+func (n *MetadataNetwork) onHolePunchConfirm(msg *Message, confirm *holePunchMessage) {
+	n.punchLock.Lock()
+	session, _ := n.punchSessions[confirm.session]
+	if session == nil || session.local != msg.Via || session.target != confirm.peer {
+		n.punchLock.Unlock()
+		return
+	}
+	delete(n.punchSessions, confirm.session)
+	n.punchLock.Unlock()
+
+	puncher, _, enabled := n.getPuncher(msg.Via)
+	if !enabled {
+		return
+	}
+	n.goPunch(puncher, session.peer, msg.Via, confirm.endpoint, confirm.peer)
+}
+
+// goPunch punches to peer and registers link path to it.
+func (n *MetadataNetwork) goPunch(puncher backend.Puncher, peer *MetaPeer, local backend.Endpoint, endpoint, identity string) {
+	for _, published := range peer.Endpoints {
+		if published.Type == local.Type && published.Endpoint.Endpoint == endpoint {
+			return // not behind NAT.
+		}
+	}
+	n.arbiters.main.Go(func() {
+		if _, err := puncher.Punch(endpoint, identity); err != nil {
+			n.log.Warnf("failed to punch to %v(%v) via %v. (err = \"%v\")", identity, endpoint, local, err)
+			return
+		}
+		n.punched.Store(backend.Endpoint{Type: local.Type, Endpoint: endpoint}, peer)
+		key := linkPathKey{local: local.Endpoint, remote: endpoint, ty: local.Type}
+		peer.addPunchedPath(key)
+		n.log.Infof("punched path %v to %v.", &key, peer)
+	})
+}
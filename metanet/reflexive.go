@@ -0,0 +1,374 @@
+package metanet
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/crossmesh/fabric/backend"
+	"github.com/crossmesh/fabric/proto"
+)
+
+const (
+	defaultReflexiveCandidateTTL   = time.Minute * 10
+	defaultReflexiveVerifyInterval = time.Minute * 5
+	maxReflexiveCandidates         = 4
+)
+
+// reflexiveCandidate is endpoint at which local backend is observed by peers.
+// It is advertised after a peer succeeds in handshaking with us there.
+type reflexiveCandidate struct {
+	local      backend.Endpoint
+	endpoint   string
+	seen       time.Time
+	verifiedAt time.Time
+}
+
+func (c *reflexiveCandidate) verified(now time.Time) bool {
+	return !c.verifiedAt.IsZero() && now.Sub(c.verifiedAt) < defaultReflexiveCandidateTTL
+}
+
+type reflexiveVerifyKey struct {
+	candidate backend.Endpoint
+	identity  string
+}
+
+// reflexiveVerifyContext records verification of candidate claimed by peer.
+type reflexiveVerifyContext struct {
+	at      time.Time
+	ok      bool
+	pending bool
+}
+
+// endpointReflection is carried by probe message body.
+// Request lists candidates to be verified, while response carries address requester is observed at
+// and candidates verified.
+type endpointReflection struct {
+	observed   string
+	candidates []string
+}
+
+func (r *endpointReflection) Encode(isResponse bool) (bins []byte) {
+	var buf [2]byte
+
+	bins = append(bins, 0x01) // version.
+	if isResponse {
+		binary.BigEndian.PutUint16(buf[:], uint16(len(r.observed)))
+		bins = append(bins, buf[:]...)
+		bins = append(bins, r.observed...)
+	}
+	bins = append(bins, uint8(len(r.candidates)))
+	for _, candidate := range r.candidates {
+		binary.BigEndian.PutUint16(buf[:], uint16(len(candidate)))
+		bins = append(bins, buf[:]...)
+		bins = append(bins, candidate...)
+	}
+	return
+}
+
+func decodeReflectionString(bins []byte) (string, int, error) {
+	if len(bins) < 2 {
+		return "", 0, ErrMessageStreamTooShort
+	}
+	l := int(binary.BigEndian.Uint16(bins[:2]))
+	if len(bins) < 2+l {
+		return "", 0, ErrMessageStreamTooShort
+	}
+	return string(bins[2 : 2+l]), 2 + l, nil
+}
+
+func (r *endpointReflection) Decode(bins []byte, isResponse bool) error {
+	if len(bins) < 1 {
+		return ErrMessageStreamTooShort
+	}
+	if version := bins[0]; version != 0x01 {
+		return &MessageBrokenError{reason: "unknown reflection version"}
+	}
+	bins = bins[1:]
+	if isResponse {
+		observed, used, err := decodeReflectionString(bins)
+		if err != nil {
+			return err
+		}
+		r.observed, bins = observed, bins[used:]
+	}
+	if len(bins) < 1 {
+		return ErrMessageStreamTooShort
+	}
+	count := int(bins[0])
+	bins = bins[1:]
+	r.candidates = nil
+	for i := 0; i < count; i++ {
+		candidate, used, err := decodeReflectionString(bins)
+		if err != nil {
+			return err
+		}
+		r.candidates, bins = append(r.candidates, candidate), bins[used:]
+	}
+	return nil
+}
+
+func defaultAcceptReflexiveIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast()
+}
+
+func (n *MetadataNetwork) initializeReflexiveDiscovery() {
+	n.arbiters.main.TickGo(func(cancel func(), deadline time.Time) {
+		if n.expireReflexiveCandidates(time.Now()) {
+			n.RepublishEndpoint()
+		}
+	}, time.Minute, 1)
+}
+
+func (n *MetadataNetwork) getReflector(via backend.Endpoint) (backend.Backend, backend.Reflector) {
+	n.lock.RLock()
+	b, _ := n.backends[via]
+	n.lock.RUnlock()
+	if b == nil {
+		return nil, nil
+	}
+	r, _ := b.(backend.Reflector)
+	return b, r
+}
+
+// reflexiveCandidatesOf returns candidates of local backend to be verified by peers.
+func (n *MetadataNetwork) reflexiveCandidatesOf(local backend.Endpoint) (candidates []string) {
+	n.reflectLock.Lock()
+	defer n.reflectLock.Unlock()
+	for ep, candidate := range n.candidates {
+		if candidate.local == local {
+			candidates = append(candidates, ep.Endpoint)
+		}
+	}
+	sort.Strings(candidates)
+	return
+}
+
+// verifiedReflexiveCandidates returns candidates ready for advertising.
+func (n *MetadataNetwork) verifiedReflexiveCandidates(local backend.Endpoint) (candidates []string) {
+	now := time.Now()
+	n.reflectLock.Lock()
+	defer n.reflectLock.Unlock()
+	for ep, candidate := range n.candidates {
+		if candidate.local == local && candidate.verified(now) {
+			candidates = append(candidates, ep.Endpoint)
+		}
+	}
+	sort.Strings(candidates)
+	return
+}
+
+// expireReflexiveCandidates drops stale candidates and verifications.
+// It reports whether any advertised candidate is withdrawn.
+func (n *MetadataNetwork) expireReflexiveCandidates(now time.Time) (withdrawn bool) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	n.reflectLock.Lock()
+	defer n.reflectLock.Unlock()
+
+	for ep, candidate := range n.candidates {
+		_, hasLocal := n.backends[candidate.local]
+		if hasLocal && now.Sub(candidate.seen) < defaultReflexiveCandidateTTL {
+			if !candidate.verifiedAt.IsZero() && !candidate.verified(now) {
+				candidate.verifiedAt = time.Time{}
+				withdrawn = true
+			}
+			continue
+		}
+		if candidate.verified(now) {
+			withdrawn = true
+		}
+		n.log.Infof("reflexive candidate %v of %v expired.", ep, candidate.local)
+		delete(n.candidates, ep)
+	}
+	for key, ctx := range n.verifies {
+		if !ctx.pending && now.Sub(ctx.at) >= defaultReflexiveCandidateTTL {
+			delete(n.verifies, key)
+		}
+	}
+	return
+}
+
+// reflectEndpoint builds reflection of probe request for requester.
+func (n *MetadataNetwork) reflectEndpoint(msg *Message, body []byte) []byte {
+	_, r := n.getReflector(msg.Via)
+	if r == nil {
+		return nil
+	}
+	req, resp := endpointReflection{}, endpointReflection{}
+	if len(body) > 0 {
+		if err := req.Decode(body, false); err != nil {
+			n.log.Debugf("ignore broken reflection request. [from = %v, via = %v] (err = \"%v\")", msg.Endpoint, msg.Via, err)
+			req.candidates = nil
+		}
+	}
+	resp.observed = r.ObservedAddress(msg.Endpoint.Endpoint)
+	if len(req.candidates) > maxReflexiveCandidates {
+		req.candidates = req.candidates[:maxReflexiveCandidates]
+	}
+
+	now := time.Now()
+	n.reflectLock.Lock()
+	defer n.reflectLock.Unlock()
+	for _, candidate := range req.candidates {
+		key := reflexiveVerifyKey{
+			candidate: backend.Endpoint{Type: msg.Via.Type, Endpoint: candidate},
+			identity:  msg.Endpoint.Endpoint,
+		}
+		ctx, _ := n.verifies[key]
+		if ctx != nil && ctx.ok {
+			resp.candidates = append(resp.candidates, candidate)
+		}
+		if ctx == nil {
+			ctx = &reflexiveVerifyContext{}
+			n.verifies[key] = ctx
+		} else if ctx.pending || now.Sub(ctx.at) < defaultReflexiveVerifyInterval {
+			continue
+		}
+		ctx.pending = true
+		n.goVerifyReflexiveCandidate(r, key, msg.Via)
+	}
+	if resp.observed == "" && len(resp.candidates) < 1 {
+		return nil
+	}
+	return resp.Encode(true)
+}
+
+// goVerifyReflexiveCandidate handshakes with candidate claimed by peer.
+// Result is pushed to the peer once candidate is verified.
+func (n *MetadataNetwork) goVerifyReflexiveCandidate(r backend.Reflector, key reflexiveVerifyKey, via backend.Endpoint) {
+	n.arbiters.main.Go(func() {
+		identity, err := r.ProbeEndpoint(key.candidate.Endpoint)
+		ok := err == nil && identity == key.identity
+		if err != nil {
+			n.log.Infof("reflexive candidate %v of %v not reachable. (err = \"%v\")", key.candidate, key.identity, err)
+		} else if !ok {
+			n.log.Warnf("reflexive candidate %v is claimed by %v, but answered by %v.", key.candidate, key.identity, identity)
+		}
+
+		n.reflectLock.Lock()
+		if ctx, _ := n.verifies[key]; ctx != nil {
+			ctx.at, ctx.ok, ctx.pending = time.Now(), ok, false
+		}
+		n.reflectLock.Unlock()
+
+		if !ok {
+			return
+		}
+		n.log.Debugf("reflexive candidate %v of %v verified.", key.candidate, key.identity)
+		header := endpointProbeHeader{isResponse: true}
+		resp := endpointReflection{
+			observed:   r.ObservedAddress(key.identity),
+			candidates: []string{key.candidate.Endpoint},
+		}
+		n.SendViaEndpoint(proto.MsgTypePing, append(header.Encode(), resp.Encode(true)...), via, key.identity)
+	})
+}
+
+// onEndpointReflection learns candidates from reflection of probe response.
+func (n *MetadataNetwork) onEndpointReflection(msg *Message, body []byte) {
+	if len(body) < 1 {
+		return // legacy peer.
+	}
+	resp := endpointReflection{}
+	if err := resp.Decode(body, true); err != nil {
+		n.log.Debugf("ignore broken reflection. [from = %v, via = %v] (err = \"%v\")", msg.Endpoint, msg.Via, err)
+		return
+	}
+	b, r := n.getReflector(msg.Via)
+	if r == nil {
+		return
+	}
+
+	var (
+		endpoint string
+		accepted bool
+	)
+	if resp.observed != "" {
+		endpoint, accepted = n.acceptReflexiveCandidate(b, r, resp.observed)
+	}
+
+	now := time.Now()
+	newCandidate, republish := false, false
+
+	n.reflectLock.Lock()
+	if accepted {
+		key := backend.Endpoint{Type: msg.Via.Type, Endpoint: endpoint}
+		candidate, _ := n.candidates[key]
+		if candidate == nil {
+			if n.evictReflexiveCandidate(msg.Via, now) {
+				republish = true
+			}
+			candidate = &reflexiveCandidate{local: msg.Via, endpoint: endpoint}
+			n.candidates[key] = candidate
+			newCandidate = true
+			n.log.Infof("found reflexive candidate %v of %v. [reflector = %v]", key, msg.Via, msg.Endpoint)
+		}
+		candidate.seen = now
+	}
+	for _, endpoint := range resp.candidates {
+		candidate, _ := n.candidates[backend.Endpoint{Type: msg.Via.Type, Endpoint: endpoint}]
+		if candidate == nil || candidate.local != msg.Via {
+			continue
+		}
+		if !candidate.verified(now) {
+			n.log.Infof("reflexive candidate %v of %v verified by %v.", endpoint, msg.Via, msg.Endpoint)
+			republish = true
+		}
+		candidate.verifiedAt = now
+	}
+	n.reflectLock.Unlock()
+
+	if newCandidate {
+		// ask reflector to verify immediately.
+		header := endpointProbeHeader{}
+		req := endpointReflection{candidates: n.reflexiveCandidatesOf(msg.Via)}
+		n.SendViaEndpoint(proto.MsgTypePing, append(header.Encode(), req.Encode(false)...), msg.Via, msg.Endpoint.Endpoint)
+	}
+	if republish {
+		n.RepublishEndpoint()
+	}
+}
+
+func (n *MetadataNetwork) acceptReflexiveCandidate(b backend.Backend, r backend.Reflector, observed string) (string, bool) {
+	endpoint, ok := r.ReflexiveCandidate(observed)
+	if !ok || endpoint == b.Publish() {
+		return "", false
+	}
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !n.acceptReflexiveIP(ip) {
+		return "", false
+	}
+	n.lock.RLock()
+	_, isLocal := n.backends[backend.Endpoint{Type: b.Type(), Endpoint: endpoint}]
+	n.lock.RUnlock()
+	return endpoint, !isLocal
+}
+
+// evictReflexiveCandidate drops the least recently seen candidate of local backend when the limit is reached.
+// It reports whether the evicted one is advertised.
+func (n *MetadataNetwork) evictReflexiveCandidate(local backend.Endpoint, now time.Time) bool {
+	var (
+		oldest *reflexiveCandidate
+		count  int
+	)
+	for _, candidate := range n.candidates {
+		if candidate.local != local {
+			continue
+		}
+		if count++; oldest == nil || candidate.seen.Before(oldest.seen) {
+			oldest = candidate
+		}
+	}
+	if count < maxReflexiveCandidates || oldest == nil {
+		return false
+	}
+	delete(n.candidates, backend.Endpoint{Type: local.Type, Endpoint: oldest.endpoint})
+	return oldest.verified(now)
+}
@@ -0,0 +1,297 @@
+package metanet
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/crossmesh/fabric/backend"
+	"github.com/crossmesh/fabric/proto"
+)
+
+const (
+	defaultRelayProbeInterval = time.Second * 10
+	defaultRelayRouteTTL      = defaultRelayProbeInterval * 3
+	maxRelayProbeCandidates   = 3
+)
+
+const (
+	relayData          = uint8(0)
+	relayProbeRequest  = uint8(1)
+	relayProbeResponse = uint8(2)
+)
+
+// relayHeader encapsulates message to peer unreachable directly.
+// Message is forwarded by a peer with direct path to destination. Relayed messages are never
+// relayed again.
+type relayHeader struct {
+	kind        uint8
+	id          uint64 // probe ID.
+	source      string // node name.
+	destination string // node name.
+}
+
+func (h *relayHeader) Encode() (bins []byte) {
+	var buf [8]byte
+
+	bins = append(bins, 0x01, h.kind) // version, kind.
+	binary.BigEndian.PutUint64(buf[:], h.id)
+	bins = append(bins, buf[:]...)
+	for _, s := range []string{h.source, h.destination} {
+		binary.BigEndian.PutUint16(buf[:2], uint16(len(s)))
+		bins = append(bins, buf[:2]...)
+		bins = append(bins, s...)
+	}
+	return
+}
+
+func (h *relayHeader) Decode(bins []byte) (int, error) {
+	if len(bins) < 1 {
+		return 0, ErrMessageStreamTooShort
+	}
+	if version := bins[0]; version != 0x01 {
+		return 0, &MessageBrokenError{reason: "unknown relay version"}
+	}
+	if len(bins) < 10 {
+		return 0, ErrMessageStreamTooShort
+	}
+	h.kind = bins[1]
+	h.id = binary.BigEndian.Uint64(bins[2:10])
+	used := 10
+
+	source, sz, err := decodeReflectionString(bins[used:])
+	if err != nil {
+		return 0, err
+	}
+	used += sz
+	destination, sz, err := decodeReflectionString(bins[used:])
+	if err != nil {
+		return 0, err
+	}
+	used += sz
+	h.source, h.destination = source, destination
+	return used, nil
+}
+
+// relayRoute is the best relay probed to reach peer.
+type relayRoute struct {
+	relay *MetaPeer
+	rtt   time.Duration
+	at    time.Time
+}
+
+type relayProbingContext struct {
+	peer, relay *MetaPeer
+	at          time.Time
+}
+
+func (n *MetadataNetwork) initializeRelay() {
+	n.RegisterMessageHandler(proto.MsgTypeRelay, n.onRelayMessage)
+	n.arbiters.main.TickGo(func(cancel func(), deadline time.Time) {
+		n.probeRelays(time.Now())
+	}, defaultRelayProbeInterval, 1)
+}
+
+func (n *MetadataNetwork) selfName() string {
+	self := n.Publish.Self
+	if self == nil {
+		return ""
+	}
+	if names := self.Names(); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// relayCandidates lists peers with healthy direct path, in ascending order of path cost.
+func (n *MetadataNetwork) relayCandidates(peer *MetaPeer) (relays []*MetaPeer) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	costs := make(map[*MetaPeer]uint32, len(n.peers))
+	for _, relay := range n.peers {
+		if relay.IsSelf() || relay == peer || relay.left {
+			continue
+		}
+		path := relay.chooseLinkPath(n.Publish.Epoch, n.Publish.Backends)
+		if path == nil {
+			continue
+		}
+		costs[relay] = path.cost
+		relays = append(relays, relay)
+	}
+	sort.Slice(relays, func(i, j int) bool { return costs[relays[i]] < costs[relays[j]] })
+	return
+}
+
+// probeRelays probes relays to peers without direct path, and expires stale routes.
+func (n *MetadataNetwork) probeRelays(now time.Time) {
+	n.lock.RLock()
+	peers := make([]*MetaPeer, 0, len(n.peers))
+	for _, peer := range n.peers {
+		if !peer.IsSelf() {
+			peers = append(peers, peer)
+		}
+	}
+	n.lock.RUnlock()
+
+	timeout := n.ProbeTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthyCheckProbeTimeout
+	}
+
+	n.relayLock.Lock()
+	for id, ctx := range n.relayProbes {
+		if now.Sub(ctx.at) >= timeout {
+			delete(n.relayProbes, id)
+		}
+	}
+	for peer, route := range n.relayRoutes {
+		if peer.left || now.Sub(route.at) >= defaultRelayRouteTTL {
+			n.log.Infof("relay route to %v via %v expired.", peer, route.relay)
+			delete(n.relayRoutes, peer)
+		}
+	}
+	n.relayLock.Unlock()
+
+	source := n.selfName()
+	if source == "" {
+		return
+	}
+	for _, peer := range peers {
+		if peer.left || peer.chooseLinkPath(n.Publish.Epoch, n.Publish.Backends) != nil {
+			continue
+		}
+		names := peer.Names()
+		if len(names) < 1 {
+			continue
+		}
+		relays := n.relayCandidates(peer)
+		if len(relays) > maxRelayProbeCandidates {
+			relays = relays[:maxRelayProbeCandidates]
+		}
+		for _, relay := range relays {
+			n.relayLock.Lock()
+			n.relayCounter++
+			header := relayHeader{kind: relayProbeRequest, id: n.relayCounter, source: source, destination: names[0]}
+			n.relayProbes[header.id] = &relayProbingContext{peer: peer, relay: relay, at: now}
+			n.relayLock.Unlock()
+
+			n.log.Debugf("probe relay to %v via %v. [request ID = %v]", peer, relay, header.id)
+			n.SendToPeers(proto.MsgTypeRelay, header.Encode(), relay)
+		}
+	}
+}
+
+func (n *MetadataNetwork) getRelayRoute(peer *MetaPeer) *relayRoute {
+	n.relayLock.Lock()
+	defer n.relayLock.Unlock()
+	route, _ := n.relayRoutes[peer]
+	return route
+}
+
+// fitsPath reports whether packed message can be sent through path.
+func fitsPath(path *linkPath, packed int) bool {
+	limiter, limited := path.Backend.(backend.FrameSizeLimiter)
+	return !limited || packed <= limiter.MaxFrameSize()
+}
+
+// sendViaRelay sends packed message to peer through the best relay probed.
+func (n *MetadataNetwork) sendViaRelay(packed []byte, peer *MetaPeer) {
+	route := n.getRelayRoute(peer)
+	if route == nil {
+		return
+	}
+	names := peer.Names()
+	if len(names) < 1 {
+		return
+	}
+	path := route.relay.chooseLinkPath(n.Publish.Epoch, n.Publish.Backends)
+	if path == nil {
+		return
+	}
+	header := relayHeader{kind: relayData, source: n.selfName(), destination: names[0]}
+	payload := append(header.Encode(), packed...)
+	if !fitsPath(path, proto.ProtocolMessageHeaderSize+len(payload)) {
+		n.log.Debugf("drop message too large to relay to %v via %v. [size = %v]", peer, route.relay, len(packed))
+		return
+	}
+	n.SendViaBackend(proto.MsgTypeRelay, payload, path.Backend, path.remote)
+}
+
+func (n *MetadataNetwork) onRelayMessage(msg *Message) {
+	header := relayHeader{}
+	used, err := header.Decode(msg.Payload)
+	if err != nil {
+		n.log.Errorf("failed to decode a relay message. [from = %v, via = %v] (err = \"%v\")", msg.Endpoint, msg.Via, err)
+		return
+	}
+	relay := msg.Peer()
+	if relay == nil || msg.Relay != nil {
+		return
+	}
+	name2Peer := n.Publish.Name2Peer
+	destination, _ := name2Peer[header.destination]
+	source, _ := name2Peer[header.source]
+	if destination == nil || source == nil {
+		n.log.Debugf("drop relay message from %v to %v of unknown peer. [relay = %v]", header.source, header.destination, relay)
+		return
+	}
+
+	if !destination.IsSelf() {
+		// forward to destination by direct path only.
+		if relay != source {
+			return
+		}
+		path := destination.chooseLinkPath(n.Publish.Epoch, n.Publish.Backends)
+		if path == nil || !fitsPath(path, len(msg.Packed)) {
+			return
+		}
+		n.nakedSendViaBackend(msg.Packed, path.Backend, path.remote)
+		return
+	}
+
+	switch header.kind {
+	case relayData:
+		packed := msg.Payload[used:]
+		typeID, payload := proto.UnpackProtocolMessageHeader(packed)
+		if typeID == proto.MsgTypeRelay {
+			return
+		}
+		endpoint, err := backend.ParseEndpoint(header.source)
+		if err != nil {
+			return
+		}
+		n.dispatchMessage(&Message{
+			n:      n,
+			Packed: packed, Payload: payload, TypeID: typeID,
+			Endpoint: endpoint, Via: msg.Via,
+			Relay: relay,
+			peer:  source,
+		})
+
+	case relayProbeRequest:
+		resp := relayHeader{kind: relayProbeResponse, id: header.id, source: header.destination, destination: header.source}
+		n.SendViaEndpoint(proto.MsgTypeRelay, resp.Encode(), msg.Via, msg.Endpoint.Endpoint)
+
+	case relayProbeResponse:
+		now := time.Now()
+
+		n.relayLock.Lock()
+		defer n.relayLock.Unlock()
+		ctx, _ := n.relayProbes[header.id]
+		if ctx == nil || ctx.peer != source || ctx.relay != relay {
+			return
+		}
+		delete(n.relayProbes, header.id)
+		rtt := now.Sub(ctx.at)
+		route, _ := n.relayRoutes[source]
+		if route == nil || route.relay == relay || rtt < route.rtt ||
+			now.Sub(route.at) >= defaultRelayProbeInterval*2 {
+			if route == nil || route.relay != relay {
+				n.log.Infof("relay to %v via %v. [rtt = %v]", source, relay, rtt)
+			}
+			n.relayRoutes[source] = &relayRoute{relay: relay, rtt: rtt, at: now}
+		}
+	}
+}
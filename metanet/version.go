@@ -104,7 +104,7 @@ func (n *MetadataNetwork) updateFromRawVersionInfo(node *sladder.Node, newValue
 
 	vi := gossipUtils.VersionInfoV1{}
 	if err := vi.DecodeString(newValue); err != nil {
-		n.log.Warn("cannot decode VersionInfoV1. (err = \"%v\")", err)
+		n.log.Warnf("cannot decode VersionInfoV1. (err = \"%v\")", err)
 		return
 	}
 
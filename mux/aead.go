@@ -0,0 +1,251 @@
+package mux
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	maxAEADStreamFrameLength = (uint32(1) << 24) - 1
+)
+
+var (
+	FrameTooLarge  = errors.New("frame too large")
+	FrameCorrupted = errors.New("frame corrupted")
+)
+
+// aeadFactory creates AEAD with bound key and given nonce size.
+type aeadFactory func(nonceSize int) (cipher.AEAD, error)
+
+// aeadStreamMuxer seals frames with AEAD.
+// Frame consists of sealed 3-byte length header and sealed payload.
+type aeadStreamMuxer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	factory aeadFactory
+	nonce   []byte
+}
+
+func (m *aeadStreamMuxer) init(w io.Writer, factory aeadFactory, nonce []byte) error {
+	if len(nonce) < 1 {
+		nonce = make([]byte, 12)
+
+		if read, err := rand.Read(nonce); err != nil {
+			return err
+		} else if read != len(nonce) {
+			return fmt.Errorf("%v byte nonce required, but %v read", len(nonce), read)
+		}
+	}
+	m.w, m.factory, m.nonce = w, factory, nonce
+	return m.Reset()
+}
+
+func (m *aeadStreamMuxer) Parallel() bool { return false }
+
+func (m *aeadStreamMuxer) Mux(frame []byte) (written int, err error) {
+	if uint32(len(frame)) > maxAEADStreamFrameLength {
+		return 0, FrameTooLarge
+	}
+	dataSize := len(frame) + m.aead.Overhead()
+	fb := NewFrameBuffer(3 + m.aead.Overhead() + dataSize)
+	defer fb.Release()
+	buf := fb.Bytes()[:0]
+	// encrypt frame header.
+	buf = append(buf, byte(dataSize&0xFF), byte((dataSize>>8)&0xFF), byte((dataSize>>16)&0xFF))
+	buf = m.aead.Seal(buf[:0], m.nonce, buf, nil) // in place.
+	// encrypt data.
+	buf = m.aead.Seal(buf, m.nonce, frame, buf)
+
+	return m.w.Write(buf)
+}
+
+func (m *aeadStreamMuxer) rekey(factory aeadFactory) error {
+	m.factory = factory
+	return m.Reset()
+}
+
+func (m *aeadStreamMuxer) ResetNonce(nonce []byte) error {
+	m.nonce = nonce
+	return m.Reset()
+}
+
+func (m *aeadStreamMuxer) Reset() error {
+	aead, err := m.factory(len(m.nonce))
+	if err != nil {
+		return err
+	}
+	m.aead = aead
+	return nil
+}
+
+// aeadStreamDemuxer opens frames sealed by aeadStreamMuxer.
+type aeadStreamDemuxer struct {
+	aead        cipher.AEAD
+	factory     aeadFactory
+	nonce       []byte
+	buf         []byte
+	frameLength int
+	opened      []byte
+
+	// rekey context.
+	frameAEAD   cipher.AEAD // aead opening current frame.
+	prev        cipher.AEAD
+	next        cipher.AEAD
+	nextFactory aeadFactory
+	getNext     func() (aeadFactory, error)
+}
+
+func (d *aeadStreamDemuxer) init(factory aeadFactory, nonce []byte) error {
+	d.factory, d.nonce = factory, nonce
+	d.buf = make([]byte, 0, defaultBufferSize)
+	d.opened = make([]byte, 0, defaultBufferSize)
+	d.frameLength = -1
+	return d.Reset()
+}
+
+func (d *aeadStreamDemuxer) Demux(raw []byte, emit func([]byte) bool) (read int, err error) {
+	originLen, buf, frameLength, headerLength, cont := len(raw), d.buf, d.frameLength, d.aead.Overhead()+3, true
+
+	defer func() {
+		if err != nil {
+			buf = buf[0:0]
+			frameLength = -1
+		}
+		d.buf, d.frameLength = buf, frameLength
+	}()
+
+	if len(raw) > 0 && cont {
+		// opened frames are kept in d.opened, which grows to the largest frame.
+		openBuf := d.opened
+		defer func() {
+			if cap(openBuf) > cap(d.opened) {
+				d.opened = openBuf[:0]
+			}
+		}()
+
+		for cont {
+			// no enough bytes to open header.
+			if frameLength < 0 {
+				// decrypt header.
+				if lenBuf := len(buf); lenBuf == 0 {
+					if len(raw) < headerLength {
+						// no enough bytes to decrypt header.
+						buf, raw = append(buf, raw...), raw[len(raw):]
+						break
+					}
+
+					// fast path: avoid copying.
+					if openBuf, err = d.openHeader(openBuf[:0], raw[:headerLength]); err != nil {
+						return 0, err
+					}
+				} else {
+					fill := headerLength - len(buf)
+					if fill > len(raw) {
+						fill = len(raw)
+					}
+					buf, raw = append(buf, raw[:fill]...), raw[fill:]
+					if len(buf) < headerLength {
+						// no enough bytes left to decrypt header.
+						break
+					}
+					if openBuf, err = d.openHeader(openBuf[:0], buf[:headerLength]); err != nil {
+						return 0, err
+					}
+				}
+				if len(openBuf) != 3 {
+					return 0, FrameCorrupted
+				}
+				frameLength = int(uint32(openBuf[0]) | (uint32(openBuf[1]) << 8) | (uint32(openBuf[2]) << 16))
+			}
+
+			need := frameLength + headerLength   // frameLength calculated by muxer. overhead has included.
+			if lenBuf := len(buf); lenBuf == 0 { // no copy?
+				if need > len(raw) { // no enough bytes left to decrypt payload.
+					buf, raw = append(buf, raw...), raw[len(raw):]
+					break
+				}
+
+				// fast path: avoid copying while decrypting.
+				if openBuf, err = d.frameAEAD.Open(openBuf[:0], d.nonce, raw[headerLength:need], raw[:headerLength]); err != nil {
+					return 0, err
+				}
+				cont = emit(openBuf)
+
+			} else {
+				need -= lenBuf
+				if need > len(raw) { // no enough bytes left to decrypt payload.
+					buf, raw = append(buf, raw...), raw[len(raw):]
+					break
+				}
+
+				originBuf := buf
+				buf = append(buf, raw[:need]...)
+				// decrypt data.
+				if openBuf, err = d.frameAEAD.Open(openBuf[:0], d.nonce, buf[headerLength:headerLength+frameLength], buf[:headerLength]); err != nil {
+					buf = originBuf
+					return 0, err
+				}
+				cont = emit(openBuf)
+				buf = buf[:0]
+			}
+
+			raw, frameLength = raw[need:], -1
+		}
+	}
+
+	return originLen - len(raw), nil
+}
+
+func (d *aeadStreamDemuxer) Reset() error {
+	aead, err := d.factory(len(d.nonce))
+	if err != nil {
+		return err
+	}
+	d.aead, d.frameAEAD = aead, aead
+	return nil
+}
+
+func (d *aeadStreamDemuxer) enableRekey(getNext func() (aeadFactory, error)) error {
+	d.getNext = getNext
+	return d.prepareNext()
+}
+
+func (d *aeadStreamDemuxer) prepareNext() (err error) {
+	if d.getNext == nil {
+		return nil
+	}
+	if d.nextFactory, err = d.getNext(); err != nil {
+		return err
+	}
+	d.next, err = d.nextFactory(len(d.nonce))
+	return err
+}
+
+func (d *aeadStreamDemuxer) openHeader(dst, header []byte) ([]byte, error) {
+	opened, err := d.aead.Open(dst, d.nonce, header, nil)
+	if err == nil {
+		d.frameAEAD = d.aead
+		return opened, nil
+	}
+	if d.next != nil {
+		if opened, nerr := d.next.Open(dst, d.nonce, header, nil); nerr == nil {
+			// peer switched key.
+			d.prev, d.aead, d.factory = d.aead, d.next, d.nextFactory
+			d.frameAEAD = d.aead
+			if nerr = d.prepareNext(); nerr != nil {
+				return nil, nerr
+			}
+			return opened, nil
+		}
+	}
+	if d.prev != nil {
+		if opened, perr := d.prev.Open(dst, d.nonce, header, nil); perr == nil {
+			d.frameAEAD = d.prev
+			return opened, nil
+		}
+	}
+	return nil, err
+}
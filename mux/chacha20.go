@@ -0,0 +1,111 @@
+package mux
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var ErrInvalidNonceSize = errors.New("invalid nonce size")
+
+func chacha20Factory(key []byte) aeadFactory {
+	key = append([]byte(nil), key...) // caller may reuse key buffer.
+	return func(nonceSize int) (cipher.AEAD, error) {
+		switch nonceSize {
+		case chacha20poly1305.NonceSize:
+			return chacha20poly1305.New(key)
+		case chacha20poly1305.NonceSizeX:
+			return chacha20poly1305.NewX(key)
+		}
+		return nil, ErrInvalidNonceSize
+	}
+}
+
+// ChaCha20StreamMuxer seals frames with ChaCha20-Poly1305.
+// Preferred over GCM on hosts without AES acceleration.
+type ChaCha20StreamMuxer struct {
+	aeadStreamMuxer
+}
+
+func NewChaCha20StreamMuxer(w io.Writer, key, nonce []byte) (*ChaCha20StreamMuxer, error) {
+	m := &ChaCha20StreamMuxer{}
+	if err := m.init(w, chacha20Factory(key), nonce); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Rekey switches to new key. Following frames will be sealed by new key.
+func (m *ChaCha20StreamMuxer) Rekey(key []byte) error {
+	return m.rekey(chacha20Factory(key))
+}
+
+// ChaCha20StreamDemuxer opens frames sealed by ChaCha20StreamMuxer.
+type ChaCha20StreamDemuxer struct {
+	aeadStreamDemuxer
+}
+
+func NewChaCha20StreamDemuxer(key, nonce []byte) (*ChaCha20StreamDemuxer, error) {
+	d := &ChaCha20StreamDemuxer{}
+	if err := d.init(chacha20Factory(key), nonce); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// EnableRekey makes demuxer follow key switches of peer.
+// getNext derives next key. See GCMStreamDemuxer.EnableRekey.
+func (d *ChaCha20StreamDemuxer) EnableRekey(getNext func() ([]byte, error)) error {
+	return d.enableRekey(func() (aeadFactory, error) {
+		key, err := getNext()
+		if err != nil {
+			return nil, err
+		}
+		return chacha20Factory(key), nil
+	})
+}
+
+// ChaCha20RecordMuxer seals frames into records with ChaCha20-Poly1305.
+// Frames can be sealed in parallel. See NewGCMRecordMuxer.
+type ChaCha20RecordMuxer struct {
+	aeadRecordMuxer
+}
+
+func NewChaCha20RecordMuxer(w io.Writer, key, nonce []byte, client bool, workers int) (*ChaCha20RecordMuxer, error) {
+	m := &ChaCha20RecordMuxer{}
+	if err := m.init(w, chacha20Factory(key), nonce, client, workers); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Rekey switches to new key. Following records will be sealed by new key.
+func (m *ChaCha20RecordMuxer) Rekey(key []byte) error {
+	return m.rekey(chacha20Factory(key))
+}
+
+// ChaCha20RecordDemuxer opens records sealed by ChaCha20RecordMuxer of remote peer.
+type ChaCha20RecordDemuxer struct {
+	aeadRecordDemuxer
+}
+
+func NewChaCha20RecordDemuxer(key, nonce []byte, client bool) (*ChaCha20RecordDemuxer, error) {
+	d := &ChaCha20RecordDemuxer{}
+	if err := d.init(chacha20Factory(key), nonce, client); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// EnableRekey makes demuxer follow key switches of peer. See GCMStreamDemuxer.EnableRekey.
+func (d *ChaCha20RecordDemuxer) EnableRekey(getNext func() ([]byte, error)) error {
+	return d.enableRekey(func() (aeadFactory, error) {
+		key, err := getNext()
+		if err != nil {
+			return nil, err
+		}
+		return chacha20Factory(key), nil
+	})
+}
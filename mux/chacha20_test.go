@@ -0,0 +1,175 @@
+package mux
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/sha256"
+	"io/ioutil"
+	"testing"
+)
+
+func TestChaCha20MuxDemux(t *testing.T) {
+	cases := [][]byte{
+		[]byte{0x00, 0xFE, 0x00, 0x01, 0x00, 0x01, 0x01},
+		[]byte{0x00, 0xFE, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x01},
+		[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01},
+	}
+	key, nonce := sha256.Sum256([]byte("testingkey")), make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+	mux, err := NewChaCha20StreamMuxer(buf, key[:], nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := range cases {
+		if _, err = mux.Mux(cases[idx]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for pieceLength := 1; pieceLength < buf.Len(); pieceLength++ {
+		demuxer, err := NewChaCha20StreamDemuxer(key[:], nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		caseN := 0
+		for idx := 0; idx < buf.Len(); idx += pieceLength {
+			end := idx + pieceLength
+			if end >= buf.Len() {
+				end = buf.Len()
+			}
+			if _, err = demuxer.Demux(buf.Bytes()[idx:end], func(frame []byte) bool {
+				if caseN >= len(cases) {
+					t.Fatalf("more then %v frame decoded.", len(cases))
+				}
+				if !bytes.Equal(frame, cases[caseN]) {
+					t.Fatalf("decoded %v: %v, not equal %v", caseN, frame, cases[caseN])
+				}
+				caseN++
+				return true
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if caseN != len(cases) {
+			t.Fatalf("demuxer missing some frame with piece length %v.", pieceLength)
+		}
+	}
+
+	// rekey.
+	next := sha256.Sum256(key[:])
+	if err = mux.Rekey(next[:]); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if _, err = mux.Mux(cases[0]); err != nil {
+		t.Fatal(err)
+	}
+	demuxer, err := NewChaCha20StreamDemuxer(key[:], nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = demuxer.EnableRekey(func() ([]byte, error) { return next[:], nil }); err != nil {
+		t.Fatal(err)
+	}
+	decoded := 0
+	if _, err = demuxer.Demux(buf.Bytes(), func(frame []byte) bool {
+		if bytes.Equal(frame, cases[0]) {
+			decoded++
+		}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != 1 {
+		t.Fatal("frame sealed by next key not accepted.")
+	}
+
+	_, err = NewChaCha20StreamMuxer(buf, key[:], make([]byte, 16))
+	if err != ErrInvalidNonceSize {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func benchmarkMuxers(b *testing.B, size int) {
+	key, nonce := sha256.Sum256([]byte("testingkey")), make([]byte, 12)
+	frame := make([]byte, size)
+	rand.Read(frame)
+
+	b.Run("aes-gcm", func(b *testing.B) {
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			b.Fatal(err)
+		}
+		mux, err := NewGCMStreamMuxer(ioutil.Discard, block, nonce)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mux.Mux(frame)
+		}
+	})
+	b.Run("chacha20-poly1305", func(b *testing.B) {
+		mux, err := NewChaCha20StreamMuxer(ioutil.Discard, key[:], nonce)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mux.Mux(frame)
+		}
+	})
+}
+
+func benchmarkDemuxers(b *testing.B, size int) {
+	key, nonce := sha256.Sum256([]byte("testingkey")), make([]byte, 12)
+	frame := make([]byte, size)
+	rand.Read(frame)
+	emit := func([]byte) bool { return true }
+
+	b.Run("aes-gcm", func(b *testing.B) {
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		mux, _ := NewGCMStreamMuxer(buf, block, nonce)
+		mux.Mux(frame)
+		demuxer, err := NewGCMStreamDemuxer(block, nonce)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			demuxer.Demux(buf.Bytes(), emit)
+		}
+	})
+	b.Run("chacha20-poly1305", func(b *testing.B) {
+		buf := &bytes.Buffer{}
+		mux, _ := NewChaCha20StreamMuxer(buf, key[:], nonce)
+		mux.Mux(frame)
+		demuxer, err := NewChaCha20StreamDemuxer(key[:], nonce)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(size))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			demuxer.Demux(buf.Bytes(), emit)
+		}
+	})
+}
+
+func BenchmarkMux64(b *testing.B)     { benchmarkMuxers(b, 64) }
+func BenchmarkMux1500(b *testing.B)   { benchmarkMuxers(b, 1500) }
+func BenchmarkMux8192(b *testing.B)   { benchmarkMuxers(b, 8192) }
+func BenchmarkDemux64(b *testing.B)   { benchmarkDemuxers(b, 64) }
+func BenchmarkDemux1500(b *testing.B) { benchmarkDemuxers(b, 1500) }
+func BenchmarkDemux8192(b *testing.B) { benchmarkDemuxers(b, 8192) }
@@ -0,0 +1,114 @@
+package mux
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+)
+
+const (
+	frameRaw    = 0x00
+	frameSnappy = 0x01
+
+	// frames smaller than this barely shrink.
+	minCompressSize = 64
+	// MaxDecompressedSize is maximum size of decompressed frame.
+	MaxDecompressedSize = 1 << 16
+)
+
+var ErrCorruptedCompressedFrame = errors.New("corrupted compressed frame")
+
+// CompressStats contains statistics of compressed sending.
+type CompressStats struct {
+	Frames     uint64 // frames sent.
+	Compressed uint64 // frames sent compressed.
+	InBytes    uint64 // bytes before compression.
+	OutBytes   uint64 // bytes after compression.
+}
+
+// Ratio returns ratio of compressed size to original size.
+func (s *CompressStats) Ratio() float64 {
+	if s.InBytes < 1 {
+		return 1
+	}
+	return float64(s.OutBytes) / float64(s.InBytes)
+}
+
+// Compressor compresses frames with Snappy before they are sealed by muxer.
+// Each frame is leaded by one byte indicating whether it's compressed, so that frames that
+// don't shrink are sent as they are.
+type Compressor struct {
+	stats CompressStats
+	plain []byte // decompressed frame. grows to the largest one.
+}
+
+func NewCompressor() *Compressor {
+	return &Compressor{}
+}
+
+// Compress encodes frame and passes it to emit. Encoded frame is valid only during emit.
+func (c *Compressor) Compress(frame []byte, emit func([]byte) error) (err error) {
+	var encoded []byte
+	size := 1 + len(frame)
+	if len(frame) >= minCompressSize {
+		size = 1 + snappy.MaxEncodedLen(len(frame))
+	}
+	fb := NewFrameBuffer(size)
+	defer fb.Release()
+	buf := fb.Bytes()
+
+	if len(frame) >= minCompressSize {
+		compressed := snappy.Encode(buf[1:], frame)
+		if len(compressed) < len(frame) {
+			encoded = buf[:1+len(compressed)]
+			encoded[0] = frameSnappy
+			atomic.AddUint64(&c.stats.Compressed, 1)
+		}
+	}
+	if encoded == nil {
+		encoded = append(append(buf[:0], frameRaw), frame...)
+	}
+	if err = emit(encoded); err != nil {
+		return err
+	}
+	atomic.AddUint64(&c.stats.Frames, 1)
+	atomic.AddUint64(&c.stats.InBytes, uint64(len(frame)))
+	atomic.AddUint64(&c.stats.OutBytes, uint64(len(encoded)))
+	return nil
+}
+
+// Decompress decodes frame encoded by Compress. Decoded frame is valid until next Decompress.
+// Decompress shouldn't be called concurrently.
+func (c *Compressor) Decompress(frame []byte) ([]byte, error) {
+	if len(frame) < 1 {
+		return nil, ErrCorruptedCompressedFrame
+	}
+	switch frame[0] {
+	case frameRaw:
+		return frame[1:], nil
+	case frameSnappy:
+		size, err := snappy.DecodedLen(frame[1:])
+		if err != nil || size > MaxDecompressedSize {
+			return nil, ErrCorruptedCompressedFrame
+		}
+		if cap(c.plain) < size {
+			c.plain = make([]byte, size)
+		}
+		plain, err := snappy.Decode(c.plain[:cap(c.plain)], frame[1:])
+		if err != nil {
+			return nil, ErrCorruptedCompressedFrame
+		}
+		return plain, nil
+	}
+	return nil, ErrCorruptedCompressedFrame
+}
+
+// Stats returns snapshot of statistics.
+func (c *Compressor) Stats() (s CompressStats) {
+	s.Frames = atomic.LoadUint64(&c.stats.Frames)
+	s.Compressed = atomic.LoadUint64(&c.stats.Compressed)
+	s.InBytes = atomic.LoadUint64(&c.stats.InBytes)
+	s.OutBytes = atomic.LoadUint64(&c.stats.OutBytes)
+	return
+}
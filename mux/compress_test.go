@@ -0,0 +1,54 @@
+package mux
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCompressor(t *testing.T) {
+	random := make([]byte, 512)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	cases := [][]byte{
+		[]byte{0x00, 0x01},
+		bytes.Repeat([]byte("{\"level\":\"info\",\"msg\":\"hello\"}"), 32),
+		random,
+	}
+
+	c := NewCompressor()
+	var encoded [][]byte
+	for _, frame := range cases {
+		if err := c.Compress(frame, func(b []byte) error {
+			encoded = append(encoded, append([]byte(nil), b...))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if encoded[0][0] != frameRaw || encoded[2][0] != frameRaw {
+		t.Fatal("frames that don't shrink should be sent as they are.")
+	}
+	if encoded[1][0] != frameSnappy || len(encoded[1]) >= len(cases[1]) {
+		t.Fatal("frame not compressed.")
+	}
+
+	for idx, frame := range encoded {
+		decoded, err := c.Decompress(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, cases[idx]) {
+			t.Fatalf("case %v mismatched.", idx)
+		}
+	}
+	if _, err := c.Decompress([]byte{frameSnappy, 0xFF, 0xFF}); err == nil {
+		t.Fatal("corrupted frame accepted.")
+	}
+
+	stats := c.Stats()
+	if stats.Frames != 3 || stats.Compressed != 1 || stats.Ratio() >= 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,108 @@
+package mux
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// FrameHeadroom is room reserved before frame, so that headers can be prepended without copying.
+const FrameHeadroom = 32
+
+// frame capacities of pooled buffers, headroom excluded.
+var frameBufferClasses = [...]int{
+	defaultBufferSize,
+	2048,
+	16 * 1024,
+	MaxDecompressedSize + 1024,
+}
+
+var frameBufferPools [len(frameBufferClasses)]sync.Pool
+
+// FrameBuffer is pooled buffer holding a frame.
+// It's reference-counted so that a frame broadcasted to many receivers returns to pool after
+// the last receiver releases it. A FrameBuffer shouldn't be modified once it's shared.
+type FrameBuffer struct {
+	refs  int32
+	class int // index of size class. -1 if not pooled.
+	head  int // offset of frame in buf.
+	buf   []byte
+}
+
+// NewFrameBuffer allocates buffer for a frame of given size with FrameHeadroom reserved.
+// Caller holds the only reference.
+func NewFrameBuffer(size int) (b *FrameBuffer) {
+	for class, capacity := range frameBufferClasses {
+		if size > capacity {
+			continue
+		}
+		if v := frameBufferPools[class].Get(); v != nil {
+			b = v.(*FrameBuffer)
+		} else {
+			b = &FrameBuffer{class: class, buf: make([]byte, FrameHeadroom+capacity)}
+		}
+		break
+	}
+	if b == nil { // too large to pool.
+		b = &FrameBuffer{class: -1, buf: make([]byte, FrameHeadroom+size)}
+	}
+	b.refs, b.head, b.buf = 1, FrameHeadroom, b.buf[:FrameHeadroom+size]
+	return b
+}
+
+// Bytes returns the frame. Capacity beyond the frame can be used for appending.
+func (b *FrameBuffer) Bytes() []byte { return b.buf[b.head:] }
+
+// Len returns length of the frame.
+func (b *FrameBuffer) Len() int { return len(b.buf) - b.head }
+
+// Resize changes length of the frame. Content is kept.
+func (b *FrameBuffer) Resize(size int) {
+	if need := b.head + size; need <= cap(b.buf) {
+		b.buf = b.buf[:need]
+		return
+	}
+	b.move(0, size)
+}
+
+// Prepend extends the frame by n bytes at front, and returns the extended frame.
+// Headroom is used if enough. Otherwise, frame is moved.
+func (b *FrameBuffer) Prepend(n int) []byte {
+	if n <= b.head {
+		b.head -= n
+		return b.Bytes()
+	}
+	b.move(n, n+b.Len())
+	return b.Bytes()
+}
+
+// move moves frame to new buffer for size bytes, at offset from start of new frame.
+func (b *FrameBuffer) move(offset, size int) {
+	moved := NewFrameBuffer(size)
+	copy(moved.buf[FrameHeadroom+offset:], b.buf[b.head:])
+	b.class, moved.class = moved.class, b.class
+	b.buf, moved.buf = moved.buf, b.buf
+	b.head = FrameHeadroom
+	moved.Release() // old buffer.
+}
+
+// Ref takes one more reference of buffer.
+func (b *FrameBuffer) Ref() *FrameBuffer {
+	if atomic.AddInt32(&b.refs, 1) < 2 {
+		panic("mux: reference to released FrameBuffer")
+	}
+	return b
+}
+
+// Release drops one reference. Buffer returns to pool once no reference is left.
+func (b *FrameBuffer) Release() {
+	refs := atomic.AddInt32(&b.refs, -1)
+	if refs > 0 {
+		return
+	}
+	if refs < 0 {
+		panic("mux: FrameBuffer released too many times")
+	}
+	if b.class >= 0 {
+		frameBufferPools[b.class].Put(b)
+	}
+}
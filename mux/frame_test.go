@@ -0,0 +1,175 @@
+package mux
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameBuffer(t *testing.T) {
+	b := NewFrameBuffer(100)
+	assert.Equal(t, 100, b.Len())
+	copy(b.Bytes(), bytes.Repeat([]byte{0x01}, 100))
+
+	// prepend within headroom.
+	header := b.Prepend(4)
+	assert.Equal(t, 104, len(header))
+	copy(header, []byte{0xFF, 0xFE, 0xFD, 0xFC})
+	assert.Equal(t, append([]byte{0xFF, 0xFE, 0xFD, 0xFC}, bytes.Repeat([]byte{0x01}, 100)...), b.Bytes())
+
+	// grow across size classes.
+	b.Resize(4000)
+	assert.Equal(t, 4000, b.Len())
+	assert.Equal(t, []byte{0xFF, 0xFE, 0xFD, 0xFC, 0x01}, b.Bytes()[:5])
+	b.Resize(5)
+	assert.Equal(t, []byte{0xFF, 0xFE, 0xFD, 0xFC, 0x01}, b.Bytes())
+
+	// prepend beyond headroom.
+	b.Prepend(FrameHeadroom)
+	b.Prepend(FrameHeadroom)
+	assert.Equal(t, 2*FrameHeadroom+5, b.Len())
+	assert.Equal(t, []byte{0xFF, 0xFE, 0xFD, 0xFC, 0x01}, b.Bytes()[2*FrameHeadroom:])
+
+	// reference counting.
+	assert.Equal(t, b, b.Ref())
+	b.Release()
+	b.Release()
+	assert.Panics(t, func() { b.Release() })
+	assert.Panics(t, func() { b.Ref() })
+
+	// not pooled.
+	large := NewFrameBuffer(MaxDecompressedSize * 2)
+	assert.Equal(t, -1, large.class)
+	assert.Equal(t, MaxDecompressedSize*2, large.Len())
+	large.Release()
+}
+
+type frameCodec struct {
+	name string
+	new  func(w io.Writer) (Muxer, Demuxer)
+}
+
+func testFrameCodecs(tb testing.TB) []frameCodec {
+	key := sha256.Sum256([]byte("testingkey"))
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		tb.Fatal(err)
+	}
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		tb.Fatal(err)
+	}
+	must := func(m Muxer, err error) Muxer {
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return m
+	}
+	mustDemuxer := func(d Demuxer, err error) Demuxer {
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return d
+	}
+	return []frameCodec{
+		{"start_code", func(w io.Writer) (Muxer, Demuxer) {
+			return NewStreamMuxer(w), NewStreamDemuxer()
+		}},
+		{"length", func(w io.Writer) (Muxer, Demuxer) {
+			return NewLengthMuxer(w), NewLengthDemuxer()
+		}},
+		{"aes-gcm", func(w io.Writer) (Muxer, Demuxer) {
+			return must(NewGCMStreamMuxer(w, block, nonce)), mustDemuxer(NewGCMStreamDemuxer(block, nonce))
+		}},
+		{"chacha20-poly1305", func(w io.Writer) (Muxer, Demuxer) {
+			return must(NewChaCha20StreamMuxer(w, key[:], nonce)), mustDemuxer(NewChaCha20StreamDemuxer(key[:], nonce))
+		}},
+		{"aes-gcm_record", func(w io.Writer) (Muxer, Demuxer) {
+			return must(NewGCMRecordMuxer(w, block, nonce, true, 1)), mustDemuxer(NewGCMRecordDemuxer(block, nonce, false))
+		}},
+		{"chacha20-poly1305_record", func(w io.Writer) (Muxer, Demuxer) {
+			return must(NewChaCha20RecordMuxer(w, key[:], nonce, true, 1)), mustDemuxer(NewChaCha20RecordDemuxer(key[:], nonce, false))
+		}},
+	}
+}
+
+var forwardedFrames int
+
+// countForwardedFrame is static, so that passing it to demuxer doesn't allocate closure.
+func countForwardedFrame([]byte) bool {
+	forwardedFrames++
+	return true
+}
+
+// forwardFrame passes frame through muxer and demuxer, as a link does.
+func forwardFrame(tb testing.TB, stream *bytes.Buffer, muxer Muxer, demuxer Demuxer, frame []byte) {
+	stream.Reset()
+	if _, err := muxer.Mux(frame); err != nil {
+		tb.Fatal(err)
+	}
+	forwardedFrames = 0
+	if _, err := demuxer.Demux(stream.Bytes(), countForwardedFrame); err != nil {
+		tb.Fatal(err)
+	}
+	if forwardedFrames != 1 {
+		tb.Fatalf("%v frames emitted.", forwardedFrames)
+	}
+}
+
+func TestFrameForwardingNoGarbage(t *testing.T) {
+	if raceEnabled {
+		t.Skip("pools drop buffers randomly with race detector.")
+	}
+	frame := make([]byte, fullSizeFrame)
+	rand.Read(frame)
+
+	for _, codec := range testFrameCodecs(t) {
+		t.Run(codec.name, func(t *testing.T) {
+			stream := &bytes.Buffer{}
+			muxer, demuxer := codec.new(stream)
+			forwardFrame(t, stream, muxer, demuxer, frame) // warm up.
+			allocs := testing.AllocsPerRun(100, func() {
+				forwardFrame(t, stream, muxer, demuxer, frame)
+			})
+			assert.Equal(t, float64(0), allocs)
+		})
+	}
+
+	t.Run("snappy", func(t *testing.T) {
+		c := NewCompressor()
+		compressible := bytes.Repeat([]byte("{\"level\":\"info\",\"msg\":\"hello\"}"), 48)
+		allocs := testing.AllocsPerRun(100, func() {
+			c.Compress(compressible, func(encoded []byte) error {
+				_, err := c.Decompress(encoded)
+				return err
+			})
+		})
+		assert.Equal(t, float64(0), allocs)
+	})
+}
+
+// BenchmarkFrameForwarding measures full-size frames passing through muxer and demuxer.
+// Steady-state forwarding should report no allocation.
+func BenchmarkFrameForwarding(b *testing.B) {
+	frame := make([]byte, fullSizeFrame)
+	rand.Read(frame)
+
+	for _, codec := range testFrameCodecs(b) {
+		b.Run(codec.name, func(b *testing.B) {
+			stream := &bytes.Buffer{}
+			muxer, demuxer := codec.new(stream)
+			forwardFrame(b, stream, muxer, demuxer, frame)
+			b.SetBytes(fullSizeFrame)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				forwardFrame(b, stream, muxer, demuxer, frame)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package mux
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
@@ -74,3 +75,100 @@ func TestGCMMuxDemux(t *testing.T) {
 		}
 	})
 }
+
+func TestGCMRekey(t *testing.T) {
+	keyChain := func(seed string) func() cipher.Block {
+		key := sha256.Sum256([]byte(seed))
+		return func() cipher.Block {
+			key = sha256.Sum256(key[:])
+			block, err := aes.NewCipher(key[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return block
+		}
+	}
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	sendKeys, recvKeys := keyChain("testingkey"), keyChain("testingkey")
+	initial := sendKeys()
+	recvKeys()
+
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+	mux, err := NewGCMStreamMuxer(buf, initial, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	demuxer, err := NewGCMStreamDemuxer(initial, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = demuxer.EnableRekey(func() (cipher.Block, error) { return recvKeys(), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	old := bytes.NewBuffer(make([]byte, 0, 1024))
+	oldMux, err := NewGCMStreamMuxer(old, initial, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected [][]byte
+	prevKey, currentKey := initial, initial
+	for i := 0; i < 8; i++ {
+		frame := []byte{byte(i), 0x00, 0x00, 0x00, 0x01}
+		if _, err = mux.Mux(frame); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, frame)
+		if i%3 == 2 {
+			prevKey, currentKey = currentKey, sendKeys()
+			if err = mux.Rekey(currentKey); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var decoded [][]byte
+	emit := func(frame []byte) bool {
+		decoded = append(decoded, append([]byte(nil), frame...))
+		return true
+	}
+	if _, err = demuxer.Demux(buf.Bytes(), emit); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(expected) {
+		t.Fatalf("%v frames decoded, but %v expected.", len(decoded), len(expected))
+	}
+	for idx := range expected {
+		if !bytes.Equal(expected[idx], decoded[idx]) {
+			t.Fatalf("decoded %v: %v, not equal %v", idx, decoded[idx], expected[idx])
+		}
+	}
+
+	// in-flight frame sealed by previous key is accepted.
+	inflight := bytes.NewBuffer(make([]byte, 0, 1024))
+	prevMux, err := NewGCMStreamMuxer(inflight, prevKey, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = prevMux.Mux([]byte{0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = demuxer.Demux(inflight.Bytes(), emit); err != nil {
+		t.Fatal(err)
+	}
+	if last := decoded[len(decoded)-1]; !bytes.Equal(last, []byte{0x02}) {
+		t.Fatalf("frame sealed by previous key not accepted.")
+	}
+
+	// frame sealed by key before previous one is rejected.
+	if _, err = oldMux.Mux([]byte{0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = demuxer.Demux(old.Bytes(), emit); err == nil {
+		t.Fatal("frame sealed by stale key accepted.")
+	}
+}
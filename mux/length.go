@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"io"
+)
+
+const (
+	lengthHeaderSize     = 3
+	maxLengthFrameLength = (1 << (lengthHeaderSize * 8)) - 1
+)
+
+// LengthMuxer frames by leading each frame with 3-byte length header.
+// Unlike StreamMuxer, payload is written as it is without escaping.
+type LengthMuxer struct {
+	w io.Writer
+}
+
+func NewLengthMuxer(w io.Writer) *LengthMuxer {
+	return &LengthMuxer{w: w}
+}
+
+func (m *LengthMuxer) Reset() error { return nil }
+
+func (m *LengthMuxer) Parallel() bool { return true }
+
+func (m *LengthMuxer) Mux(frame []byte) (written int, err error) {
+	if len(frame) > maxLengthFrameLength {
+		return 0, FrameTooLarge
+	}
+	size := len(frame)
+	fb := NewFrameBuffer(lengthHeaderSize + size)
+	buf := fb.Bytes()
+	buf[0], buf[1], buf[2] = byte(size&0xFF), byte((size>>8)&0xFF), byte((size>>16)&0xFF)
+	copy(buf[lengthHeaderSize:], frame)
+	written, err = m.w.Write(buf)
+	fb.Release()
+	return
+}
+
+// LengthDemuxer splits frames muxed by LengthMuxer.
+type LengthDemuxer struct {
+	header      [lengthHeaderSize]byte
+	headerRead  int
+	frameLength int
+	buf         []byte
+}
+
+func NewLengthDemuxer() *LengthDemuxer {
+	d := &LengthDemuxer{
+		buf: make([]byte, 0, defaultBufferSize),
+	}
+	d.Reset()
+	return d
+}
+
+func (d *LengthDemuxer) Reset() error {
+	d.headerRead, d.frameLength = 0, -1
+	d.buf = d.buf[:0]
+	return nil
+}
+
+func (d *LengthDemuxer) Demux(raw []byte, emit func([]byte) bool) (read int, err error) {
+	originLen, cont := len(raw), true
+
+	for cont {
+		if d.frameLength < 0 {
+			// header.
+			if len(raw) < 1 {
+				break
+			}
+			fill := copy(d.header[d.headerRead:], raw)
+			d.headerRead += fill
+			raw = raw[fill:]
+			if d.headerRead < lengthHeaderSize {
+				break
+			}
+			d.frameLength = int(uint32(d.header[0]) | (uint32(d.header[1]) << 8) | (uint32(d.header[2]) << 16))
+			d.buf = d.buf[:0]
+		}
+
+		if len(d.buf) == 0 && len(raw) >= d.frameLength {
+			// fast path: avoid copying.
+			cont = emit(raw[:d.frameLength])
+			raw = raw[d.frameLength:]
+		} else {
+			need := d.frameLength - len(d.buf)
+			if need > len(raw) {
+				d.buf, raw = append(d.buf, raw...), raw[len(raw):]
+				break
+			}
+			d.buf, raw = append(d.buf, raw[:need]...), raw[need:]
+			cont = emit(d.buf)
+			d.buf = d.buf[:0]
+		}
+		d.headerRead, d.frameLength = 0, -1
+	}
+
+	return originLen - len(raw), nil
+}
@@ -0,0 +1,144 @@
+package mux
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLengthMuxDemux(t *testing.T) {
+	cases := [][]byte{
+		{0x00, 0x23, 0x48, 0xfe, 0x00, 0x00, 0x01, 0x01},
+		{0x00, 0x00, 0x00, 0x01},
+		{},
+		{0x00},
+		bytes.Repeat([]byte{0x00, 0x00, 0x02}, 400),
+	}
+
+	seq := &bytes.Buffer{}
+	muxer := NewLengthMuxer(seq)
+	for _, c := range cases {
+		written, err := muxer.Mux(c)
+		assert.NoError(t, err)
+		assert.Equal(t, len(c)+lengthHeaderSize, written)
+	}
+	_, err := muxer.Mux(make([]byte, maxLengthFrameLength+1))
+	assert.Equal(t, FrameTooLarge, err)
+
+	demuxer := NewLengthDemuxer()
+	for blkLen := 1; blkLen <= seq.Len(); blkLen++ {
+		t.Run(fmt.Sprintf("block_length_%v", blkLen), func(t *testing.T) {
+			demuxer.Reset()
+			cidx := 0
+			for idx := 0; idx < seq.Len(); idx += blkLen {
+				end := idx + blkLen
+				if end > seq.Len() {
+					end = seq.Len()
+				}
+				read, err := demuxer.Demux(seq.Bytes()[idx:end], func(pkt []byte) bool {
+					if assert.True(t, cidx < len(cases)) {
+						assert.True(t, bytes.Equal(cases[cidx], pkt), "decoded %v: %v, not equal %v", cidx, pkt, cases[cidx])
+					}
+					cidx++
+					return true
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, end-idx, read)
+			}
+			assert.Equal(t, len(cases), cidx)
+		})
+	}
+
+	// stop emitting.
+	demuxer.Reset()
+	read, err := demuxer.Demux(seq.Bytes(), func(pkt []byte) bool { return false })
+	assert.NoError(t, err)
+	assert.Equal(t, len(cases[0])+lengthHeaderSize, read)
+}
+
+const fullSizeFrame = 1500
+
+func fullSizeFrames(zero bool) [][]byte {
+	frames := make([][]byte, 64)
+	for i := range frames {
+		frames[i] = make([]byte, fullSizeFrame)
+		if !zero {
+			rand.Read(frames[i])
+		}
+	}
+	return frames
+}
+
+func BenchmarkFullSizeFrameMux(b *testing.B) {
+	for _, c := range []struct {
+		name  string
+		muxer Muxer
+	}{
+		{"start_code", NewStreamMuxer(ioutil.Discard)},
+		{"length", NewLengthMuxer(ioutil.Discard)},
+	} {
+		for _, zero := range []bool{false, true} {
+			frames, payload := fullSizeFrames(zero), "random"
+			if zero {
+				payload = "zero"
+			}
+			b.Run(c.name+"/"+payload, func(b *testing.B) {
+				b.SetBytes(fullSizeFrame)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := c.muxer.Mux(frames[i%len(frames)]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkFullSizeFrameDemux(b *testing.B) {
+	for _, c := range []struct {
+		name       string
+		newMuxer   func(w *bytes.Buffer) Muxer
+		newDemuxer func() Demuxer
+	}{
+		{"start_code", func(w *bytes.Buffer) Muxer { return NewStreamMuxer(w) }, func() Demuxer { return NewStreamDemuxer() }},
+		{"length", func(w *bytes.Buffer) Muxer { return NewLengthMuxer(w) }, func() Demuxer { return NewLengthDemuxer() }},
+	} {
+		for _, zero := range []bool{false, true} {
+			frames, payload := fullSizeFrames(zero), "random"
+			if zero {
+				payload = "zero"
+			}
+			stream := &bytes.Buffer{}
+			muxer := c.newMuxer(stream)
+			for _, frame := range frames {
+				if _, err := muxer.Mux(frame); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.Run(c.name+"/"+payload, func(b *testing.B) {
+				demuxer, raw, emitted := c.newDemuxer(), stream.Bytes(), 0
+				b.SetBytes(fullSizeFrame)
+				b.ResetTimer()
+				for emitted < b.N {
+					for idx := 0; idx < len(raw) && emitted < b.N; idx += defaultBufferSize * 8 {
+						end := idx + defaultBufferSize*8
+						if end > len(raw) {
+							end = len(raw)
+						}
+						if _, err := demuxer.Demux(raw[idx:end], func(frame []byte) bool {
+							emitted++
+							return true
+						}); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+			})
+		}
+	}
+}
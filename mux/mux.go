@@ -155,17 +155,20 @@ MuxMainLoop:
 }
 
 type StreamDemuxer struct {
-	left int
-	buf  []byte
+	left   int
+	synced bool // start code found.
+	buf    []byte
 }
 
 func NewStreamDemuxer() *StreamDemuxer {
-	d := &StreamDemuxer{}
+	d := &StreamDemuxer{
+		buf: make([]byte, 0, defaultBufferSize),
+	}
 	return d
 }
 
 func (d *StreamDemuxer) Reset() error {
-	d.buf = nil
+	d.synced, d.buf = false, d.buf[:0]
 	return nil
 }
 
@@ -177,7 +180,7 @@ loopForStartCode:
 			switch raw[idx] {
 			case 0x00:
 			case 0x01:
-				d.buf = make([]byte, 0, defaultBufferSize)
+				d.synced, d.buf = true, d.buf[:0]
 				idx++
 				left = 0
 				break loopForStartCode
@@ -199,9 +202,9 @@ loopForStartCode:
 
 func (d *StreamDemuxer) Demux(raw []byte, emit func([]byte) bool) (read int, err error) {
 	idx := 0
-	if d.buf == nil {
+	if !d.synced {
 		idx = d.indexStartCode(raw)
-		if d.buf == nil {
+		if !d.synced {
 			return len(raw), nil
 		}
 	}
@@ -210,9 +213,7 @@ func (d *StreamDemuxer) Demux(raw []byte, emit func([]byte) bool) (read int, err
 	preserved, cont := len(d.buf), true
 	defer func() {
 		if err != nil {
-			if d.buf != nil {
-				d.buf = d.buf[:preserved]
-			}
+			d.buf = d.buf[:preserved]
 		} else {
 			d.left = left
 			d.buf = buf
@@ -0,0 +1,285 @@
+package mux
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	recordHeaderSize      = 8 + 3 // sequence number, sealed payload length.
+	maxRecordSealedLength = (1 << 24) - 1
+
+	recordRoleClient = byte(0x01)
+	recordRoleServer = byte(0x02)
+)
+
+var ErrRecordOutOfOrder = errors.New("record out of order")
+
+func recordRole(client bool) byte {
+	if client {
+		return recordRoleClient
+	}
+	return recordRoleServer
+}
+
+// recordNonce derives nonce of record from IV, sealer role and sequence number,
+// so that nonces never repeat in both directions under the same key.
+func recordNonce(dst, iv []byte, role byte, seq uint64) []byte {
+	var seqBuf [8]byte
+
+	dst = append(dst[:0], iv...)
+	dst[0] ^= role
+	binary.BigEndian.PutUint64(seqBuf[:], seq)
+	for i, off := 0, len(dst)-len(seqBuf); i < len(seqBuf); i++ {
+		dst[off+i] ^= seqBuf[i]
+	}
+	return dst
+}
+
+func recordSealedLength(header []byte) int {
+	return int(uint32(header[8]) | (uint32(header[9]) << 8) | (uint32(header[10]) << 16))
+}
+
+// aeadRecordMuxer seals frames into records with explicit sequence numbers.
+// Record consists of 8-byte sequence number, 3-byte length and sealed payload. The header is
+// authenticated as additional data. Frames are sealed by at most workers goroutines at once, while
+// records are written in order of sequence numbers.
+type aeadRecordMuxer struct {
+	seq uint64 // sequence number of last record sealed. first field for 64-bit atomic alignment.
+
+	w       io.Writer
+	factory aeadFactory
+	iv      []byte
+	role    byte
+	workers chan struct{}
+
+	lock sync.RWMutex // protects aead against rekeying.
+	aead cipher.AEAD
+
+	writeLock sync.Mutex
+	writeCond *sync.Cond
+	written   uint64 // sequence number of last record written.
+	err       error  // first write error. stream is broken since then.
+}
+
+func (m *aeadRecordMuxer) init(w io.Writer, factory aeadFactory, iv []byte, client bool, workers int) error {
+	if len(iv) < 1 {
+		iv = make([]byte, 12)
+
+		if read, err := rand.Read(iv); err != nil {
+			return err
+		} else if read != len(iv) {
+			return fmt.Errorf("%v byte nonce required, but %v read", len(iv), read)
+		}
+	}
+	if len(iv) < 8 {
+		return ErrInvalidNonceSize
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	m.w, m.factory, m.iv, m.role = w, factory, iv, recordRole(client)
+	m.workers = make(chan struct{}, workers)
+	m.writeCond = sync.NewCond(&m.writeLock)
+	return m.Reset()
+}
+
+func (m *aeadRecordMuxer) Parallel() bool { return true }
+
+func (m *aeadRecordMuxer) Mux(frame []byte) (written int, err error) {
+	// worker is acquired before sequence number, so that holder of the smallest unwritten
+	// sequence number never waits for others.
+	m.workers <- struct{}{}
+	defer func() { <-m.workers }()
+
+	m.lock.RLock()
+	aead := m.aead
+	if len(frame)+aead.Overhead() > maxRecordSealedLength {
+		m.lock.RUnlock()
+		return 0, FrameTooLarge
+	}
+	// sequence number is taken under the same read lock as aead, so that records are sealed
+	// by key of their turn.
+	seq := atomic.AddUint64(&m.seq, 1)
+	m.lock.RUnlock()
+
+	// seal. nonce is placed after sealed payload.
+	size := len(frame) + aead.Overhead()
+	fb := NewFrameBuffer(recordHeaderSize + size + len(m.iv))
+	defer fb.Release()
+	buf := fb.Bytes()
+	nonce := recordNonce(buf[recordHeaderSize+size:recordHeaderSize+size], m.iv, m.role, seq)
+	buf = append(buf[:0], 0, 0, 0, 0, 0, 0, 0, 0, byte(size&0xFF), byte((size>>8)&0xFF), byte((size>>16)&0xFF))
+	binary.BigEndian.PutUint64(buf[:8], seq)
+	buf = aead.Seal(buf, nonce, frame, buf[:recordHeaderSize])
+
+	// write in order.
+	m.writeLock.Lock()
+	defer m.writeLock.Unlock()
+	for m.written+1 != seq {
+		m.writeCond.Wait()
+	}
+	if err = m.err; err == nil {
+		if written, err = m.w.Write(buf); err != nil {
+			m.err = err
+		}
+	}
+	m.written = seq
+	m.writeCond.Broadcast()
+	return
+}
+
+func (m *aeadRecordMuxer) rekey(factory aeadFactory) error {
+	aead, err := factory(len(m.iv))
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.factory, m.aead = factory, aead
+	return nil
+}
+
+// Reset recreates AEAD. Sequence number is kept so that nonces are never reused.
+func (m *aeadRecordMuxer) Reset() error {
+	return m.rekey(m.factory)
+}
+
+// aeadRecordDemuxer opens records sealed by aeadRecordMuxer.
+// Records are accepted in strict order of sequence numbers, so that replayed or reordered
+// records are rejected.
+type aeadRecordDemuxer struct {
+	aead    cipher.AEAD
+	factory aeadFactory
+	iv      []byte
+	role    byte   // role of peer sealing records.
+	seq     uint64 // sequence number of last record opened.
+	buf     []byte // partial record.
+	opened  []byte
+	nonce   []byte
+
+	// rekey context.
+	next        cipher.AEAD
+	nextFactory aeadFactory
+	getNext     func() (aeadFactory, error)
+}
+
+func (d *aeadRecordDemuxer) init(factory aeadFactory, iv []byte, client bool) error {
+	if len(iv) < 8 {
+		return ErrInvalidNonceSize
+	}
+	d.factory, d.iv, d.role = factory, iv, recordRole(!client)
+	d.buf = make([]byte, 0, defaultBufferSize)
+	d.opened = make([]byte, 0, defaultBufferSize)
+	return d.Reset()
+}
+
+func (d *aeadRecordDemuxer) Demux(raw []byte, emit func([]byte) bool) (read int, err error) {
+	originLen, cont := len(raw), true
+
+	for cont {
+		var record []byte
+
+		if len(d.buf) == 0 {
+			if len(raw) < recordHeaderSize {
+				d.buf, raw = append(d.buf, raw...), raw[len(raw):]
+				break
+			}
+			size := recordHeaderSize + recordSealedLength(raw)
+			if len(raw) < size {
+				d.buf, raw = append(d.buf, raw...), raw[len(raw):]
+				break
+			}
+			// fast path: avoid copying.
+			record, raw = raw[:size], raw[size:]
+
+		} else {
+			if len(d.buf) < recordHeaderSize {
+				fill := recordHeaderSize - len(d.buf)
+				if fill > len(raw) {
+					fill = len(raw)
+				}
+				d.buf, raw = append(d.buf, raw[:fill]...), raw[fill:]
+				if len(d.buf) < recordHeaderSize {
+					break
+				}
+			}
+			need := recordHeaderSize + recordSealedLength(d.buf) - len(d.buf)
+			if need > len(raw) {
+				d.buf, raw = append(d.buf, raw...), raw[len(raw):]
+				break
+			}
+			d.buf, raw = append(d.buf, raw[:need]...), raw[need:]
+			record = d.buf
+		}
+
+		frame, oerr := d.open(record)
+		d.buf = d.buf[:0]
+		if oerr != nil {
+			return originLen - len(raw), oerr
+		}
+		cont = emit(frame)
+	}
+
+	return originLen - len(raw), nil
+}
+
+func (d *aeadRecordDemuxer) open(record []byte) (frame []byte, err error) {
+	seq := binary.BigEndian.Uint64(record[:8])
+	if seq != d.seq+1 {
+		return nil, ErrRecordOutOfOrder
+	}
+	d.nonce = recordNonce(d.nonce, d.iv, d.role, seq)
+	header, sealed := record[:recordHeaderSize], record[recordHeaderSize:]
+	if frame, err = d.aead.Open(d.opened[:0], d.nonce, sealed, header); err != nil {
+		if d.next == nil {
+			return nil, err
+		}
+		var nerr error
+		if frame, nerr = d.next.Open(d.opened[:0], d.nonce, sealed, header); nerr != nil {
+			return nil, err
+		}
+		// peer switched key.
+		d.aead, d.factory = d.next, d.nextFactory
+		if err = d.prepareNext(); err != nil {
+			return nil, err
+		}
+	}
+	if cap(frame) > cap(d.opened) {
+		d.opened = frame[:0]
+	}
+	d.seq = seq
+	return frame, nil
+}
+
+// Reset recreates AEAD and drops partial record. Sequence number is kept.
+func (d *aeadRecordDemuxer) Reset() error {
+	aead, err := d.factory(len(d.iv))
+	if err != nil {
+		return err
+	}
+	d.aead, d.buf = aead, d.buf[:0]
+	return nil
+}
+
+func (d *aeadRecordDemuxer) enableRekey(getNext func() (aeadFactory, error)) error {
+	d.getNext = getNext
+	return d.prepareNext()
+}
+
+func (d *aeadRecordDemuxer) prepareNext() (err error) {
+	if d.getNext == nil {
+		return nil
+	}
+	if d.nextFactory, err = d.getNext(); err != nil {
+		return err
+	}
+	d.next, err = d.nextFactory(len(d.iv))
+	return err
+}
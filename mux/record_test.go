@@ -0,0 +1,218 @@
+package mux
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRecordCipher(t testing.TB, seed string) (cipher.Block, []byte) {
+	key := sha256.Sum256([]byte(seed))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block, key[:]
+}
+
+func TestRecordMuxDemux(t *testing.T) {
+	cases := [][]byte{
+		{0x00, 0xFE, 0x00, 0x01, 0x00, 0x01, 0x01},
+		{},
+		bytes.Repeat([]byte{0x01, 0x02}, 300),
+	}
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	block, key := newTestRecordCipher(t, "testingkey")
+
+	for _, suite := range []struct {
+		name       string
+		newMuxer   func(w *bytes.Buffer, client bool) (Muxer, error)
+		newDemuxer func(client bool) (Demuxer, error)
+	}{
+		{
+			"gcm",
+			func(w *bytes.Buffer, client bool) (Muxer, error) {
+				return NewGCMRecordMuxer(w, block, nonce, client, 4)
+			},
+			func(client bool) (Demuxer, error) { return NewGCMRecordDemuxer(block, nonce, client) },
+		},
+		{
+			"chacha20",
+			func(w *bytes.Buffer, client bool) (Muxer, error) {
+				return NewChaCha20RecordMuxer(w, key, nonce, client, 4)
+			},
+			func(client bool) (Demuxer, error) { return NewChaCha20RecordDemuxer(key, nonce, client) },
+		},
+	} {
+		t.Run(suite.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			muxer, err := suite.newMuxer(buf, true)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.True(t, muxer.Parallel())
+			var records [][]byte
+			for _, c := range cases {
+				last := buf.Len()
+				_, err = muxer.Mux(c)
+				assert.NoError(t, err)
+				records = append(records, append([]byte(nil), buf.Bytes()[last:]...))
+			}
+
+			// pieces feed.
+			for pieceLength := 1; pieceLength <= buf.Len(); pieceLength++ {
+				demuxer, err := suite.newDemuxer(false)
+				if !assert.NoError(t, err) {
+					return
+				}
+				caseN := 0
+				for idx := 0; idx < buf.Len(); idx += pieceLength {
+					end := idx + pieceLength
+					if end > buf.Len() {
+						end = buf.Len()
+					}
+					read, err := demuxer.Demux(buf.Bytes()[idx:end], func(frame []byte) bool {
+						if assert.True(t, caseN < len(cases)) {
+							assert.True(t, bytes.Equal(cases[caseN], frame), "piece length %v: frame %v mismatched", pieceLength, caseN)
+						}
+						caseN++
+						return true
+					})
+					assert.NoError(t, err)
+					assert.Equal(t, end-idx, read)
+				}
+				assert.Equal(t, len(cases), caseN, "piece length %v", pieceLength)
+			}
+
+			feed := func(demuxer Demuxer, records ...[]byte) (n int, err error) {
+				for _, record := range records {
+					if _, err = demuxer.Demux(record, func([]byte) bool { n++; return true }); err != nil {
+						return
+					}
+				}
+				return
+			}
+			// replay.
+			demuxer, _ := suite.newDemuxer(false)
+			n, err := feed(demuxer, records[0], records[1], records[1])
+			assert.Equal(t, 2, n)
+			assert.Equal(t, ErrRecordOutOfOrder, err)
+			// reorder.
+			demuxer, _ = suite.newDemuxer(false)
+			n, err = feed(demuxer, records[0], records[2], records[1])
+			assert.Equal(t, 1, n)
+			assert.Equal(t, ErrRecordOutOfOrder, err)
+			// reflected.
+			demuxer, _ = suite.newDemuxer(true)
+			n, err = feed(demuxer, records[0])
+			assert.Equal(t, 0, n)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRecordParallelMux(t *testing.T) {
+	block, _ := newTestRecordCipher(t, "testingkey")
+	next, _ := newTestRecordCipher(t, "nextkey")
+	nonce := make([]byte, 12)
+
+	buf := &bytes.Buffer{}
+	muxer, err := NewGCMRecordMuxer(buf, block, nonce, false, 4)
+	if !assert.NoError(t, err) {
+		return
+	}
+	const senders, frames = 8, 64
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < frames; j++ {
+				if i == 0 && j == frames/2 {
+					assert.NoError(t, muxer.Rekey(next))
+				}
+				_, err := muxer.Mux([]byte(fmt.Sprintf("%v-%v", i, j)))
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// every record has unique sequence number, so that no nonce is reused.
+	seqs, stream := make(map[uint64]struct{}), buf.Bytes()
+	for len(stream) >= recordHeaderSize {
+		seqs[binary.BigEndian.Uint64(stream[:8])] = struct{}{}
+		stream = stream[recordHeaderSize+recordSealedLength(stream):]
+	}
+	assert.Equal(t, 0, len(stream))
+	assert.Equal(t, senders*frames, len(seqs))
+
+	demuxer, err := NewGCMRecordDemuxer(block, nonce, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, demuxer.EnableRekey(func() (cipher.Block, error) { return next, nil }))
+	received := make(map[string]struct{})
+	_, err = demuxer.Demux(buf.Bytes(), func(frame []byte) bool {
+		received[string(frame)] = struct{}{}
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, senders*frames, len(received))
+}
+
+// BenchmarkGCMParallelSeal compares sealing full-size frames by concurrent senders.
+// Stream muxer is serialized by lock as TCPLink does.
+func BenchmarkGCMParallelSeal(b *testing.B) {
+	block, _ := newTestRecordCipher(b, "testingkey")
+	nonce := make([]byte, 12)
+	frame := make([]byte, fullSizeFrame)
+	rand.Read(frame)
+
+	stream, err := NewGCMStreamMuxer(ioutil.Discard, block, nonce)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var lock sync.Mutex
+	b.Run("stream", func(b *testing.B) {
+		b.SetBytes(fullSizeFrame)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				lock.Lock()
+				_, err := stream.Mux(frame)
+				lock.Unlock()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	record, err := NewGCMRecordMuxer(ioutil.Discard, block, nonce, true, runtime.GOMAXPROCS(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("record", func(b *testing.B) {
+		b.SetBytes(fullSizeFrame)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := record.Mux(frame); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
@@ -6,6 +6,9 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
+	"time"
+
+	"github.com/crossmesh/fabric/cmd/version"
 )
 
 type Hello struct {
@@ -56,16 +59,100 @@ func (h *Hello) Len() int {
 	return len(h.Lead) + len(h.IV) + len(h.HMAC)
 }
 
-func (h *Hello) Refresh() { rand.Read(h.IV[:]) }
+// Refresh generates new IV leaded by timestamp, so that receiver could reject stale hello.
+func (h *Hello) Refresh() {
+	binary.BigEndian.PutUint64(h.IV[:8], uint64(time.Now().UnixNano()))
+	rand.Read(h.IV[8:])
+}
+
+// Timestamp returns time when hello is generated. It's authenticated by HMAC.
+func (h *Hello) Timestamp() time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(h.IV[:8])))
+}
+
+// HandshakeExtension is optional trailing part of Welcome and Connect.
+// Peers with legacy handshake ignore it.
+type HandshakeExtension struct {
+	Features  version.FeatureSet
+	PublicKey []byte
+	Stripe    uint8 // index of connection among parallel connections to the same peer.
+}
+
+func (e *HandshakeExtension) empty() bool {
+	return len(e.Features) < 1 && len(e.PublicKey) < 1 && e.Stripe == 0
+}
+
+func (e *HandshakeExtension) extensionLen() int {
+	if e.empty() {
+		return 0
+	}
+	n := 1 + len(e.Features) + 1 + len(e.PublicKey)
+	if e.Stripe > 0 {
+		n++
+	}
+	return n
+}
+
+func (e *HandshakeExtension) encodeExtension(buf []byte) ([]byte, error) {
+	if e.empty() {
+		return buf, nil
+	}
+	if len(e.PublicKey) > 0xFF {
+		return buf, ErrBufferTooShort
+	}
+	if len(e.Features) < 1 {
+		buf = append(buf, 0)
+	} else {
+		features, err := e.Features.Encode()
+		if err != nil {
+			return buf, err
+		}
+		buf = append(buf, features...)
+	}
+	buf = append(buf, byte(len(e.PublicKey)))
+	buf = append(buf, e.PublicKey...)
+	if e.Stripe > 0 {
+		buf = append(buf, e.Stripe)
+	}
+	return buf, nil
+}
+
+func (e *HandshakeExtension) decodeExtension(buf []byte) error {
+	e.Features, e.PublicKey, e.Stripe = nil, nil, 0
+	if len(buf) < 1 {
+		return nil
+	}
+	read, err := e.Features.Decode(buf)
+	if err != nil {
+		return ErrInvalidPacket
+	}
+	if read < 1 { // empty set.
+		read = 1
+	}
+	if buf = buf[read:]; len(buf) < 1 {
+		return nil
+	}
+	keyLen := int(buf[0])
+	if keyLen > len(buf)-1 {
+		return ErrInvalidPacket
+	}
+	e.PublicKey = append(e.PublicKey, buf[1:1+keyLen]...)
+	if buf = buf[1+keyLen:]; len(buf) > 0 {
+		e.Stripe = buf[0]
+	}
+	return nil
+}
 
 type Welcome struct {
 	Welcome  bool
 	RawMsg   [31]byte
 	MsgLen   int
 	Identity string
+
+	HandshakeExtension
 }
 
-func (c *Welcome) Len() int { return 1 + c.MsgLen + 2 + len([]byte(c.Identity)) }
+func (c *Welcome) Len() int { return 1 + c.MsgLen + 2 + len([]byte(c.Identity)) + c.extensionLen() }
 
 func (c *Welcome) EncodeMessage(msg string) error {
 	raw := []byte(msg)
@@ -93,6 +180,7 @@ func (c *Welcome) Encode(buf []byte) []byte {
 	buf = append(buf, byte(len(identityBin)&0xFF))
 	buf = append(buf, c.RawMsg[:c.MsgLen]...)
 	buf = append(buf, identityBin...)
+	buf, _ = c.encodeExtension(buf)
 	return buf
 }
 
@@ -114,21 +202,24 @@ func (c *Welcome) Decode(buf []byte) error {
 	c.MsgLen = int(msgLen)
 	copy(c.RawMsg[:c.MsgLen], buf[3:3+c.MsgLen])
 	c.Identity = string(buf[3+c.MsgLen : 3+c.MsgLen+int(identityLength)])
-	return nil
+	return c.decodeExtension(buf[3+c.MsgLen+int(identityLength):])
 }
 
 type Connect struct {
 	Version  uint8
 	Identity string
+
+	HandshakeExtension
 }
 
 const (
-	ConnectNoCrypt   = 0
-	ConnectAES256GCM = 1
+	ConnectNoCrypt          = 0
+	ConnectAES256GCM        = 1
+	ConnectChaCha20Poly1305 = 2
 )
 
 func (c *Connect) Len() int {
-	return 3 + len([]byte(c.Identity))
+	return 3 + len([]byte(c.Identity)) + c.extensionLen()
 }
 
 func (c *Connect) Encode(buf []byte) []byte {
@@ -138,6 +229,7 @@ func (c *Connect) Encode(buf []byte) []byte {
 	buf = append(buf, 0, 0)
 	binary.BigEndian.PutUint16(buf[1:], uint16(len(idBin)))
 	buf = append(buf, idBin...)
+	buf, _ = c.encodeExtension(buf)
 	return buf
 }
 
@@ -151,5 +243,5 @@ func (c *Connect) Decode(buf []byte) error {
 		return ErrInvalidPacket
 	}
 	c.Identity = string(buf[3 : 3+idLen])
-	return nil
+	return c.decodeExtension(buf[3+idLen:])
 }
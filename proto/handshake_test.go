@@ -3,7 +3,9 @@ package proto
 import (
 	"bytes"
 	"testing"
+	"time"
 
+	"github.com/crossmesh/fabric/cmd/version"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,6 +18,7 @@ func TestHello(t *testing.T) {
 
 	assert.True(t, msg.Verify(psk))
 	assert.False(t, msg.Verify([]byte("dadadad")))
+	assert.True(t, time.Since(msg.Timestamp()) < time.Second)
 
 	buf := make([]byte, msg.Len())
 	t.Run("encode", func(t *testing.T) {
@@ -77,3 +80,49 @@ func TestConnect(t *testing.T) {
 	assert.Equal(t, msg.Version, decoded.Version)
 	assert.Equal(t, msg.Identity, decoded.Identity)
 }
+
+func TestHandshakeExtension(t *testing.T) {
+	welcome := &Welcome{
+		Welcome:  true,
+		Identity: "starstudio.org",
+	}
+	assert.NoError(t, welcome.EncodeMessage("ok"))
+	legacy := welcome.Encode(nil)
+
+	welcome.Features.Enable(version.LinkKeyExchange)
+	welcome.PublicKey = bytes.Repeat([]byte{0xAB}, 32)
+	buf := welcome.Encode(nil)
+	assert.Equal(t, welcome.Len(), len(buf))
+	assert.True(t, bytes.HasPrefix(buf, legacy)) // legacy peer ignores extension.
+
+	decoded := &Welcome{}
+	assert.NoError(t, decoded.Decode(buf))
+	assert.True(t, decoded.Features.Enabled(version.LinkKeyExchange))
+	assert.Equal(t, welcome.PublicKey, decoded.PublicKey)
+	assert.Error(t, decoded.Decode(buf[:len(buf)-1]))
+
+	// from legacy peer.
+	assert.NoError(t, decoded.Decode(legacy))
+	assert.False(t, decoded.Features.Enabled(version.LinkKeyExchange))
+	assert.Nil(t, decoded.PublicKey)
+
+	connect := &Connect{
+		Version:  ConnectAES256GCM,
+		Identity: "starstudio.org",
+	}
+	connect.PublicKey = []byte{1, 2, 3}
+	buf = connect.Encode(nil)
+	assert.Equal(t, connect.Len(), len(buf))
+	decodedConnect := &Connect{}
+	assert.NoError(t, decodedConnect.Decode(buf))
+	assert.Equal(t, connect.PublicKey, decodedConnect.PublicKey)
+	assert.Equal(t, 0, len(decodedConnect.Features))
+	assert.Equal(t, uint8(0), decodedConnect.Stripe)
+
+	connect.Stripe = 3
+	buf = connect.Encode(nil)
+	assert.Equal(t, connect.Len(), len(buf))
+	assert.NoError(t, decodedConnect.Decode(buf))
+	assert.Equal(t, connect.PublicKey, decodedConnect.PublicKey)
+	assert.Equal(t, uint8(3), decodedConnect.Stripe)
+}
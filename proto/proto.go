@@ -31,6 +31,8 @@ const (
 	MsgTypePeerExchange = uint16(5)
 	MsgTypePing         = uint16(6)
 	MsgTypeRawFrame     = uint16(7)
+	MsgTypeHolePunch    = uint16(8)
+	MsgTypeRelay        = uint16(9)
 )
 
 var IDByProtoType map[reflect.Type]uint16 = map[reflect.Type]uint16{
@@ -12,8 +12,9 @@ var (
 type p2pL2MeshPeerRef struct {
 	lock sync.RWMutex
 
-	peer   MeshNetPeer
-	macSet map[[6]byte]struct{}
+	peer    MeshNetPeer
+	unicast []MeshNetPeer // routes to the peer only. shared by results of Route.
+	macSet  map[[6]byte]struct{}
 }
 
 // P2PL2MeshNetworkRouter implements symmetry peer-to-peer ethernet network.
@@ -59,7 +60,11 @@ func (r *P2PL2MeshNetworkRouter) Route(frame []byte, from MeshNetPeer) (peers []
 		}
 		peer, hasPeer := routes[dst]
 		if hasPeer && peer != nil {
-			peers = []MeshNetPeer{peer.(MeshNetPeer)}
+			if ref, _ := peerSet[peer.HashID()]; ref != nil && ref.peer == peer {
+				peers = ref.unicast
+			} else {
+				peers = []MeshNetPeer{peer.(MeshNetPeer)}
+			}
 		}
 	}
 	if len(peers) < 1 { // boardcast.
@@ -140,8 +145,9 @@ func (r *P2PL2MeshNetworkRouter) PeerJoin(peer MeshNetPeer) {
 		newPeers[id] = peer
 	}
 	newPeers[id] = &p2pL2MeshPeerRef{
-		peer:   peer,
-		macSet: make(map[[6]byte]struct{}),
+		peer:    peer,
+		unicast: []MeshNetPeer{peer},
+		macSet:  make(map[[6]byte]struct{}),
 	}
 	r.peers = newPeers
 }
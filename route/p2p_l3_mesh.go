@@ -1,11 +1,9 @@
 package route
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"net"
-	"sort"
 	"sync"
 
 	"github.com/crossmesh/fabric/common"
@@ -19,13 +17,15 @@ var (
 type p2pL3IPv4MeshPeerRef struct {
 	lock sync.RWMutex
 
-	peer  MeshNetPeer
-	ipSet map[[4]byte]struct{}
+	peer    MeshNetPeer
+	unicast []MeshNetPeer // routes to the peer only. shared by results of Route.
+	ipSet   map[[4]byte]struct{}
 }
 
 type p2pL3IPv4CIDRRoute struct {
-	cidr net.IPNet
-	peer MeshNetPeer
+	cidr    net.IPNet
+	peer    MeshNetPeer
+	unicast []MeshNetPeer
 }
 
 // P2PL3IPv4MeshNetworkRouter implements symmetry peer-to-peer ipv4 network.
@@ -75,13 +75,17 @@ func (r *P2PL3IPv4MeshNetworkRouter) Route(packet []byte, from MeshNetPeer) (pee
 		if !ip.Equal(net.IPv4bcast) { // unicast.
 			peer, hasPeer := ip2Peer[dst]
 			if hasPeer && peer != nil {
-				peers = []MeshNetPeer{peer}
+				if ref, _ := peerSet[peer.HashID()]; ref != nil && ref.peer == peer {
+					peers = ref.unicast
+				} else {
+					peers = []MeshNetPeer{peer}
+				}
 			}
 		}
 		if len(peers) < 1 { // lookup static CIDR routes.
 			for _, route := range cidrRoutes {
 				if route.cidr.Contains(ip) {
-					peers = []MeshNetPeer{route.peer}
+					peers = route.unicast
 					break
 				}
 			}
@@ -171,8 +175,9 @@ func (r *P2PL3IPv4MeshNetworkRouter) PeerJoin(peer MeshNetPeer) {
 		newPeers[id] = peer
 	}
 	newPeers[id] = &p2pL3IPv4MeshPeerRef{
-		peer:  peer,
-		ipSet: map[[4]byte]struct{}{},
+		peer:    peer,
+		unicast: []MeshNetPeer{peer},
+		ipSet:   map[[4]byte]struct{}{},
 	}
 	r.peers = newPeers
 }
@@ -256,40 +261,32 @@ func (r *P2PL3IPv4MeshNetworkRouter) RemoveStaticCIDRRoutes(peer MeshNetPeer, ro
 		return false
 	}
 
-	cidrRoutes, newCIDRRoutes := r.cidrRoutes, ([]*p2pL3IPv4CIDRRoute)(nil)
-	set := common.IPNetSet(routes)
-	set = set.Clone()
-	set.Build()
-	for i, copyPtr := 0, 0; i < len(cidrRoutes); i++ {
-		route := cidrRoutes[i]
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cidrRoutes := r.cidrRoutes
+	newCIDRRoutes := make([]*p2pL3IPv4CIDRRoute, 0, len(cidrRoutes))
+	for _, route := range cidrRoutes {
+		removed := false
 		if route.peer == peer {
-			if idx := sort.Search(set.Len(), func(i int) bool {
-				le, ge := common.IPNetLess(&route.cidr, set[i]), common.IPNetLess(set[i], &route.cidr)
-				return le == ge || ge
-			}); idx < set.Len() {
-				if possibleRoute := set[idx]; bytes.Compare(possibleRoute.IP, route.cidr.IP) == 0 &&
-					bytes.Compare(possibleRoute.Mask, route.cidr.Mask) == 0 {
-					continue
+			for _, cidr := range routes {
+				if cidr != nil && route.cidr.IP.Equal(cidr.IP) &&
+					common.IPMaskPrefixLen(route.cidr.Mask) == common.IPMaskPrefixLen(cidr.Mask) {
+					removed = true
+					break
 				}
 			}
 		}
-
-		if newCIDRRoutes == nil {
-			if copyPtr != i {
-				newCIDRRoutes = append(newCIDRRoutes, cidrRoutes[:copyPtr]...)
-			}
-		} else {
+		if !removed {
 			newCIDRRoutes = append(newCIDRRoutes, route)
 		}
-		copyPtr++
 	}
-
-	if newCIDRRoutes != nil {
-		r.cidrRoutes = newCIDRRoutes
-		return true
+	if len(newCIDRRoutes) == len(cidrRoutes) {
+		return false
 	}
+	r.cidrRoutes = newCIDRRoutes
 
-	return false
+	return true
 }
 
 // AddStaticCIDRRoutes add static CIDR prefix routes.
@@ -309,8 +306,7 @@ func (r *P2PL3IPv4MeshNetworkRouter) AddStaticCIDRRoutes(peer MeshNetPeer, route
 	defer r.lock.Unlock()
 
 	peers, cidrRoutes := r.peers, r.cidrRoutes
-	ref := peers[peer.HashID()]
-	if ref.peer != peer {
+	if ref := peers[id]; ref == nil || ref.peer != peer {
 		return ErrInvalidPeer
 	}
 	set := make(common.IPNetSet, 0, len(cidrRoutes)+len(routes))
@@ -326,13 +322,19 @@ func (r *P2PL3IPv4MeshNetworkRouter) AddStaticCIDRRoutes(peer MeshNetPeer, route
 	if overlapped, n1, n2 := common.IPNetOverlapped(set...); overlapped {
 		return fmt.Errorf("route CIDR %v and route CIDR %v are overlapped in range", n1.String(), n2.String())
 	}
-	for _, cidr := range routes { // just append.
-		cidrRoutes = append(cidrRoutes, &p2pL3IPv4CIDRRoute{
-			cidr: *cidr,
-			peer: peer,
+	newCIDRRoutes := make([]*p2pL3IPv4CIDRRoute, len(cidrRoutes), len(cidrRoutes)+len(routes))
+	copy(newCIDRRoutes, cidrRoutes) // copy-on-write.
+	for _, cidr := range routes {
+		if cidr == nil {
+			continue
+		}
+		newCIDRRoutes = append(newCIDRRoutes, &p2pL3IPv4CIDRRoute{
+			cidr:    *cidr,
+			peer:    peer,
+			unicast: []MeshNetPeer{peer},
 		})
 	}
-	r.cidrRoutes = cidrRoutes
+	r.cidrRoutes = newCIDRRoutes
 
 	return nil
 }
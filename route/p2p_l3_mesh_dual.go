@@ -0,0 +1,81 @@
+package route
+
+import (
+	"net"
+
+	"github.com/crossmesh/fabric/common"
+)
+
+// P2PL3MeshNetworkRouter implements symmetry peer-to-peer dual-stack network.
+// Packets are dispatched to ipv4 or ipv6 router by IP version.
+type P2PL3MeshNetworkRouter struct {
+	ipv4 *P2PL3IPv4MeshNetworkRouter
+	ipv6 *P2PL3IPv6MeshNetworkRouter
+}
+
+// NewP2PL3MeshNetworkRouter initializes new P2PL3MeshNetworkRouter.
+func NewP2PL3MeshNetworkRouter() *P2PL3MeshNetworkRouter {
+	return &P2PL3MeshNetworkRouter{
+		ipv4: NewP2PL3IPv4MeshNetworkRouter(),
+		ipv6: NewP2PL3IPv6MeshNetworkRouter(),
+	}
+}
+
+// Route routes ipv4 or ipv6 packet.
+func (r *P2PL3MeshNetworkRouter) Route(packet []byte, from MeshNetPeer) []MeshNetPeer {
+	if len(packet) < 1 {
+		return nil
+	}
+	switch uint8(packet[0]) >> 4 {
+	case 4:
+		return r.ipv4.Route(packet, from)
+	case 6:
+		return r.ipv6.Route(packet, from)
+	}
+	return nil
+}
+
+// PeerJoin joins new peer.
+func (r *P2PL3MeshNetworkRouter) PeerJoin(peer MeshNetPeer) {
+	r.ipv4.PeerJoin(peer)
+	r.ipv6.PeerJoin(peer)
+}
+
+// PeerLeave removes peer and related routes.
+func (r *P2PL3MeshNetworkRouter) PeerLeave(peer MeshNetPeer) {
+	r.ipv4.PeerLeave(peer)
+	r.ipv6.PeerLeave(peer)
+}
+
+// splitCIDRRoutes splits routes by address family.
+func splitCIDRRoutes(routes []*net.IPNet) (v4, v6 []*net.IPNet) {
+	for _, route := range routes {
+		if route == nil {
+			continue
+		}
+		if route.IP.To4() != nil {
+			v4 = append(v4, route)
+		} else {
+			v6 = append(v6, route)
+		}
+	}
+	return
+}
+
+// RemoveStaticCIDRRoutes removes static CIDR prefix routes.
+func (r *P2PL3MeshNetworkRouter) RemoveStaticCIDRRoutes(peer MeshNetPeer, routes ...*net.IPNet) bool {
+	v4, v6 := splitCIDRRoutes(routes)
+	removed := r.ipv4.RemoveStaticCIDRRoutes(peer, v4...)
+	return r.ipv6.RemoveStaticCIDRRoutes(peer, v6...) || removed
+}
+
+// AddStaticCIDRRoutes add static CIDR prefix routes.
+// Routes of both address families are applied independently, so that failure of one
+// doesn't block the other.
+func (r *P2PL3MeshNetworkRouter) AddStaticCIDRRoutes(peer MeshNetPeer, routes ...*net.IPNet) error {
+	var errs common.Errors
+	v4, v6 := splitCIDRRoutes(routes)
+	errs.Trace(r.ipv4.AddStaticCIDRRoutes(peer, v4...))
+	errs.Trace(r.ipv6.AddStaticCIDRRoutes(peer, v6...))
+	return errs.AsError()
+}
@@ -0,0 +1,321 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/crossmesh/fabric/common"
+)
+
+type p2pL3IPv6MeshPeerRef struct {
+	lock sync.RWMutex
+
+	peer    MeshNetPeer
+	unicast []MeshNetPeer // routes to the peer only. shared by results of Route.
+	ipSet   map[[16]byte]struct{}
+}
+
+type p2pL3IPv6CIDRRoute struct {
+	cidr    net.IPNet
+	peer    MeshNetPeer
+	unicast []MeshNetPeer
+}
+
+// P2PL3IPv6MeshNetworkRouter implements symmetry peer-to-peer ipv6 network.
+type P2PL3IPv6MeshNetworkRouter struct {
+	lock       sync.RWMutex
+	ip2Peer    map[[16]byte]MeshNetPeer         // (copy-on-write)
+	peers      map[string]*p2pL3IPv6MeshPeerRef // (copy-on-write)
+	cidrRoutes []*p2pL3IPv6CIDRRoute            // (copy-on-write)
+}
+
+// NewP2PL3IPv6MeshNetworkRouter initializes new P2PL3IPv6MeshNetworkRouter.
+func NewP2PL3IPv6MeshNetworkRouter() *P2PL3IPv6MeshNetworkRouter {
+	return &P2PL3IPv6MeshNetworkRouter{
+		peers:   make(map[string]*p2pL3IPv6MeshPeerRef),
+		ip2Peer: make(map[[16]byte]MeshNetPeer),
+	}
+}
+
+// routableIPv6 reports whether address can be routed or learned.
+// lookback, unspecified and link-local addresses are dropped. multicast is not supported yet.
+func routableIPv6(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsMulticast() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast()
+}
+
+// Route routes packet.
+// param `packet` is ipv6 packet.
+func (r *P2PL3IPv6MeshNetworkRouter) Route(packet []byte, from MeshNetPeer) (peers []MeshNetPeer) {
+	// This function will be massively called. Be careful for performance penalty.
+
+	var dst, src [16]byte
+	if len(packet) < 40 {
+		// packet too small.
+		return
+	}
+	if version := uint8(packet[0]) >> 4; version != 6 {
+		// not version 6.
+		return
+	}
+
+	ip2Peer, peerSet, cidrRoutes := r.ip2Peer, r.peers, r.cidrRoutes // for lock-free read, must copy a reference first.
+
+	fromRef, _ := peerSet[from.HashID()]
+	if fromRef == nil {
+		// drop packet from an unknown peer.
+		return
+	}
+
+	copy(dst[:], packet[24:40])
+	if ip := net.IP(dst[:]); routableIPv6(ip) {
+		// lookup.
+		peer, hasPeer := ip2Peer[dst]
+		if hasPeer && peer != nil {
+			if ref, _ := peerSet[peer.HashID()]; ref != nil && ref.peer == peer {
+				peers = ref.unicast
+			} else {
+				peers = []MeshNetPeer{peer}
+			}
+		}
+		if len(peers) < 1 { // lookup static CIDR routes.
+			for _, route := range cidrRoutes {
+				if route.cidr.Contains(ip) {
+					peers = route.unicast
+					break
+				}
+			}
+		}
+		if len(peers) < 1 { // boardcast.
+			for _, ref := range peerSet {
+				if peer := ref.peer; from.IsSelf() != peer.IsSelf() {
+					peers = append(peers, peer)
+				}
+			}
+		}
+	}
+
+	// learn.
+	copy(src[:], packet[8:24])
+	if !routableIPv6(net.IP(src[:])) {
+		return
+	}
+
+	origin, hasRoute := ip2Peer[src]
+	if hasRoute && origin == from { // exists.
+		return
+	}
+
+	// try to update routes.
+	r.lock.Lock()
+
+	ip2Peer, peerSet = r.ip2Peer, r.peers
+	if origin, hasRoute = ip2Peer[src]; hasRoute && origin == from { // exists.
+		r.lock.Unlock()
+		return
+	}
+	if origin != nil {
+		if ref, _ := peerSet[origin.HashID()]; ref != nil { // should has peer.
+			ref.lock.Lock()
+			delete(ref.ipSet, src)
+			ref.lock.Unlock()
+		}
+	}
+	fromRef.lock.Lock()
+	fromRef.ipSet[src] = struct{}{}
+	fromRef.lock.Unlock()
+
+	// route updates.
+	newRoutes := make(map[[16]byte]MeshNetPeer, len(ip2Peer)+1)
+	for dst, peer := range ip2Peer {
+		newRoutes[dst] = peer
+	}
+	newRoutes[src] = from
+	r.ip2Peer = newRoutes // replace the old.
+
+	r.lock.Unlock()
+
+	return
+}
+
+// PeerJoin joins new peer.
+func (r *P2PL3IPv6MeshNetworkRouter) PeerJoin(peer MeshNetPeer) {
+	if peer == nil {
+		return
+	}
+	id := peer.HashID()
+	if id == "" {
+		return
+	}
+
+	peers := r.peers
+	if ref, hasPeer := peers[id]; hasPeer && peer == ref.peer {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	peers = r.peers
+	if ref, hasPeer := peers[id]; hasPeer && peer == ref.peer {
+		return
+	}
+	newPeers := make(map[string]*p2pL3IPv6MeshPeerRef, len(peers)+1)
+	for id, peer := range peers {
+		newPeers[id] = peer
+	}
+	newPeers[id] = &p2pL3IPv6MeshPeerRef{
+		peer:    peer,
+		unicast: []MeshNetPeer{peer},
+		ipSet:   map[[16]byte]struct{}{},
+	}
+	r.peers = newPeers
+}
+
+// PeerLeave removes peer and related routes.
+func (r *P2PL3IPv6MeshNetworkRouter) PeerLeave(peer MeshNetPeer) {
+	if peer == nil {
+		return
+	}
+	id := peer.HashID()
+	if id == "" {
+		return
+	}
+
+	peers := r.peers
+	if ref, hasPeer := peers[id]; !hasPeer || peer != ref.peer {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	peers, ip2Peer := r.peers, r.ip2Peer
+	ref, hasPeer := peers[id]
+	if !hasPeer || peer != ref.peer {
+		return
+	}
+
+	ref.lock.Lock()
+	// route updates.
+	newRoutes := make(map[[16]byte]MeshNetPeer, len(ip2Peer))
+	for rip, peer := range ip2Peer {
+		if _, exist := ref.ipSet[rip]; exist {
+			continue
+		}
+		newRoutes[rip] = peer
+	}
+	r.ip2Peer = newRoutes
+	ref.lock.Unlock()
+
+	cidrRoutes := r.cidrRoutes
+	newCIDRRoutes := make([]*p2pL3IPv6CIDRRoute, 0, len(cidrRoutes))
+	for _, route := range cidrRoutes {
+		if route.peer != peer {
+			newCIDRRoutes = append(newCIDRRoutes, route)
+		}
+	}
+	if len(newCIDRRoutes) != len(cidrRoutes) {
+		r.cidrRoutes = newCIDRRoutes
+	}
+
+	// peer updates.
+	newPeers := make(map[string]*p2pL3IPv6MeshPeerRef, len(peers))
+	for pid, peer := range peers {
+		if pid == id {
+			continue
+		}
+		newPeers[pid] = peer
+	}
+	r.peers = newPeers
+}
+
+// RemoveStaticCIDRRoutes removes static CIDR prefix routes.
+func (r *P2PL3IPv6MeshNetworkRouter) RemoveStaticCIDRRoutes(peer MeshNetPeer, routes ...*net.IPNet) bool {
+	if len(routes) < 1 {
+		return false
+	}
+	if peer == nil {
+		return false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cidrRoutes := r.cidrRoutes
+	newCIDRRoutes := make([]*p2pL3IPv6CIDRRoute, 0, len(cidrRoutes))
+	for _, route := range cidrRoutes {
+		removed := false
+		if route.peer == peer {
+			for _, cidr := range routes {
+				if cidr != nil && route.cidr.IP.Equal(cidr.IP) &&
+					common.IPMaskPrefixLen(route.cidr.Mask) == common.IPMaskPrefixLen(cidr.Mask) {
+					removed = true
+					break
+				}
+			}
+		}
+		if !removed {
+			newCIDRRoutes = append(newCIDRRoutes, route)
+		}
+	}
+	if len(newCIDRRoutes) == len(cidrRoutes) {
+		return false
+	}
+	r.cidrRoutes = newCIDRRoutes
+
+	return true
+}
+
+// AddStaticCIDRRoutes add static CIDR prefix routes.
+func (r *P2PL3IPv6MeshNetworkRouter) AddStaticCIDRRoutes(peer MeshNetPeer, routes ...*net.IPNet) error {
+	if len(routes) < 1 {
+		return nil
+	}
+	if peer == nil {
+		return nil
+	}
+	id := peer.HashID()
+	if id == "" {
+		return ErrInvalidPeerID
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	peers, cidrRoutes := r.peers, r.cidrRoutes
+	if ref := peers[id]; ref == nil || ref.peer != peer {
+		return ErrInvalidPeer
+	}
+	set := make(common.IPNetSet, 0, len(cidrRoutes)+len(routes))
+	for _, route := range cidrRoutes {
+		set = append(set, &route.cidr)
+	}
+	for _, route := range routes {
+		if route == nil {
+			continue
+		}
+		if route.IP.To4() != nil {
+			return fmt.Errorf("route CIDR %v is not an ipv6 prefix", route.String())
+		}
+		set = append(set, route)
+	}
+	if overlapped, n1, n2 := common.IPNetOverlapped(set...); overlapped {
+		return fmt.Errorf("route CIDR %v and route CIDR %v are overlapped in range", n1.String(), n2.String())
+	}
+	newCIDRRoutes := make([]*p2pL3IPv6CIDRRoute, len(cidrRoutes), len(cidrRoutes)+len(routes))
+	copy(newCIDRRoutes, cidrRoutes)
+	for _, cidr := range routes {
+		if cidr == nil {
+			continue
+		}
+		newCIDRRoutes = append(newCIDRRoutes, &p2pL3IPv6CIDRRoute{
+			cidr:    *cidr,
+			peer:    peer,
+			unicast: []MeshNetPeer{peer},
+		})
+	}
+	r.cidrRoutes = newCIDRRoutes
+
+	return nil
+}
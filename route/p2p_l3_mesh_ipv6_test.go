@@ -0,0 +1,184 @@
+package route
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildIPv6Packet builds header of ipv6 icmp packet.
+func buildIPv6Packet(src, dst string) []byte {
+	packet := []byte{
+		0x60, 0x00, 0x00, 0x00, // version, traffic class, flow label.
+		0x00, 0x00, // payload length.
+		0x3a, // next header: icmpv6
+		0x40, // hop limit.
+	}
+	packet = append(packet, net.ParseIP(src).To16()...)
+	return append(packet, net.ParseIP(dst).To16()...)
+}
+
+func TestP2PL3IPv6Mesh(t *testing.T) {
+	packet := [][]byte{
+		buildIPv6Packet("fd00:4::2", "fd00:5::1"),
+		buildIPv6Packet("fd00:5::1", "fd00:4::2"),
+		buildIPv6Packet("fd00:5::1", "fd00:5::3"),
+		buildIPv6Packet("fd00:5::2", "ff02::1"),
+		buildIPv6Packet("fe80::1", "fd00:5::3"),
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		route := NewP2PL3IPv6MeshNetworkRouter()
+		self := &MockMeshNetPeer{Self: true, ID: "self"}
+		peer1 := &MockMeshNetPeer{Self: false, ID: "peer1"}
+		peer2 := &MockMeshNetPeer{Self: false, ID: "peer2"}
+		peer3 := &MockMeshNetPeer{Self: false, ID: "peer3"}
+		peer4 := &MockMeshNetPeer{Self: false, ID: "peer4"}
+
+		route.PeerJoin(self)
+		route.PeerJoin(peer1)
+		route.PeerJoin(peer2)
+		route.PeerJoin(peer3)
+
+		// drop ipv4 and truncated packet.
+		assert.Equal(t, 0, len(route.Route([]byte{0x45, 0x00}, self)))
+		assert.Equal(t, 0, len(route.Route(packet[0][:39], self)))
+
+		// unicast miss.
+		peers := route.Route(packet[0], self)
+		assert.Equal(t, 3, len(peers))
+		assert.Contains(t, peers, MeshNetPeer(peer1))
+		assert.Contains(t, peers, MeshNetPeer(peer2))
+		assert.Contains(t, peers, MeshNetPeer(peer3))
+		// unicast from the remote.
+		peers = route.Route(packet[1], peer2)
+		assert.Equal(t, 1, len(peers))
+		assert.Contains(t, peers, MeshNetPeer(self))
+		peers = route.Route(packet[0], self)
+		assert.Equal(t, []MeshNetPeer{peer2}, peers)
+		// updated unicast route.
+		route.Route(packet[1], peer1)
+		peers = route.Route(packet[0], self)
+		assert.Equal(t, []MeshNetPeer{peer1}, peers)
+
+		// drop packet from unknown peer.
+		assert.Equal(t, 0, len(route.Route(packet[2], peer4)))
+		// drop multicast packet.
+		assert.Equal(t, 0, len(route.Route(packet[3], peer2)))
+		// do not learn link-local address.
+		route.Route(packet[4], peer3)
+		_, learned := route.ip2Peer[[16]byte{0xfe, 0x80, 15: 0x01}]
+		assert.False(t, learned)
+
+		// remove routes when peer leaves.
+		route.PeerLeave(peer1)
+		peers = route.Route(packet[0], self)
+		assert.Equal(t, 2, len(peers))
+		assert.Contains(t, peers, MeshNetPeer(peer2))
+		assert.Contains(t, peers, MeshNetPeer(peer3))
+	})
+
+	t.Run("static_routes", func(t *testing.T) {
+		route := NewP2PL3IPv6MeshNetworkRouter()
+		self := &MockMeshNetPeer{Self: true, ID: "self"}
+		peer1 := &MockMeshNetPeer{Self: false, ID: "peer1"}
+		peer2 := &MockMeshNetPeer{Self: false, ID: "peer2"}
+		peer3 := &MockMeshNetPeer{Self: false, ID: "peer3"}
+		unknown := &MockMeshNetPeer{Self: false, ID: "unknown"}
+
+		route.PeerJoin(self)
+		route.PeerJoin(peer1)
+		route.PeerJoin(peer2)
+		route.PeerJoin(peer3)
+
+		var subnet [4]*net.IPNet
+		var err error
+		_, subnet[0], err = net.ParseCIDR("fd00:5::/64")
+		assert.NoError(t, err)
+		_, subnet[1], err = net.ParseCIDR("fd00:5::/80")
+		assert.NoError(t, err)
+		_, subnet[2], err = net.ParseCIDR("fd00:3::/64")
+		assert.NoError(t, err)
+		_, subnet[3], err = net.ParseCIDR("10.240.5.0/24")
+		assert.NoError(t, err)
+		assert.Error(t, route.AddStaticCIDRRoutes(peer1, subnet[0], subnet[1]))
+		assert.Error(t, route.AddStaticCIDRRoutes(peer1, subnet[3]))
+		assert.Equal(t, ErrInvalidPeer, route.AddStaticCIDRRoutes(unknown, subnet[0]))
+		assert.NoError(t, route.AddStaticCIDRRoutes(peer1, subnet[0]))
+		assert.Error(t, route.AddStaticCIDRRoutes(self, subnet[1]))
+		assert.NoError(t, route.AddStaticCIDRRoutes(peer2, subnet[2]))
+
+		// unicast should not miss.
+		peers := route.Route(packet[0], self)
+		assert.Equal(t, []MeshNetPeer{peer1}, peers)
+		peers = route.Route(buildIPv6Packet("fd00:4::2", "fd00:3::1"), self)
+		assert.Equal(t, []MeshNetPeer{peer2}, peers)
+
+		// remove static route.
+		assert.False(t, route.RemoveStaticCIDRRoutes(peer3, subnet[2]))
+		assert.False(t, route.RemoveStaticCIDRRoutes(peer2, subnet[0]))
+		assert.True(t, route.RemoveStaticCIDRRoutes(peer2, subnet[2]))
+		peers = route.Route(buildIPv6Packet("fd00:4::2", "fd00:3::1"), self)
+		assert.Equal(t, 3, len(peers))
+
+		// remove routes when peer leaves.
+		route.PeerLeave(peer1)
+		peers = route.Route(packet[0], self)
+		assert.Equal(t, 2, len(peers))
+		assert.Contains(t, peers, MeshNetPeer(peer2))
+		assert.Contains(t, peers, MeshNetPeer(peer3))
+	})
+}
+
+func TestP2PL3DualStackMesh(t *testing.T) {
+	route := NewP2PL3MeshNetworkRouter()
+	self := &MockMeshNetPeer{Self: true, ID: "self"}
+	peer1 := &MockMeshNetPeer{Self: false, ID: "peer1"}
+	peer2 := &MockMeshNetPeer{Self: false, ID: "peer2"}
+
+	route.PeerJoin(self)
+	route.PeerJoin(peer1)
+	route.PeerJoin(peer2)
+
+	var v4, v6 *net.IPNet
+	var err error
+	_, v4, err = net.ParseCIDR("10.240.5.0/24")
+	assert.NoError(t, err)
+	_, v6, err = net.ParseCIDR("fd00:5::/64")
+	assert.NoError(t, err)
+	assert.NoError(t, route.AddStaticCIDRRoutes(peer1, v4))
+	assert.NoError(t, route.AddStaticCIDRRoutes(peer2, v6))
+
+	v4Packet := []byte{
+		0x45, 0x00,
+		0x00, 0x54, // length.
+		0xa8, 0x52, 0x00, 0x00, 0x40,
+		0x01, // type: icmp
+		0xd5, 0xed,
+		10, 240, 4, 2, // src IP: 10.240.4.2
+		10, 240, 5, 1, // dst IP: 10.240.5.1
+	}
+	assert.Equal(t, []MeshNetPeer{peer1}, route.Route(v4Packet, self))
+	assert.Equal(t, []MeshNetPeer{peer2}, route.Route(buildIPv6Packet("fd00:4::2", "fd00:5::1"), self))
+	assert.Equal(t, 0, len(route.Route([]byte{0x20, 0x00}, self)))
+	assert.Equal(t, 0, len(route.Route(nil, self)))
+
+	assert.True(t, route.RemoveStaticCIDRRoutes(peer2, v6))
+	peers := route.Route(buildIPv6Packet("fd00:4::2", "fd00:5::1"), self)
+	assert.Equal(t, 2, len(peers))
+	assert.Equal(t, []MeshNetPeer{peer1}, route.Route(v4Packet, self))
+
+	// failure of one address family doesn't block the other.
+	var v4Overlapped, v6Next *net.IPNet
+	_, v4Overlapped, err = net.ParseCIDR("10.240.5.0/25")
+	assert.NoError(t, err)
+	_, v6Next, err = net.ParseCIDR("fd00:6::/64")
+	assert.NoError(t, err)
+	assert.Error(t, route.AddStaticCIDRRoutes(peer2, v4Overlapped, v6Next))
+	assert.Equal(t, []MeshNetPeer{peer2}, route.Route(buildIPv6Packet("fd00:4::2", "fd00:6::1"), self))
+
+	// peers leave both stacks.
+	route.PeerLeave(peer1)
+	assert.Equal(t, []MeshNetPeer{peer2}, route.Route(v4Packet, self))
+}
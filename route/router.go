@@ -15,6 +15,7 @@ type PeerActivityWatcher interface {
 }
 
 // MeshDataNetworkRouter routes packet over mesh network.
+// Returned peers may be shared among calls, and shouldn't be modified.
 type MeshDataNetworkRouter interface {
 	Route(raw []byte, from MeshNetPeer) []MeshNetPeer
 }